@@ -0,0 +1,228 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// Configuration is read from yaml file, then overridden by environment variables and command-line flags.
+// Variable of key is GOWALLET_ followed by upper-cased key with "." replaced by "_", e.g. GOWALLET_DATABASE_DSN
+// for database.dsn. Path of file is --config flag or GOWALLET_CONFIG, missing default file is ignored,
+// so binary runs in container configured by environment only
+
+const (
+	envPrefix         = "GOWALLET"
+	defaultConfigFile = "../config/config.yaml"
+
+	// minTokenKeyLen is minimum length of key of access tokens, shorter keys can be guessed
+	minTokenKeyLen = 32
+)
+
+// config is configuration of server
+type config struct {
+	Server    serverConfig    `mapstructure:"server"`
+	Database  databaseConfig  `mapstructure:"database"`
+	Business  businessConfig  `mapstructure:"business"`
+	Exchange  exchangeConfig  `mapstructure:"exchange"`
+	Holds     holdsConfig     `mapstructure:"holds"`
+	Scheduler schedulerConfig `mapstructure:"scheduler"`
+	Security  securityConfig  `mapstructure:"security"`
+}
+
+// serverConfig is address and timeouts of http server
+type serverConfig struct {
+	Host            string        `mapstructure:"host"`
+	Port            string        `mapstructure:"port"`
+	ReadTimeout     time.Duration `mapstructure:"read_timeout"`
+	WriteTimeout    time.Duration `mapstructure:"write_timeout"`
+	IdleTimeout     time.Duration `mapstructure:"idle_timeout"`
+	ShutdownTimeout time.Duration `mapstructure:"shutdown_timeout"`
+}
+
+type databaseConfig struct {
+	DSN string `mapstructure:"dsn"`
+}
+
+type businessConfig struct {
+	Timezone string `mapstructure:"timezone"`
+}
+
+type exchangeConfig struct {
+	RatesFile string `mapstructure:"rates_file"`
+}
+
+type holdsConfig struct {
+	TTL            time.Duration `mapstructure:"ttl"`
+	ExpiryInterval time.Duration `mapstructure:"expiry_interval"`
+}
+
+type schedulerConfig struct {
+	Interval    time.Duration `mapstructure:"interval"`
+	RetryDelay  time.Duration `mapstructure:"retry_delay"`
+	MaxAttempts int           `mapstructure:"max_attempts"`
+}
+
+type securityConfig struct {
+	ClockSkew time.Duration `mapstructure:"clock_skew"`
+	TokenKey  string        `mapstructure:"token_key"`
+	TokenTTL  time.Duration `mapstructure:"token_ttl"`
+}
+
+// defaults are values of keys absent from file and environment. Every key must have default,
+// otherwise its environment variable is not read. Database dsn and token key have no usable default
+var defaults = map[string]interface{}{
+	"server.host":             "0.0.0.0",
+	"server.port":             "9999",
+	"server.read_timeout":     "30s",
+	"server.write_timeout":    "5m",
+	"server.idle_timeout":     "2m",
+	"server.shutdown_timeout": "30s",
+	"database.dsn":            "",
+	"business.timezone":       "Asia/Dushanbe",
+	"exchange.rates_file":     "",
+	"holds.ttl":               "168h",
+	"holds.expiry_interval":   "1m",
+	"scheduler.interval":      "1m",
+	"scheduler.retry_delay":   "10m",
+	"scheduler.max_attempts":  3,
+	"security.clock_skew":     "5m",
+	"security.token_key":      "",
+	"security.token_ttl":      "1h",
+}
+
+// overrideFlags are command-line flags which take precedence over file and environment
+var overrideFlags = []struct {
+	name  string
+	key   string
+	usage string
+}{
+	{"host", "server.host", "host to listen on"},
+	{"port", "server.port", "port to listen on"},
+	{"dsn", "database.dsn", "database connection string"},
+}
+
+// loadConfig parses flags of args, reads and validates configuration and returns it with arguments left after flags.
+// Commands like migrate only connect to database, so only server configuration is validated in full
+func loadConfig(args []string) (*config, []string, error) {
+	flags := flag.NewFlagSet("gowallet", flag.ContinueOnError)
+	file := flags.String("config", os.Getenv(envPrefix+"_CONFIG"), "path of yaml config file (default "+defaultConfigFile+")")
+	for _, f := range overrideFlags {
+		flags.String(f.name, "", f.usage+", overrides "+f.key)
+	}
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: gowallet [flags] [migrate up|down [steps]|status|baseline | partner rotate <partner_id> <key_id> [overlap]]\n\nFlags:\n")
+		flags.PrintDefaults()
+		fmt.Fprintf(flags.Output(), "\nEvery config key is also read from environment variable like %s_DATABASE_DSN\n", envPrefix)
+	}
+	if err := flags.Parse(args); err != nil {
+		return nil, nil, err
+	}
+
+	v := viper.New()
+	for key, value := range defaults {
+		v.SetDefault(key, value)
+	}
+	v.SetEnvPrefix(envPrefix)
+	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	v.AutomaticEnv()
+
+	v.SetConfigType("yaml")
+	if *file != "" {
+		v.SetConfigFile(*file)
+		if err := v.ReadInConfig(); err != nil {
+			return nil, nil, fmt.Errorf("reading config file %s: %w", *file, err)
+		}
+	} else {
+		v.SetConfigFile(defaultConfigFile)
+		err := v.ReadInConfig()
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, nil, fmt.Errorf("reading config file %s: %w", defaultConfigFile, err)
+		}
+	}
+
+	flags.Visit(func(f *flag.Flag) {
+		for _, override := range overrideFlags {
+			if override.name == f.Name {
+				v.Set(override.key, f.Value.String())
+			}
+		}
+	})
+
+	cfg := &config{}
+	if err := v.Unmarshal(cfg); err != nil {
+		return nil, nil, fmt.Errorf("decoding config: %w", err)
+	}
+	validate := cfg.validate
+	if flags.NArg() > 0 {
+		validate = cfg.validateCommand
+	}
+	if err := validate(); err != nil {
+		return nil, nil, err
+	}
+	return cfg, flags.Args(), nil
+}
+
+// validate returns error listing all invalid keys of configuration
+func (c *config) validate() error {
+	var problems []string
+	required := func(key string, value string) {
+		if strings.TrimSpace(value) == "" {
+			problems = append(problems, key+" is required")
+		}
+	}
+	positive := func(key string, value time.Duration) {
+		if value <= 0 {
+			problems = append(problems, key+" must be positive duration")
+		}
+	}
+	notNegative := func(key string, value time.Duration) {
+		if value < 0 {
+			problems = append(problems, key+" must not be negative, 0 disables timeout")
+		}
+	}
+
+	required("server.host", c.Server.Host)
+	if port, err := strconv.Atoi(c.Server.Port); err != nil || port <= 0 || port > 65535 {
+		problems = append(problems, fmt.Sprintf("server.port %q is not port number", c.Server.Port))
+	}
+	notNegative("server.read_timeout", c.Server.ReadTimeout)
+	notNegative("server.write_timeout", c.Server.WriteTimeout)
+	notNegative("server.idle_timeout", c.Server.IdleTimeout)
+	positive("server.shutdown_timeout", c.Server.ShutdownTimeout)
+	required("database.dsn", c.Database.DSN)
+	if _, err := time.LoadLocation(c.Business.Timezone); err != nil {
+		problems = append(problems, fmt.Sprintf("business.timezone %q is unknown", c.Business.Timezone))
+	}
+	positive("holds.ttl", c.Holds.TTL)
+	positive("holds.expiry_interval", c.Holds.ExpiryInterval)
+	positive("scheduler.interval", c.Scheduler.Interval)
+	positive("scheduler.retry_delay", c.Scheduler.RetryDelay)
+	if c.Scheduler.MaxAttempts < 1 {
+		problems = append(problems, "scheduler.max_attempts must be at least 1")
+	}
+	positive("security.clock_skew", c.Security.ClockSkew)
+	if len(c.Security.TokenKey) < minTokenKeyLen {
+		problems = append(problems, fmt.Sprintf("security.token_key must be at least %d bytes, set %s_SECURITY_TOKEN_KEY", minTokenKeyLen, envPrefix))
+	}
+	positive("security.token_ttl", c.Security.TokenTTL)
+
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid config: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// validateCommand returns error if configuration lacks keys used by commands
+func (c *config) validateCommand() error {
+	if strings.TrimSpace(c.Database.DSN) == "" {
+		return errors.New("invalid config: database.dsn is required")
+	}
+	return nil
+}
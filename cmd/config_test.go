@@ -0,0 +1,151 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testConfig = `
+server:
+  port: "8080"
+  host: "127.0.0.1"
+  read_timeout: "10s"
+database:
+  dsn: "postgres://file"
+scheduler:
+  max_attempts: 5
+security:
+  token_key: "file-secret-of-32-bytes-or-longer"
+`
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadConfig_Precedence(t *testing.T) {
+	path := writeConfig(t, testConfig)
+	t.Setenv("GOWALLET_DATABASE_DSN", "postgres://env")
+	t.Setenv("GOWALLET_SERVER_PORT", "8081")
+	t.Setenv("GOWALLET_HOLDS_TTL", "24h")
+
+	cfg, args, err := loadConfig([]string{"--config", path, "--port", "8082", "migrate", "up"})
+	if err != nil {
+		t.Fatalf("loadConfig error: %v", err)
+	}
+	if strings.Join(args, " ") != "migrate up" {
+		t.Errorf("args = %q, want migrate up", args)
+	}
+
+	tests := []struct {
+		name string
+		got  interface{}
+		want interface{}
+	}{
+		{"value of file", cfg.Server.Host, "127.0.0.1"},
+		{"duration of file", cfg.Server.ReadTimeout, 10 * time.Second},
+		{"int of file", cfg.Scheduler.MaxAttempts, 5},
+		{"default", cfg.Server.ShutdownTimeout, 30 * time.Second},
+		{"env over file", cfg.Database.DSN, "postgres://env"},
+		{"env over default", cfg.Holds.TTL, 24 * time.Hour},
+		{"flag over env", cfg.Server.Port, "8082"},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s = %v, want %v", tt.name, tt.got, tt.want)
+		}
+	}
+}
+
+func TestLoadConfig_EnvironmentOnly(t *testing.T) {
+	// default config file is relative to working directory, there is none in empty directory
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	t.Setenv("GOWALLET_DATABASE_DSN", "postgres://env")
+	t.Setenv("GOWALLET_SECURITY_TOKEN_KEY", "env-secret-of-32-bytes-or-longer")
+	t.Setenv("GOWALLET_SCHEDULER_MAX_ATTEMPTS", "2")
+
+	cfg, _, err := loadConfig(nil)
+	if err != nil {
+		t.Fatalf("loadConfig error: %v", err)
+	}
+	if cfg.Database.DSN != "postgres://env" || cfg.Security.TokenKey != "env-secret-of-32-bytes-or-longer" || cfg.Scheduler.MaxAttempts != 2 || cfg.Server.Port != "9999" {
+		t.Errorf("config = %+v, want environment values and defaults", cfg)
+	}
+}
+
+func TestLoadConfig_CommandNeedsOnlyDSN(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	if _, _, err := loadConfig([]string{"migrate", "status"}); err == nil || !strings.Contains(err.Error(), "database.dsn is required") {
+		t.Errorf("loadConfig of command without dsn error = %v, want database.dsn is required", err)
+	}
+
+	t.Setenv("GOWALLET_DATABASE_DSN", "postgres://env")
+	cfg, args, err := loadConfig([]string{"migrate", "status"})
+	if err != nil || cfg.Database.DSN != "postgres://env" || strings.Join(args, " ") != "migrate status" {
+		t.Errorf("loadConfig of command = %+v, %q, %v, want dsn of environment without token key", cfg, args, err)
+	}
+	if _, _, err := loadConfig(nil); err == nil || !strings.Contains(err.Error(), "security.token_key") {
+		t.Errorf("loadConfig of server without token key error = %v, want security.token_key", err)
+	}
+}
+
+func TestLoadConfig_Errors(t *testing.T) {
+	path := writeConfig(t, testConfig)
+
+	tests := []struct {
+		name string
+		args []string
+		env  map[string]string
+		want []string
+	}{
+		{"missing file", []string{"--config", filepath.Join(t.TempDir(), "none.yaml")}, nil, []string{"none.yaml"}},
+		{"bad yaml", []string{"--config", writeConfig(t, "server: [")}, nil, []string{"reading config file"}},
+		{"unknown flag", []string{"--verbose"}, nil, []string{"verbose"}},
+		{"bad duration", []string{"--config", path}, map[string]string{"GOWALLET_SERVER_READ_TIMEOUT": "soon"}, []string{"read_timeout"}},
+		{"bad int", []string{"--config", path}, map[string]string{"GOWALLET_SCHEDULER_MAX_ATTEMPTS": "many"}, []string{"max_attempts"}},
+		{"invalid values", []string{"--config", writeConfig(t, "server:\n  host: \"\"\n"), "--dsn", " ", "--port", "http"}, map[string]string{
+			"GOWALLET_HOLDS_TTL":         "0s",
+			"GOWALLET_BUSINESS_TIMEZONE": "Mars/Olympus",
+		}, []string{"server.host is required", "database.dsn is required", "server.port \"http\"", "holds.ttl must be positive", "business.timezone", "security.token_key must be at least 32 bytes"}},
+		{"short token key", []string{"--config", path}, map[string]string{"GOWALLET_SECURITY_TOKEN_KEY": "TokenSecret"}, []string{"security.token_key must be at least 32 bytes"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+			_, _, err := loadConfig(tt.args)
+			if err == nil {
+				t.Fatalf("loadConfig error = nil, want error")
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("loadConfig error = %q, want it to mention %q", err, want)
+				}
+			}
+		})
+	}
+}
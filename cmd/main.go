@@ -2,56 +2,121 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
 	"log"
 	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/SYSTEMTerror/GoWallet/internal/app"
+	"github.com/SYSTEMTerror/GoWallet/internal/app/middleware"
+	"github.com/SYSTEMTerror/GoWallet/internal/pkg/migrate"
+	"github.com/SYSTEMTerror/GoWallet/internal/pkg/partner"
+	"github.com/SYSTEMTerror/GoWallet/internal/pkg/scheduler"
 	"github.com/SYSTEMTerror/GoWallet/internal/pkg/wallet"
 	"github.com/gorilla/mux"
 	"github.com/jackc/pgx/v4/pgxpool"
-	"github.com/spf13/viper"
 	"go.uber.org/dig"
 )
 
 func main() {
-	viper.AddConfigPath("../config")
-	viper.SetConfigName("config")
-	viper.SetConfigType("yaml")
-	err := viper.ReadInConfig()
+	cfg, args, err := loadConfig(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
 	if err != nil {
-		log.Fatalf("Error reading config file, %s", err)
+		log.Fatalf("Error loading config, %s", err)
+	}
+
+	if len(args) > 0 {
+		var err error
+		switch args[0] {
+		case "migrate":
+			err = migrateCommand(cfg.Database.DSN, args[1:])
+		case "partner":
+			err = partnerCommand(cfg.Database.DSN, args[1:])
+		default:
+			log.Fatalf("Unknown command %q", args[0])
+		}
+		if err != nil {
+			log.Print(err)
+			os.Exit(1)
+		}
+		return
 	}
 
-	host := viper.Get("server.host").(string)
-	port := viper.Get("server.port").(string)
-	dsn := viper.Get("database.dsn").(string)
-	secretKey := viper.Get("security.secret_key").(string)
+	location, err := time.LoadLocation(cfg.Business.Timezone)
+	if err != nil {
+		log.Fatalf("Error loading business.timezone, %s", err)
+	}
 
-	if err := execute(host, port, dsn, secretKey); err != nil {
+	signatureConfig := &middleware.SignatureConfig{ClockSkew: cfg.Security.ClockSkew}
+	tokenConfig := &wallet.TokenConfig{Secret: cfg.Security.TokenKey, TTL: cfg.Security.TokenTTL}
+	holdConfig := &wallet.HoldConfig{TTL: cfg.Holds.TTL, ExpiryInterval: cfg.Holds.ExpiryInterval}
+	schedulerConfig := &scheduler.Config{Interval: cfg.Scheduler.Interval, RetryDelay: cfg.Scheduler.RetryDelay, MaxAttempts: cfg.Scheduler.MaxAttempts}
+	if err := execute(&cfg.Server, cfg.Database.DSN, signatureConfig, tokenConfig, holdConfig, schedulerConfig, location, cfg.Exchange.RatesFile); err != nil {
 		log.Print(err)
 		os.Exit(1)
 	}
 }
 
-func execute(host string, port string, dsn string, secretKey string) (err error) {
+func execute(serverConfig *serverConfig, dsn string, signatureConfig *middleware.SignatureConfig, tokenConfig *wallet.TokenConfig, holdConfig *wallet.HoldConfig, schedulerConfig *scheduler.Config, location *time.Location, ratesFile string) (err error) {
 	deps := []interface{}{
 		app.NewServer,
-		func() (string) {
-			return secretKey
+		func() *middleware.SignatureConfig {
+			return signatureConfig
+		},
+		func() *wallet.TokenConfig {
+			return tokenConfig
+		},
+		func() *wallet.HoldConfig {
+			return holdConfig
+		},
+		func() *scheduler.Config {
+			return schedulerConfig
+		},
+		func() *time.Location {
+			return location
 		},
 		mux.NewRouter,
 		func() (*pgxpool.Pool, error) {
-			ctx, _ := context.WithTimeout(context.Background(), 10*time.Second)
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
 			return pgxpool.Connect(ctx, dsn)
 		},
+		func(pool *pgxpool.Pool) wallet.Repository {
+			return wallet.NewPostgresRepository(pool)
+		},
+		func(pool *pgxpool.Pool) partner.Repository {
+			return partner.NewPostgresRepository(pool)
+		},
+		func(pool *pgxpool.Pool) scheduler.Repository {
+			return scheduler.NewPostgresRepository(pool)
+		},
 		wallet.NewService,
+		partner.NewService,
+		scheduler.NewService,
+		func(svc *partner.Service) app.PartnerService {
+			return svc
+		},
+		func(svc *scheduler.Service) app.SchedulerService {
+			return svc
+		},
 		func(server *app.Server) *http.Server {
 			return &http.Server{
-				Addr:    net.JoinHostPort(host, port),
-				Handler: server,
+				Addr:         net.JoinHostPort(serverConfig.Host, serverConfig.Port),
+				Handler:      server,
+				ReadTimeout:  serverConfig.ReadTimeout,
+				WriteTimeout: serverConfig.WriteTimeout,
+				IdleTimeout:  serverConfig.IdleTimeout,
 			}
 		},
 	}
@@ -64,14 +129,186 @@ func execute(host string, port string, dsn string, secretKey string) (err error)
 		}
 	}
 
-	err = container.Invoke(func(server *app.Server) {
+	// SIGINT or SIGTERM starts graceful shutdown
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	var workers sync.WaitGroup
+	err = container.Invoke(func(server *app.Server, walletSvc *wallet.Service, schedulerSvc *scheduler.Service) error {
+		if ratesFile != "" {
+			_, err := walletSvc.LoadRatesFile(ctx, ratesFile)
+			if err != nil {
+				return err
+			}
+		}
 		server.Init()
+		workers.Add(2)
+		go func() {
+			defer workers.Done()
+			walletSvc.RunHoldExpiry(ctx)
+		}()
+		go func() {
+			defer workers.Done()
+			schedulerSvc.Run(ctx)
+		}()
+		return nil
 	})
 	if err != nil {
 		return err
 	}
 
-	return container.Invoke(func(server *http.Server) error {
-		return server.ListenAndServe()
+	return container.Invoke(func(server *http.Server, pool *pgxpool.Pool) error {
+		listener, err := net.Listen("tcp", server.Addr)
+		if err != nil {
+			stop()
+			workers.Wait()
+			pool.Close()
+			return err
+		}
+		return serve(ctx, stop, server, listener, &workers, pool.Close, serverConfig.ShutdownTimeout)
 	})
 }
+
+// serve accepts connections of listener until ctx is done, then stops accepting them, waits for in-flight requests and
+// background workers within shutdown timeout and calls closePool. Requests still running after timeout are cut off and
+// closePool is not called, because pool waits for connections held by them
+func serve(ctx context.Context, stop context.CancelFunc, server *http.Server, listener net.Listener, workers *sync.WaitGroup, closePool func(), timeout time.Duration) error {
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- server.Serve(listener)
+	}()
+
+	select {
+	case err := <-serveErr:
+		stop()
+		workers.Wait()
+		closePool()
+		return err
+	case <-ctx.Done():
+	}
+	// the next signal kills process
+	stop()
+	log.Println("Shutting down, draining requests for", timeout)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	err := server.Shutdown(shutdownCtx)
+	if err != nil {
+		server.Close()
+		return fmt.Errorf("shutdown: %w", err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		workers.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-shutdownCtx.Done():
+		return errors.New("shutdown: background workers did not stop in time")
+	}
+
+	closePool()
+	log.Println("Server stopped")
+	return nil
+}
+
+// migrateCommand runs "migrate up", "migrate down [steps]", "migrate status" or "migrate baseline" against database of dsn.
+// Baseline adopts database created from schema.sql before migrations, it is run once before its first "migrate up"
+func migrateCommand(dsn string, args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: migrate up|down [steps]|status|baseline")
+	}
+
+	ctx := context.Background()
+	pool, err := pgxpool.Connect(ctx, dsn)
+	if err != nil {
+		return err
+	}
+	defer pool.Close()
+
+	migrator, err := migrate.New(pool)
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		for _, migration := range applied {
+			fmt.Printf("applied %d_%s\n", migration.Version, migration.Name)
+		}
+		return err
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps <= 0 {
+				return fmt.Errorf("invalid number of steps %q", args[1])
+			}
+		}
+		reverted, err := migrator.Down(ctx, steps)
+		for _, migration := range reverted {
+			fmt.Printf("reverted %d_%s\n", migration.Version, migration.Name)
+		}
+		return err
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		for _, status := range statuses {
+			applied := "pending"
+			if status.Applied != nil {
+				applied = status.Applied.Format(time.RFC3339)
+			}
+			fmt.Printf("%d_%s\t%s\n", status.Version, status.Name, applied)
+		}
+		return nil
+	case "baseline":
+		baseline, err := migrator.Baseline(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("marked %d_%s as applied\n", baseline.Version, baseline.Name)
+		return nil
+	}
+	return fmt.Errorf("unknown migrate command %q", args[0])
+}
+
+// partnerCommand runs "partner rotate <partner_id> <key_id> [overlap]" against database of dsn. Rotate prints secret of
+// new key, current keys of partner stay valid for overlap (24h by default)
+func partnerCommand(dsn string, args []string) error {
+	if len(args) < 3 || len(args) > 4 || args[0] != "rotate" {
+		return errors.New("usage: partner rotate <partner_id> <key_id> [overlap]")
+	}
+	overlap := 24 * time.Hour
+	if len(args) == 4 {
+		var err error
+		overlap, err = time.ParseDuration(args[3])
+		if err != nil {
+			return fmt.Errorf("invalid overlap %q", args[3])
+		}
+	}
+
+	ctx := context.Background()
+	pool, err := pgxpool.Connect(ctx, dsn)
+	if err != nil {
+		return err
+	}
+	defer pool.Close()
+
+	return rotateKey(ctx, os.Stdout, partner.NewService(partner.NewPostgresRepository(pool)), args[1], args[2], overlap)
+}
+
+// rotateKey rotates key of partner and writes new key to w
+func rotateKey(ctx context.Context, w io.Writer, svc *partner.Service, partnerID string, keyID string, overlap time.Duration) error {
+	key, _, err := svc.RotateKey(ctx, partnerID, keyID, overlap)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "partner_id\t%s\nkey_id\t%s\nsecret\t%s\nvalid_from\t%s\nold keys valid to\t%s\n",
+		key.PartnerID, key.KeyID, key.Secret, key.ValidFrom.Format(time.RFC3339), key.ValidFrom.Add(overlap).Format(time.RFC3339))
+	return err
+}
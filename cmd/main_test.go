@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/SYSTEMTerror/GoWallet/internal/pkg/partner"
+)
+
+// startServe runs serve with handler and one background worker which stops with ctx
+func startServe(t *testing.T, handler http.Handler, timeout time.Duration) (string, context.CancelFunc, *int32, chan error) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, stop := context.WithCancel(context.Background())
+
+	var workers sync.WaitGroup
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		<-ctx.Done()
+	}()
+
+	var poolClosed int32
+	result := make(chan error, 1)
+	go func() {
+		result <- serve(ctx, stop, &http.Server{Handler: handler}, listener, &workers, func() { atomic.StoreInt32(&poolClosed, 1) }, timeout)
+	}()
+	return "http://" + listener.Addr().String(), stop, &poolClosed, result
+}
+
+func TestServe_DrainsInFlightRequests(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	url, stop, poolClosed, result := startServe(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		w.WriteHeader(http.StatusOK)
+	}), 5*time.Second)
+
+	response := make(chan error, 1)
+	go func() {
+		resp, err := http.Get(url)
+		if err == nil {
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				err = fmt.Errorf("status %s", resp.Status)
+			}
+		}
+		response <- err
+	}()
+
+	<-started
+	stop()
+	select {
+	case err := <-result:
+		t.Fatalf("serve returned %v before in-flight request finished", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+	if _, err := http.Get(url); err == nil {
+		t.Errorf("new connection accepted during shutdown")
+	}
+	if atomic.LoadInt32(poolClosed) != 0 {
+		t.Errorf("pool closed before in-flight request finished")
+	}
+
+	close(release)
+	if err := <-response; err != nil {
+		t.Errorf("in-flight request error: %v", err)
+	}
+	if err := <-result; err != nil {
+		t.Errorf("serve error: %v", err)
+	}
+	if atomic.LoadInt32(poolClosed) != 1 {
+		t.Errorf("pool is not closed after shutdown")
+	}
+}
+
+func TestServe_ShutdownTimeout(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	defer close(release)
+	url, stop, poolClosed, result := startServe(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+	}), 50*time.Millisecond)
+
+	go func() {
+		resp, err := http.Get(url)
+		if err == nil {
+			resp.Body.Close()
+		}
+	}()
+
+	<-started
+	stop()
+	select {
+	case err := <-result:
+		if err == nil {
+			t.Errorf("serve error = nil, want shutdown timeout")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("serve did not return after shutdown timeout")
+	}
+	if atomic.LoadInt32(poolClosed) != 0 {
+		t.Errorf("pool closed while request still holds it")
+	}
+}
+
+func TestPartnerCommand_Usage(t *testing.T) {
+	for _, args := range [][]string{nil, {"rotate", "shop"}, {"revoke", "shop", "k2"}, {"rotate", "shop", "k2", "1h", "x"}} {
+		err := partnerCommand("postgres://unused", args)
+		if err == nil || !strings.Contains(err.Error(), "usage") {
+			t.Errorf("partnerCommand(%q) error = %v, want usage", args, err)
+		}
+	}
+	if err := partnerCommand("postgres://unused", []string{"rotate", "shop", "k2", "soon"}); err == nil || !strings.Contains(err.Error(), "overlap") {
+		t.Errorf("partnerCommand with bad overlap error = %v, want invalid overlap", err)
+	}
+}
+
+func TestRotateKey(t *testing.T) {
+	repo := partner.NewMemoryRepository()
+	repo.AddPartner("shop", true, partner.AllEndpoints)
+	svc := partner.NewService(repo)
+
+	var out bytes.Buffer
+	if err := rotateKey(context.Background(), &out, svc, "shop", "k2", time.Hour); err != nil {
+		t.Fatalf("rotateKey error: %v", err)
+	}
+	var secret string
+	for _, line := range strings.Split(out.String(), "\n") {
+		if strings.HasPrefix(line, "secret\t") {
+			secret = strings.TrimPrefix(line, "secret\t")
+		}
+	}
+	got, err := svc.Secret(context.Background(), "shop", "k2", "/api/wallet/holds")
+	if err != nil || secret == "" || got != secret {
+		t.Errorf("printed secret %q, secret of new key = %q, %v", secret, got, err)
+	}
+
+	if err := rotateKey(context.Background(), &out, svc, "nobody", "k2", time.Hour); err != partner.ErrPartnerNotFound {
+		t.Errorf("rotateKey of unknown partner error = %v, want %v", err, partner.ErrPartnerNotFound)
+	}
+}
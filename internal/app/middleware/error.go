@@ -0,0 +1,18 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// writeError writes rejection of request in the same json form as handlers: {"error": status text}.
+// Unlike responses of handlers it is not signed, partner of rejected request is not verified or not allowed to call endpoint
+func writeError(w http.ResponseWriter, code int) {
+	data, _ := json.Marshal(struct {
+		Error string `json:"error"`
+	}{http.StatusText(code)})
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(code)
+	w.Write(data)
+}
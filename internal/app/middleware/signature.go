@@ -0,0 +1,202 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// Request signing scheme.
+//
+// Client sends headers:
+//
+//	X-Partner-ID: id of partner
+//	X-Key-ID:     id of partner key, secret of this key signs request
+//	X-Timestamp:  unix time in seconds
+//	X-Nonce:      unique random string, 8 to 64 characters
+//	X-Signature:  "sha256=" + hex(HMAC-SHA256(secret, canonical))
+//
+// where canonical is
+//
+//	METHOD + "\n" + PATH + "\n" + X-Timestamp + "\n" + X-Nonce + "\n" + hex(SHA256(raw body))
+//
+// PATH is escaped path of request followed by "?" and raw query, if request has query. Requests with timestamp outside of
+// clock skew window or with nonce already seen within the window are rejected.
+// Responses are signed with "X-Signature: sha256=" + hex(HMAC-SHA256(secret, raw body)).
+// Partner key must be active and valid now. Correctly signed request of partner not allowed to call route of request
+// is rejected with 403.
+
+const (
+	minNonceLen = 8
+	maxNonceLen = 64
+)
+
+var partnerContextKey = &contextKey{"partner context"}
+
+var (
+	// ErrUnknownKey is returned by SecretFunc, if key of partner is unknown, inactive or not valid now
+	ErrUnknownKey = errors.New("partner key not found")
+	// ErrForbidden is returned by SecretFunc with secret of key, if partner is not allowed to call endpoint
+	ErrForbidden = errors.New("endpoint not allowed for partner")
+)
+
+// SignatureConfig is configuration of request signature verification
+type SignatureConfig struct {
+	ClockSkew time.Duration
+}
+
+// SecretFunc returns secret of partner key, with ErrForbidden if partner is not allowed to call endpoint.
+// Unknown key is reported with ErrUnknownKey, other errors are internal
+type SecretFunc func(ctx context.Context, partnerID string, keyID string, endpoint string) (string, error)
+
+// partnerCredentials are put to request context after signature is verified
+type partnerCredentials struct {
+	partnerID string
+	secret    string
+}
+
+// Signature is a middleware function that rejects requests without valid signature of partner key
+func Signature(cfg *SignatureConfig, secretFunc SecretFunc) func(http.Handler) http.Handler {
+	nonces := newNonceCache(2 * cfg.ClockSkew)
+	return func(handler http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			partnerID := r.Header.Get("X-Partner-ID")
+			keyID := r.Header.Get("X-Key-ID")
+			timestamp := r.Header.Get("X-Timestamp")
+			nonce := r.Header.Get("X-Nonce")
+			signature := r.Header.Get("X-Signature")
+
+			ts, err := strconv.ParseInt(timestamp, 10, 64)
+			if err != nil || len(nonce) < minNonceLen || len(nonce) > maxNonceLen || !strings.HasPrefix(signature, "sha256=") {
+				writeError(w, http.StatusUnauthorized)
+				return
+			}
+			skew := time.Since(time.Unix(ts, 0))
+			if skew > cfg.ClockSkew || skew < -cfg.ClockSkew {
+				writeError(w, http.StatusUnauthorized)
+				return
+			}
+
+			body, err := io.ReadAll(r.Body)
+			if err != nil {
+				writeError(w, http.StatusBadRequest)
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			var endpoint string
+			if route := mux.CurrentRoute(r); route != nil {
+				endpoint, _ = route.GetPathTemplate()
+			}
+			secret, err := secretFunc(r.Context(), partnerID, keyID, endpoint)
+			forbidden := err == ErrForbidden
+			if err == ErrUnknownKey {
+				writeError(w, http.StatusUnauthorized)
+				return
+			}
+			if err != nil && !forbidden {
+				writeError(w, http.StatusInternalServerError)
+				return
+			}
+
+			got, err := hex.DecodeString(strings.TrimPrefix(signature, "sha256="))
+			if err != nil || !hmac.Equal(got, Sign(secret, Canonical(r.Method, r.URL.RequestURI(), timestamp, nonce, body))) {
+				writeError(w, http.StatusUnauthorized)
+				return
+			}
+			if forbidden {
+				writeError(w, http.StatusForbidden)
+				return
+			}
+
+			if !nonces.add(partnerID + "\n" + nonce) {
+				writeError(w, http.StatusUnauthorized)
+				return
+			}
+
+			ctx := context.WithValue(r.Context(), partnerContextKey, &partnerCredentials{partnerID: partnerID, secret: secret})
+			r = r.WithContext(ctx)
+			handler.ServeHTTP(w, r)
+		})
+	}
+}
+
+// GetPartnerID returns id of partner which signed request
+func GetPartnerID(ctx context.Context) string {
+	if value, ok := ctx.Value(partnerContextKey).(*partnerCredentials); ok {
+		return value.partnerID
+	}
+	return ""
+}
+
+// GetSecret returns secret of partner key which signed request, response is signed with it
+func GetSecret(ctx context.Context) string {
+	if value, ok := ctx.Value(partnerContextKey).(*partnerCredentials); ok {
+		return value.secret
+	}
+	return ""
+}
+
+// Canonical returns string of request which is signed by client
+func Canonical(method string, path string, timestamp string, nonce string, body []byte) []byte {
+	bodyHash := sha256.Sum256(body)
+	return []byte(method + "\n" + path + "\n" + timestamp + "\n" + nonce + "\n" + hex.EncodeToString(bodyHash[:]))
+}
+
+// Sign returns HMAC-SHA256 of data with secret
+func Sign(secret string, data []byte) []byte {
+	h := NewSigner(secret)
+	h.Write(data)
+	return h.Sum(nil)
+}
+
+// NewSigner returns HMAC-SHA256 hash with secret, which signs streamed response while it is written
+func NewSigner(secret string) hash.Hash {
+	return hmac.New(sha256.New, []byte(secret))
+}
+
+// nonceCache remembers nonces for ttl to reject replayed requests
+type nonceCache struct {
+	mu     sync.Mutex
+	ttl    time.Duration
+	nonces map[string]time.Time
+	purged time.Time
+}
+
+func newNonceCache(ttl time.Duration) *nonceCache {
+	return &nonceCache{ttl: ttl, nonces: map[string]time.Time{}, purged: time.Now()}
+}
+
+// add returns false if nonce was already seen within ttl
+func (c *nonceCache) add(nonce string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(c.purged) > c.ttl {
+		for n, expires := range c.nonces {
+			if now.After(expires) {
+				delete(c.nonces, n)
+			}
+		}
+		c.purged = now
+	}
+
+	if expires, ok := c.nonces[nonce]; ok && now.Before(expires) {
+		return false
+	}
+	c.nonces[nonce] = now.Add(c.ttl)
+	return true
+}
@@ -0,0 +1,164 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+const testSecret = "partner-secret"
+
+// signedRequest returns request signed by key of testSecret, canonical path is RequestURI of target
+func signedRequest(method string, target string, body []byte, ts time.Time, nonce string) *http.Request {
+	r := httptest.NewRequest(method, target, bytes.NewReader(body))
+	timestamp := strconv.FormatInt(ts.Unix(), 10)
+	r.Header.Set("X-Partner-ID", "partner")
+	r.Header.Set("X-Key-ID", "key")
+	r.Header.Set("X-Timestamp", timestamp)
+	r.Header.Set("X-Nonce", nonce)
+	r.Header.Set("X-Signature", "sha256="+hex.EncodeToString(Sign(testSecret, Canonical(method, r.URL.RequestURI(), timestamp, nonce, body))))
+	return r
+}
+
+// newSignatureRouter returns router of one endpoint behind Signature, it records endpoint passed to secret func
+func newSignatureRouter(cfg *SignatureConfig, endpoint *string) *mux.Router {
+	secretFunc := func(ctx context.Context, partnerID string, keyID string, e string) (string, error) {
+		*endpoint = e
+		if partnerID == "limited" && keyID == "key" {
+			return testSecret, ErrForbidden
+		}
+		if partnerID == "broken" {
+			return "", errors.New("connection refused")
+		}
+		if partnerID != "partner" || keyID != "key" {
+			return "", ErrUnknownKey
+		}
+		return testSecret, nil
+	}
+	router := mux.NewRouter()
+	router.Use(Signature(cfg, secretFunc))
+	router.HandleFunc("/api/wallet/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if GetPartnerID(r.Context()) != "partner" || GetSecret(r.Context()) != testSecret {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	})
+	return router
+}
+
+func TestCanonical(t *testing.T) {
+	got := string(Canonical("POST", "/api/wallet/history?from=2021-01-01&limit=10", "1600000000", "nonce-123", []byte("{}")))
+	// sha256 of "{}"
+	want := "POST\n/api/wallet/history?from=2021-01-01&limit=10\n1600000000\nnonce-123\n44136fa355b3678a1146ad16f7e8649e94fb4fc21fe77e8310c060f61caaff8a"
+	if got != want {
+		t.Errorf("Canonical = %q, want %q", got, want)
+	}
+}
+
+func TestSignature(t *testing.T) {
+	var endpoint string
+	router := newSignatureRouter(&SignatureConfig{ClockSkew: time.Minute}, &endpoint)
+	now := time.Now()
+
+	wrongKey := signedRequest("POST", "/api/wallet/1", nil, now, "nonce-wrong-key")
+	wrongKey.Header.Set("X-Key-ID", "other")
+	queryChanged := signedRequest("GET", "/api/wallet/1?limit=10", nil, now, "nonce-query")
+	queryChanged.URL.RawQuery = "limit=1000"
+	queryChanged.RequestURI = queryChanged.URL.RequestURI()
+	bodyChanged := signedRequest("POST", "/api/wallet/1", []byte(`{"amount":1}`), now, "nonce-body")
+	bodyChanged.Body = httptest.NewRequest("POST", "/", bytes.NewReader([]byte(`{"amount":2}`))).Body
+	badTimestamp := signedRequest("POST", "/api/wallet/1", nil, now, "nonce-timestamp")
+	badTimestamp.Header.Set("X-Timestamp", "now")
+	forbidden := signedRequest("POST", "/api/wallet/1", nil, now, "nonce-forbidden")
+	forbidden.Header.Set("X-Partner-ID", "limited")
+	forbiddenUnsigned := signedRequest("POST", "/api/wallet/1", nil, now, "nonce-forbidden-unsigned")
+	forbiddenUnsigned.Header.Set("X-Partner-ID", "limited")
+	forbiddenUnsigned.Header.Set("X-Signature", "sha256=00")
+	lookupFailed := signedRequest("POST", "/api/wallet/1", nil, now, "nonce-lookup-failed")
+	lookupFailed.Header.Set("X-Partner-ID", "broken")
+	noPrefix := signedRequest("POST", "/api/wallet/1", nil, now, "nonce-prefix")
+	noPrefix.Header.Set("X-Signature", noPrefix.Header.Get("X-Signature")[len("sha256="):])
+
+	tests := []struct {
+		name string
+		r    *http.Request
+		code int
+	}{
+		{"valid", signedRequest("POST", "/api/wallet/1", []byte(`{"amount":1}`), now, "nonce-valid"), http.StatusOK},
+		{"valid with query", signedRequest("GET", "/api/wallet/1?from=2021-01-01&to=2021-02-01", nil, now, "nonce-valid-query"), http.StatusOK},
+		{"within clock skew", signedRequest("POST", "/api/wallet/1", nil, now.Add(-50*time.Second), "nonce-past"), http.StatusOK},
+		{"behind clock skew", signedRequest("POST", "/api/wallet/1", nil, now.Add(-2*time.Minute), "nonce-old"), http.StatusUnauthorized},
+		{"ahead of clock skew", signedRequest("POST", "/api/wallet/1", nil, now.Add(2*time.Minute), "nonce-future"), http.StatusUnauthorized},
+		{"query changed", queryChanged, http.StatusUnauthorized},
+		{"body changed", bodyChanged, http.StatusUnauthorized},
+		{"unknown key", wrongKey, http.StatusUnauthorized},
+		{"key lookup failed", lookupFailed, http.StatusInternalServerError},
+		{"forbidden endpoint", forbidden, http.StatusForbidden},
+		{"forbidden endpoint with bad signature", forbiddenUnsigned, http.StatusUnauthorized},
+		{"bad timestamp", badTimestamp, http.StatusUnauthorized},
+		{"signature without prefix", noPrefix, http.StatusUnauthorized},
+		{"short nonce", signedRequest("POST", "/api/wallet/1", nil, now, "short"), http.StatusUnauthorized},
+		{"unsigned", httptest.NewRequest("POST", "/api/wallet/1", nil), http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, tt.r)
+			if w.Code != tt.code {
+				t.Errorf("status = %d, want %d", w.Code, tt.code)
+			}
+		})
+	}
+
+	if endpoint != "/api/wallet/{id}" {
+		t.Errorf("endpoint passed to secret func = %q, want route template", endpoint)
+	}
+}
+
+func TestSignature_Replay(t *testing.T) {
+	var endpoint string
+	router := newSignatureRouter(&SignatureConfig{ClockSkew: time.Minute}, &endpoint)
+	now := time.Now()
+
+	for i, want := range []int{http.StatusOK, http.StatusUnauthorized} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, signedRequest("POST", "/api/wallet/1", []byte(`{}`), now, "nonce-replayed"))
+		if w.Code != want {
+			t.Errorf("request %d status = %d, want %d", i+1, w.Code, want)
+		}
+	}
+}
+
+func TestNonceCache(t *testing.T) {
+	ttl := 50 * time.Millisecond
+	cache := newNonceCache(ttl)
+
+	if !cache.add("a") || !cache.add("b") {
+		t.Fatal("add of new nonce = false, want true")
+	}
+	if cache.add("a") {
+		t.Error("add of seen nonce within ttl = true, want false")
+	}
+
+	time.Sleep(2 * ttl)
+	if !cache.add("a") {
+		t.Error("add of nonce after ttl = false, want true")
+	}
+	cache.mu.Lock()
+	_, ok := cache.nonces["b"]
+	size := len(cache.nonces)
+	cache.mu.Unlock()
+	if ok || size != 1 {
+		t.Errorf("cache after ttl has %d nonces, want expired nonce b evicted", size)
+	}
+	if cache.add("a") {
+		t.Error("add of nonce seen again after eviction = true, want false")
+	}
+}
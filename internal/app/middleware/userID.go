@@ -4,7 +4,7 @@ import (
 	"context"
 	"errors"
 	"net/http"
-	"strconv"
+	"strings"
 )
 
 var ErrNoUserID = errors.New("no user id")
@@ -19,14 +19,24 @@ func (c *contextKey) String() string {
 	return c.name
 }
 
-func UserID() func(http.Handler) http.Handler {
+// IDFunc returns account id by access token
+type IDFunc func(ctx context.Context, token string) (int64, error)
+
+// Authenticate puts account id from "Authorization: Bearer <token>" header to request context.
+// Requests without token pass through without id, requests with invalid token are rejected
+func Authenticate(idFunc IDFunc) func(http.Handler) http.Handler {
 	return func(handler http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			idParam := r.Header.Get("X-UserID")
-			if idParam != "" {
-				id, err := strconv.ParseInt(idParam, 10, 64)
+			header := r.Header.Get("Authorization")
+			if header != "" {
+				token := strings.TrimPrefix(header, "Bearer ")
+				if token == header {
+					writeError(w, http.StatusUnauthorized)
+					return
+				}
+				id, err := idFunc(r.Context(), token)
 				if err != nil {
-					http.Error(w, err.Error(), http.StatusBadRequest)
+					writeError(w, http.StatusUnauthorized)
 					return
 				}
 				ctx := context.WithValue(r.Context(), userIDContextKey, id)
@@ -0,0 +1,58 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAuthenticate(t *testing.T) {
+	idFunc := func(ctx context.Context, token string) (int64, error) {
+		if token == "valid" {
+			return 7, nil
+		}
+		return 0, errors.New("invalid token")
+	}
+
+	tests := []struct {
+		name   string
+		header string
+		code   int
+		id     int64
+		err    error
+	}{
+		{"valid token", "Bearer valid", http.StatusOK, 7, nil},
+		{"no header", "", http.StatusOK, 0, ErrNoUserID},
+		{"invalid token", "Bearer invalid", http.StatusUnauthorized, 0, nil},
+		{"not bearer", "Basic valid", http.StatusUnauthorized, 0, nil},
+		{"bearer without space", "Bearervalid", http.StatusUnauthorized, 0, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			called := false
+			handler := Authenticate(idFunc)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				called = true
+				id, err := GetUserID(r.Context())
+				if id != tt.id || err != tt.err {
+					t.Errorf("GetUserID = %d, %v, want %d, %v", id, err, tt.id, tt.err)
+				}
+			}))
+
+			r := httptest.NewRequest("GET", "/api/wallet/account", nil)
+			if tt.header != "" {
+				r.Header.Set("Authorization", tt.header)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			if w.Code != tt.code {
+				t.Errorf("status = %d, want %d", w.Code, tt.code)
+			}
+			if called != (tt.code == http.StatusOK) {
+				t.Errorf("handler called = %v, want %v", called, tt.code == http.StatusOK)
+			}
+		})
+	}
+}
@@ -1,28 +1,57 @@
 package app
 
 import (
-	"crypto/hmac"
-	"crypto/sha1"
+	"bytes"
+	"context"
 	"encoding/hex"
 	"encoding/json"
-	"fmt"
+	"errors"
+	"io"
 	"log"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/SYSTEMTerror/GoWallet/internal/app/middleware"
+	"github.com/SYSTEMTerror/GoWallet/internal/pkg/partner"
+	"github.com/SYSTEMTerror/GoWallet/internal/pkg/scheduler"
+	"github.com/SYSTEMTerror/GoWallet/internal/pkg/statement"
 	"github.com/SYSTEMTerror/GoWallet/internal/pkg/types"
 	"github.com/SYSTEMTerror/GoWallet/internal/pkg/wallet"
 	"github.com/gorilla/mux"
 )
 
+// PartnerService returns secrets of partner keys which sign requests, it is implemented by partner.Service.
+// Secret of partner not allowed to call endpoint is returned with partner.ErrForbidden, unknown key with partner.ErrNotFound
+type PartnerService interface {
+	Secret(ctx context.Context, partnerID string, keyID string, endpoint string) (string, error)
+}
+
+// SchedulerService manages recurring payments of users, it is implemented by scheduler.Service
+type SchedulerService interface {
+	Create(ctx context.Context, item *types.Schedule) (*types.Schedule, int, error)
+	GetSchedules(ctx context.Context, userID int64) ([]*types.Schedule, int, error)
+	GetSchedule(ctx context.Context, userID int64, id int64) (*types.Schedule, int, error)
+	Update(ctx context.Context, userID int64, id int64, item *types.ScheduleUpdate) (*types.Schedule, int, error)
+	Delete(ctx context.Context, userID int64, id int64) (int, error)
+	GetRuns(ctx context.Context, userID int64, id int64) ([]*types.ScheduleRun, int, error)
+}
+
+var (
+	_ PartnerService   = (*partner.Service)(nil)
+	_ SchedulerService = (*scheduler.Service)(nil)
+)
+
 type Server struct {
-	mux       *mux.Router
-	walletSvc *wallet.Service
-	secretKey string
+	mux          *mux.Router
+	walletSvc    *wallet.Service
+	partnerSvc   PartnerService
+	schedulerSvc SchedulerService
+	signature    *middleware.SignatureConfig
 }
 
-func NewServer(mux *mux.Router, walletSvc *wallet.Service, secretKey string) *Server {
-	return &Server{mux: mux, walletSvc: walletSvc, secretKey: secretKey}
+func NewServer(mux *mux.Router, walletSvc *wallet.Service, partnerSvc PartnerService, schedulerSvc SchedulerService, signature *middleware.SignatureConfig) *Server {
+	return &Server{mux: mux, walletSvc: walletSvc, partnerSvc: partnerSvc, schedulerSvc: schedulerSvc, signature: signature}
 }
 
 func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
@@ -33,49 +62,79 @@ func (s *Server) Init() {
 	s.mux.Use(middleware.Logger)
 	s.mux.Use(middleware.LoggersFuncs)
 
-	walletUserIDMd := middleware.UserID()
+	walletSignatureMd := middleware.Signature(s.signature, s.partnerSecret)
+	walletAuthenticateMd := middleware.Authenticate(s.walletSvc.IDByToken)
 
 	walletSubrouter := s.mux.PathPrefix("/api/wallet").Subrouter()
-	walletSubrouter.Use(walletUserIDMd)
+	walletSubrouter.Use(walletSignatureMd)
+	walletSubrouter.Use(walletAuthenticateMd)
 
 	walletSubrouter.HandleFunc("/exist/{phone}", s.handleExist).Methods("GET")
 	walletSubrouter.HandleFunc("/register", s.handleRegister).Methods("POST")
+	walletSubrouter.HandleFunc("/login", s.handleLogin).Methods("POST")
 	walletSubrouter.HandleFunc("/transaction", s.handleTransaction).Methods("POST")
+	walletSubrouter.HandleFunc("/transfer", s.handleTransfer).Methods("POST")
 	walletSubrouter.HandleFunc("/transactions", s.handleGetTransactionsPerMonth).Methods("GET")
+	walletSubrouter.HandleFunc("/statement", s.handleStatement).Methods("GET")
 	walletSubrouter.HandleFunc("/account", s.handleGetAccount).Methods("GET")
 	walletSubrouter.HandleFunc("/balance", s.handleBalance).Methods("GET")
 	walletSubrouter.HandleFunc("/identify", s.handleIdentify).Methods("POST")
+	walletSubrouter.HandleFunc("/identify", s.handleGetIdentification).Methods("GET")
+	walletSubrouter.HandleFunc("/holds", s.handleAuthorize).Methods("POST")
+	walletSubrouter.HandleFunc("/holds/{id}/capture", s.handleCapture).Methods("POST")
+	walletSubrouter.HandleFunc("/holds/{id}/void", s.handleVoid).Methods("POST")
+	walletSubrouter.HandleFunc("/wallets", s.handleGetWallets).Methods("GET")
+	walletSubrouter.HandleFunc("/wallets", s.handleOpenWallet).Methods("POST")
+	walletSubrouter.HandleFunc("/currencies", s.handleGetCurrencies).Methods("GET")
+	walletSubrouter.HandleFunc("/rates", s.handleGetRates).Methods("GET")
+	walletSubrouter.HandleFunc("/exchange", s.handleExchange).Methods("POST")
+	walletSubrouter.HandleFunc("/schedules", s.handleGetSchedules).Methods("GET")
+	walletSubrouter.HandleFunc("/schedules", s.handleCreateSchedule).Methods("POST")
+	walletSubrouter.HandleFunc("/schedules/{id}", s.handleGetSchedule).Methods("GET")
+	walletSubrouter.HandleFunc("/schedules/{id}", s.handleUpdateSchedule).Methods("PUT")
+	walletSubrouter.HandleFunc("/schedules/{id}", s.handleDeleteSchedule).Methods("DELETE")
+	walletSubrouter.HandleFunc("/schedules/{id}/runs", s.handleGetScheduleRuns).Methods("GET")
+
+	// operator routes are called by support tools, partners need operator permission or route in their endpoints
+	operatorSubrouter := s.mux.PathPrefix("/api/operator").Subrouter()
+	operatorSubrouter.Use(walletSignatureMd)
+
+	operatorSubrouter.HandleFunc("/transactions/{id}/reverse", s.handleReverse).Methods("POST")
+	operatorSubrouter.HandleFunc("/identifications/{id}/approve", s.handleApproveIdentification).Methods("POST")
+	operatorSubrouter.HandleFunc("/identifications/{id}/reject", s.handleRejectIdentification).Methods("POST")
+	operatorSubrouter.HandleFunc("/rates", s.handleLoadRates).Methods("POST")
+}
+
+// partnerSecret returns secret of partner key for signature middleware
+func (s *Server) partnerSecret(ctx context.Context, partnerID string, keyID string, endpoint string) (string, error) {
+	secret, err := s.partnerSvc.Secret(ctx, partnerID, keyID, endpoint)
+	switch err {
+	case partner.ErrNotFound:
+		return "", middleware.ErrUnknownKey
+	case partner.ErrForbidden:
+		return secret, middleware.ErrForbidden
+	}
+	return secret, err
 }
 
 func (s *Server) handleExist(w http.ResponseWriter, r *http.Request) {
 	loggers, err := middleware.GetLoggers(r.Context())
 	if err != nil {
 		log.Println("LOGGERS DON'T WORK!!!")
-		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		writeError(w, r, err, http.StatusInternalServerError)
 		return
 	}
 	loggers.InfoLogger.Println("handleExist started.")
 
-	if !verify(r, "", s.secretKey) {
-		loggers.ErrorLogger.Println("handleExist verify error:", err)
-		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
-		return
-	}
-
 	phone := mux.Vars(r)["phone"]
-	exist, acc, statusCode, err := s.walletSvc.Exist(r.Context(), phone)
+	exist, _, statusCode, err := s.walletSvc.Exist(r.Context(), phone)
 	if err != nil {
 		loggers.ErrorLogger.Println("handleExist s.walletSvc.Exist error:", err)
-		http.Error(w, http.StatusText(statusCode), statusCode)
+		writeError(w, r, err, statusCode)
 		return
 	}
-	var mes interface{}
-	if exist {
-		mes = acc
-	} else {
-		mes = "Account not exist"
-	}
-	err = jsoner(w, mes, statusCode, s.secretKey)
+
+	err = jsoner(w, types.ExistView{Exist: exist}, statusCode, middleware.GetSecret(r.Context()))
 	if err != nil {
 		loggers.ErrorLogger.Println("handleExist jsoner error:", err)
 		return
@@ -87,7 +146,7 @@ func (s *Server) handleRegister(w http.ResponseWriter, r *http.Request) {
 	loggers, err := middleware.GetLoggers(r.Context())
 	if err != nil {
 		log.Println("LOGGERS DON'T WORK!!!")
-		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		writeError(w, r, err, http.StatusInternalServerError)
 		return
 	}
 	loggers.InfoLogger.Println("handleRegister started.")
@@ -96,24 +155,18 @@ func (s *Server) handleRegister(w http.ResponseWriter, r *http.Request) {
 	err = json.NewDecoder(r.Body).Decode(&item)
 	if err != nil {
 		loggers.ErrorLogger.Println("handleRegister json.NewDecoder error:", err)
-		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
-		return
-	}
-
-	if !verify(r, fmt.Sprintf("%s", item), s.secretKey) {
-		loggers.ErrorLogger.Println("handleExist verify error:", err)
-		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		writeError(w, r, err, http.StatusBadRequest)
 		return
 	}
 
 	acc, statusCode, err := s.walletSvc.Register(r.Context(), item)
 	if err != nil {
 		loggers.ErrorLogger.Println("handleRegister s.walletSvc.Register error:", err)
-		http.Error(w, http.StatusText(statusCode), statusCode)
+		writeError(w, r, err, statusCode)
 		return
 	}
 
-	err = jsoner(w, acc, statusCode, s.secretKey)
+	err = jsoner(w, types.NewAccountView(acc), statusCode, middleware.GetSecret(r.Context()))
 	if err != nil {
 		loggers.ErrorLogger.Println("handleRegisterCustomer jsoner error:", err)
 		return
@@ -121,11 +174,43 @@ func (s *Server) handleRegister(w http.ResponseWriter, r *http.Request) {
 	loggers.InfoLogger.Println("handleRegisterCustomer finished with any error.")
 }
 
+func (s *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
+	loggers, err := middleware.GetLoggers(r.Context())
+	if err != nil {
+		log.Println("LOGGERS DON'T WORK!!!")
+		writeError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	loggers.InfoLogger.Println("handleLogin started.")
+
+	var item *types.LoginInfo
+	err = json.NewDecoder(r.Body).Decode(&item)
+	if err != nil {
+		loggers.ErrorLogger.Println("handleLogin json.NewDecoder error:", err)
+		writeError(w, r, err, http.StatusBadRequest)
+		return
+	}
+
+	token, statusCode, err := s.walletSvc.Login(r.Context(), item)
+	if err != nil {
+		loggers.ErrorLogger.Println("handleLogin s.walletSvc.Login error:", err)
+		writeError(w, r, err, statusCode)
+		return
+	}
+
+	err = jsoner(w, token, statusCode, middleware.GetSecret(r.Context()))
+	if err != nil {
+		loggers.ErrorLogger.Println("handleLogin jsoner error:", err)
+		return
+	}
+	loggers.InfoLogger.Println("handleLogin finished with any error.")
+}
+
 func (s *Server) handleTransaction(w http.ResponseWriter, r *http.Request) {
 	loggers, err := middleware.GetLoggers(r.Context())
 	if err != nil {
 		log.Println("LOGGERS DON'T WORK!!!")
-		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		writeError(w, r, err, http.StatusInternalServerError)
 		return
 	}
 	loggers.InfoLogger.Println("handleTransaction started.")
@@ -134,37 +219,42 @@ func (s *Server) handleTransaction(w http.ResponseWriter, r *http.Request) {
 	err = json.NewDecoder(r.Body).Decode(&item)
 	if err != nil {
 		loggers.ErrorLogger.Println("handleTransaction json.NewDecoder error:", err)
-		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
-		return
-	}
-
-	if !verify(r, fmt.Sprintf("%d", item), s.secretKey) {
-		loggers.ErrorLogger.Println("handleExist verify error:", err)
-		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		writeError(w, r, err, http.StatusBadRequest)
 		return
 	}
 
 	id, err := middleware.GetUserID(r.Context())
 	if err != nil {
 		loggers.ErrorLogger.Println("handleIdentify middleware.Authentication error:", err)
-		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		writeError(w, r, err, http.StatusUnauthorized)
 		return
 	}
 
-	if id != item.AccID {
-		loggers.ErrorLogger.Println("handleTransaction id != item.AccID")
-		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+	owner, statusCode, err := s.walletSvc.IsOwner(r.Context(), id, item.AccID)
+	if err != nil {
+		loggers.ErrorLogger.Println("handleTransaction s.walletSvc.IsOwner error:", err)
+		writeError(w, r, err, statusCode)
+		return
+	}
+	if !owner {
+		loggers.ErrorLogger.Println("handleTransaction item.AccID is not wallet of user")
+		writeError(w, r, wallet.ErrNotFound, http.StatusNotFound)
 		return
 	}
 
-	transaction, statusCode, err := s.walletSvc.Transaction(r.Context(), item)
+	var transaction *types.Transaction
+	if key := r.Header.Get("Idempotency-Key"); key != "" {
+		transaction, statusCode, err = s.walletSvc.IdempotentTransaction(r.Context(), key, item)
+	} else {
+		transaction, statusCode, err = s.walletSvc.Transaction(r.Context(), item)
+	}
 	if err != nil {
 		loggers.ErrorLogger.Println("handleTransaction s.walletSvc.Transaction error:", err)
-		http.Error(w, http.StatusText(statusCode), statusCode)
+		writeError(w, r, err, statusCode)
 		return
 	}
 
-	err = jsoner(w, transaction, statusCode, s.secretKey)
+	err = jsoner(w, transaction, statusCode, middleware.GetSecret(r.Context()))
 	if err != nil {
 		loggers.ErrorLogger.Println("handleTransaction jsoner error:", err)
 		return
@@ -172,36 +262,100 @@ func (s *Server) handleTransaction(w http.ResponseWriter, r *http.Request) {
 	loggers.InfoLogger.Println("handleTransaction finished with any error.")
 }
 
-func (s *Server) handleGetTransactionsPerMonth(w http.ResponseWriter, r *http.Request) {
+func (s *Server) handleTransfer(w http.ResponseWriter, r *http.Request) {
 	loggers, err := middleware.GetLoggers(r.Context())
 	if err != nil {
 		log.Println("LOGGERS DON'T WORK!!!")
-		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		writeError(w, r, err, http.StatusInternalServerError)
 		return
 	}
-	loggers.InfoLogger.Println("handleGetTransactionsPerMonth started.")
+	loggers.InfoLogger.Println("handleTransfer started.")
 
-	if !verify(r, "", s.secretKey) {
-		loggers.ErrorLogger.Println("handleExist verify error:", err)
-		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	var item *types.Transfer
+	err = json.NewDecoder(r.Body).Decode(&item)
+	if err != nil {
+		loggers.ErrorLogger.Println("handleTransfer json.NewDecoder error:", err)
+		writeError(w, r, err, http.StatusBadRequest)
+		return
+	}
+
+	id, err := middleware.GetUserID(r.Context())
+	if err != nil {
+		loggers.ErrorLogger.Println("handleTransfer middleware.GetUserID error:", err)
+		writeError(w, r, err, http.StatusUnauthorized)
+		return
+	}
+
+	owner, statusCode, err := s.walletSvc.IsOwner(r.Context(), id, item.AccID)
+	if err != nil {
+		loggers.ErrorLogger.Println("handleTransfer s.walletSvc.IsOwner error:", err)
+		writeError(w, r, err, statusCode)
+		return
+	}
+	if !owner {
+		loggers.ErrorLogger.Println("handleTransfer item.AccID is not wallet of user")
+		writeError(w, r, wallet.ErrNotFound, http.StatusNotFound)
+		return
+	}
+
+	var transfer *types.TransferResult
+	if key := r.Header.Get("Idempotency-Key"); key != "" {
+		transfer, statusCode, err = s.walletSvc.IdempotentTransfer(r.Context(), key, item)
+	} else {
+		transfer, statusCode, err = s.walletSvc.Transfer(r.Context(), item)
+	}
+	if err != nil {
+		loggers.ErrorLogger.Println("handleTransfer s.walletSvc.Transfer error:", err)
+		writeError(w, r, err, statusCode)
+		return
+	}
+
+	err = jsoner(w, transfer, statusCode, middleware.GetSecret(r.Context()))
+	if err != nil {
+		loggers.ErrorLogger.Println("handleTransfer jsoner error:", err)
+		return
+	}
+	loggers.InfoLogger.Println("handleTransfer finished with any error.")
+}
+
+func (s *Server) handleGetTransactionsPerMonth(w http.ResponseWriter, r *http.Request) {
+	loggers, err := middleware.GetLoggers(r.Context())
+	if err != nil {
+		log.Println("LOGGERS DON'T WORK!!!")
+		writeError(w, r, err, http.StatusInternalServerError)
 		return
 	}
+	loggers.InfoLogger.Println("handleGetTransactionsPerMonth started.")
 
 	id, err := middleware.GetUserID(r.Context())
 	if err != nil {
 		loggers.ErrorLogger.Println("handleIdentify middleware.Authentication error:", err)
-		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		writeError(w, r, err, http.StatusUnauthorized)
+		return
+	}
+
+	id, statusCode, err := s.walletID(r, id)
+	if err != nil {
+		loggers.ErrorLogger.Println("handleGetTransactionsPerMonth s.walletID error:", err)
+		writeError(w, r, err, statusCode)
+		return
+	}
+
+	filter, statusCode, err := s.walletSvc.ParseTransactionFilter(r.URL.Query())
+	if err != nil {
+		loggers.ErrorLogger.Println("handleGetTransactionsPerMonth s.walletSvc.ParseTransactionFilter error:", err)
+		writeError(w, r, err, statusCode)
 		return
 	}
 
-	transactions, sum, count, statusCode, err := s.walletSvc.GetTransactionsPerMonth(r.Context(), id)
+	page, statusCode, err := s.walletSvc.GetTransactionsPage(r.Context(), id, filter)
 	if err != nil {
-		loggers.ErrorLogger.Println("handleGetTransactionsPerMonth s.walletSvc.GetTransactionsPerMonth error:", err)
-		http.Error(w, http.StatusText(statusCode), statusCode)
+		loggers.ErrorLogger.Println("handleGetTransactionsPerMonth s.walletSvc.GetTransactionsPage error:", err)
+		writeError(w, r, err, statusCode)
 		return
 	}
 
-	err = jsoner(w, types.TransactionsPerMonth{Sum: sum, Count: count, Transactions: transactions}, statusCode, s.secretKey)
+	err = jsoner(w, page, statusCode, middleware.GetSecret(r.Context()))
 	if err != nil {
 		loggers.ErrorLogger.Println("handleGetTransactionsPerMonth jsoner error:", err)
 		return
@@ -209,36 +363,124 @@ func (s *Server) handleGetTransactionsPerMonth(w http.ResponseWriter, r *http.Re
 	loggers.InfoLogger.Println("handleGetTransactionsPerMonth finished with any error.")
 }
 
-func (s *Server) handleGetAccount(w http.ResponseWriter, r *http.Request) {
+func (s *Server) handleStatement(w http.ResponseWriter, r *http.Request) {
 	loggers, err := middleware.GetLoggers(r.Context())
 	if err != nil {
 		log.Println("LOGGERS DON'T WORK!!!")
-		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		writeError(w, r, err, http.StatusInternalServerError)
 		return
 	}
-	loggers.InfoLogger.Println("handleGetAccount started.")
+	loggers.InfoLogger.Println("handleStatement started.")
 
-	if !verify(r, "", s.secretKey) {
-		loggers.ErrorLogger.Println("handleExist verify error:", err)
-		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	id, err := middleware.GetUserID(r.Context())
+	if err != nil {
+		loggers.ErrorLogger.Println("handleStatement middleware.GetUserID error:", err)
+		writeError(w, r, err, http.StatusUnauthorized)
+		return
+	}
+
+	id, statusCode, err := s.walletID(r, id)
+	if err != nil {
+		loggers.ErrorLogger.Println("handleStatement s.walletID error:", err)
+		writeError(w, r, err, statusCode)
+		return
+	}
+
+	query := r.URL.Query()
+	from, to := s.walletSvc.CurrentMonth()
+	if query.Get("from") != "" || query.Get("to") != "" {
+		from, to, statusCode, err = s.walletSvc.ParsePeriod(query.Get("from"), query.Get("to"))
+		if err != nil {
+			loggers.ErrorLogger.Println("handleStatement s.walletSvc.ParsePeriod error:", err)
+			writeError(w, r, err, statusCode)
+			return
+		}
+	}
+
+	format, statusCode, err := statement.Negotiate(query.Get("format"), r.Header.Get("Accept"))
+	if err != nil {
+		loggers.ErrorLogger.Println("handleStatement statement.Negotiate error:", err)
+		writeError(w, r, err, statusCode)
+		return
+	}
+
+	if statement.Buffered(format) {
+		st, lines, statusCode, err := s.walletSvc.GetStatement(r.Context(), id, from, to)
+		if err != nil {
+			loggers.ErrorLogger.Println("handleStatement s.walletSvc.GetStatement error:", err)
+			writeError(w, r, err, statusCode)
+			return
+		}
+
+		var buf bytes.Buffer
+		err = statement.Export(format, &buf, &statement.Document{Statement: st, Lines: lines, Created: time.Now().In(from.Location())})
+		if err != nil {
+			loggers.ErrorLogger.Println("handleStatement statement.Export error:", err)
+			writeError(w, r, err, http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", statement.ContentType(format))
+		w.Header().Set("X-Signature", "sha256="+hex.EncodeToString(middleware.Sign(middleware.GetSecret(r.Context()), buf.Bytes())))
+		w.Write(buf.Bytes())
+		loggers.InfoLogger.Println("handleStatement finished with any error.")
+		return
+	}
+
+	// response is streamed, so its signature is sent in trailer
+	signer := middleware.NewSigner(middleware.GetSecret(r.Context()))
+	body := &trackingWriter{w: io.MultiWriter(w, signer)}
+	writer, err := statement.NewWriter(format, body)
+	if err != nil {
+		loggers.ErrorLogger.Println("handleStatement statement.NewWriter error:", err)
+		writeError(w, r, err, http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", statement.ContentType(format))
+	w.Header().Set("Trailer", "X-Signature")
+
+	statusCode, err = s.walletSvc.Statement(r.Context(), id, from, to, writer)
+	if err != nil {
+		loggers.ErrorLogger.Println("handleStatement s.walletSvc.Statement error:", err)
+		if !body.written {
+			writeError(w, r, err, statusCode)
+		}
+		return
+	}
+	w.Header().Set("X-Signature", "sha256="+hex.EncodeToString(signer.Sum(nil)))
+	loggers.InfoLogger.Println("handleStatement finished with any error.")
+}
+
+func (s *Server) handleGetAccount(w http.ResponseWriter, r *http.Request) {
+	loggers, err := middleware.GetLoggers(r.Context())
+	if err != nil {
+		log.Println("LOGGERS DON'T WORK!!!")
+		writeError(w, r, err, http.StatusInternalServerError)
 		return
 	}
+	loggers.InfoLogger.Println("handleGetAccount started.")
 
 	id, err := middleware.GetUserID(r.Context())
 	if err != nil {
 		loggers.ErrorLogger.Println("handleIdentify middleware.Authentication error:", err)
-		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		writeError(w, r, err, http.StatusUnauthorized)
+		return
+	}
+
+	id, statusCode, err := s.walletID(r, id)
+	if err != nil {
+		loggers.ErrorLogger.Println("handleGetAccount s.walletID error:", err)
+		writeError(w, r, err, statusCode)
 		return
 	}
 
 	account, statusCode, err := s.walletSvc.GetAccountByID(r.Context(), id)
 	if err != nil {
 		loggers.ErrorLogger.Println("handleGetAccount s.walletSvc.GetAccountByID error:", err)
-		http.Error(w, http.StatusText(statusCode), statusCode)
+		writeError(w, r, err, statusCode)
 		return
 	}
 
-	err = jsoner(w, account, statusCode, s.secretKey)
+	err = jsoner(w, types.NewAccountView(account), statusCode, middleware.GetSecret(r.Context()))
 	if err != nil {
 		loggers.ErrorLogger.Println("handleGetAccount jsoner error:", err)
 		return
@@ -250,32 +492,33 @@ func (s *Server) handleBalance(w http.ResponseWriter, r *http.Request) {
 	loggers, err := middleware.GetLoggers(r.Context())
 	if err != nil {
 		log.Println("LOGGERS DON'T WORK!!!")
-		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		writeError(w, r, err, http.StatusInternalServerError)
 		return
 	}
 	loggers.InfoLogger.Println("handleBalance started.")
 
-	if !verify(r, "", s.secretKey) {
-		loggers.ErrorLogger.Println("handleExist verify error:", err)
-		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	id, err := middleware.GetUserID(r.Context())
+	if err != nil {
+		loggers.ErrorLogger.Println("handleIdentify middleware.Authentication error:", err)
+		writeError(w, r, err, http.StatusUnauthorized)
 		return
 	}
 
-	id, err := middleware.GetUserID(r.Context())
+	id, statusCode, err := s.walletID(r, id)
 	if err != nil {
-		loggers.ErrorLogger.Println("handleIdentify middleware.Authentication error:", err)
-		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		loggers.ErrorLogger.Println("handleBalance s.walletID error:", err)
+		writeError(w, r, err, statusCode)
 		return
 	}
 
-	acc, statusCode, err := s.walletSvc.GetAccountByID(r.Context(), id)
+	balance, statusCode, err := s.walletSvc.GetBalance(r.Context(), id)
 	if err != nil {
-		loggers.ErrorLogger.Println("handleBalance s.walletSvc.GetAccountByID error:", err)
-		http.Error(w, http.StatusText(statusCode), statusCode)
+		loggers.ErrorLogger.Println("handleBalance s.walletSvc.GetBalance error:", err)
+		writeError(w, r, err, statusCode)
 		return
 	}
 
-	err = jsoner(w, acc.Balance, statusCode, s.secretKey)
+	err = jsoner(w, balance, statusCode, middleware.GetSecret(r.Context()))
 	if err != nil {
 		loggers.ErrorLogger.Println("handleBalance jsoner error:", err)
 		return
@@ -287,32 +530,34 @@ func (s *Server) handleIdentify(w http.ResponseWriter, r *http.Request) {
 	loggers, err := middleware.GetLoggers(r.Context())
 	if err != nil {
 		log.Println("LOGGERS DON'T WORK!!!")
-		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		writeError(w, r, err, http.StatusInternalServerError)
 		return
 	}
 	loggers.InfoLogger.Println("handleIdentify started.")
 
-	if !verify(r, "", s.secretKey) {
-		loggers.ErrorLogger.Println("handleExist verify error:", err)
-		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	var item *types.Identification
+	err = json.NewDecoder(r.Body).Decode(&item)
+	if err != nil {
+		loggers.ErrorLogger.Println("handleIdentify json.NewDecoder error:", err)
+		writeError(w, r, err, http.StatusBadRequest)
 		return
 	}
 
 	id, err := middleware.GetUserID(r.Context())
 	if err != nil {
 		loggers.ErrorLogger.Println("handleIdentify middleware.Authentication error:", err)
-		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		writeError(w, r, err, http.StatusUnauthorized)
 		return
 	}
 
-	statusCode, err := s.walletSvc.Identify(r.Context(), id)
+	identification, statusCode, err := s.walletSvc.SubmitIdentification(r.Context(), id, item)
 	if err != nil {
-		loggers.ErrorLogger.Println("handleIdentify s.walletSvc.Identify error:", err)
-		http.Error(w, http.StatusText(statusCode), statusCode)
+		loggers.ErrorLogger.Println("handleIdentify s.walletSvc.SubmitIdentification error:", err)
+		writeError(w, r, err, statusCode)
 		return
 	}
 
-	err = jsoner(w, "Account was identified", statusCode, s.secretKey)
+	err = jsoner(w, identification, statusCode, middleware.GetSecret(r.Context()))
 	if err != nil {
 		loggers.ErrorLogger.Println("handleIdentify jsoner error:", err)
 		return
@@ -320,38 +565,783 @@ func (s *Server) handleIdentify(w http.ResponseWriter, r *http.Request) {
 	loggers.InfoLogger.Println("handleIdentify finished with any error.")
 }
 
-//function jsoner marshal interfaces to json and write to response writer
-func jsoner(w http.ResponseWriter, v interface{}, code int, secretKey string) error {
-	data, err := json.Marshal(v)
+func (s *Server) handleGetIdentification(w http.ResponseWriter, r *http.Request) {
+	loggers, err := middleware.GetLoggers(r.Context())
 	if err != nil {
-		log.Println("jsoner json.Marshal error:", err)
-		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
-		return err
+		log.Println("LOGGERS DON'T WORK!!!")
+		writeError(w, r, err, http.StatusInternalServerError)
+		return
 	}
+	loggers.InfoLogger.Println("handleGetIdentification started.")
 
-	w.Header().Set("X-Digest", hasher(string(data), secretKey))
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(code)
-	_, err = w.Write(data)
+	id, err := middleware.GetUserID(r.Context())
 	if err != nil {
-		log.Println("jsoner w.Write error:", err)
-		return err
+		loggers.ErrorLogger.Println("handleGetIdentification middleware.Authentication error:", err)
+		writeError(w, r, err, http.StatusUnauthorized)
+		return
 	}
-	return nil
+
+	state, statusCode, err := s.walletSvc.GetIdentificationState(r.Context(), id)
+	if err != nil {
+		loggers.ErrorLogger.Println("handleGetIdentification s.walletSvc.GetIdentificationState error:", err)
+		writeError(w, r, err, statusCode)
+		return
+	}
+
+	err = jsoner(w, state, statusCode, middleware.GetSecret(r.Context()))
+	if err != nil {
+		loggers.ErrorLogger.Println("handleGetIdentification jsoner error:", err)
+		return
+	}
+	loggers.InfoLogger.Println("handleGetIdentification finished with any error.")
+}
+
+func (s *Server) handleApproveIdentification(w http.ResponseWriter, r *http.Request) {
+	loggers, err := middleware.GetLoggers(r.Context())
+	if err != nil {
+		log.Println("LOGGERS DON'T WORK!!!")
+		writeError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	loggers.InfoLogger.Println("handleApproveIdentification started by partner", middleware.GetPartnerID(r.Context()))
+
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		loggers.ErrorLogger.Println("handleApproveIdentification strconv.ParseInt error:", err)
+		writeError(w, r, err, http.StatusBadRequest)
+		return
+	}
+
+	identification, statusCode, err := s.walletSvc.ApproveIdentification(r.Context(), middleware.GetPartnerID(r.Context()), id)
+	if err != nil {
+		loggers.ErrorLogger.Println("handleApproveIdentification s.walletSvc.ApproveIdentification error:", err)
+		writeError(w, r, err, statusCode)
+		return
+	}
+
+	err = jsoner(w, identification, statusCode, middleware.GetSecret(r.Context()))
+	if err != nil {
+		loggers.ErrorLogger.Println("handleApproveIdentification jsoner error:", err)
+		return
+	}
+	loggers.InfoLogger.Println("handleApproveIdentification finished with any error.")
+}
+
+func (s *Server) handleRejectIdentification(w http.ResponseWriter, r *http.Request) {
+	loggers, err := middleware.GetLoggers(r.Context())
+	if err != nil {
+		log.Println("LOGGERS DON'T WORK!!!")
+		writeError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	loggers.InfoLogger.Println("handleRejectIdentification started by partner", middleware.GetPartnerID(r.Context()))
+
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		loggers.ErrorLogger.Println("handleRejectIdentification strconv.ParseInt error:", err)
+		writeError(w, r, err, http.StatusBadRequest)
+		return
+	}
+
+	var item *types.Rejection
+	err = json.NewDecoder(r.Body).Decode(&item)
+	if err != nil {
+		loggers.ErrorLogger.Println("handleRejectIdentification json.NewDecoder error:", err)
+		writeError(w, r, err, http.StatusBadRequest)
+		return
+	}
+
+	identification, statusCode, err := s.walletSvc.RejectIdentification(r.Context(), middleware.GetPartnerID(r.Context()), id, item.Reason)
+	if err != nil {
+		loggers.ErrorLogger.Println("handleRejectIdentification s.walletSvc.RejectIdentification error:", err)
+		writeError(w, r, err, statusCode)
+		return
+	}
+
+	err = jsoner(w, identification, statusCode, middleware.GetSecret(r.Context()))
+	if err != nil {
+		loggers.ErrorLogger.Println("handleRejectIdentification jsoner error:", err)
+		return
+	}
+	loggers.InfoLogger.Println("handleRejectIdentification finished with any error.")
 }
 
-//fuction hasher create hmac-sha1 hash from string and secret key
-func hasher(s string, secret string) string {
-	h := hmac.New(sha1.New, []byte(secret))
-	h.Write([]byte(s))
-	return "sha1=" + hex.EncodeToString(h.Sum(nil))
+func (s *Server) handleAuthorize(w http.ResponseWriter, r *http.Request) {
+	loggers, err := middleware.GetLoggers(r.Context())
+	if err != nil {
+		log.Println("LOGGERS DON'T WORK!!!")
+		writeError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	loggers.InfoLogger.Println("handleAuthorize started.")
+
+	var item *types.Hold
+	err = json.NewDecoder(r.Body).Decode(&item)
+	if err != nil {
+		loggers.ErrorLogger.Println("handleAuthorize json.NewDecoder error:", err)
+		writeError(w, r, err, http.StatusBadRequest)
+		return
+	}
+
+	id, err := middleware.GetUserID(r.Context())
+	if err != nil {
+		loggers.ErrorLogger.Println("handleAuthorize middleware.GetUserID error:", err)
+		writeError(w, r, err, http.StatusUnauthorized)
+		return
+	}
+
+	owner, statusCode, err := s.walletSvc.IsOwner(r.Context(), id, item.AccID)
+	if err != nil {
+		loggers.ErrorLogger.Println("handleAuthorize s.walletSvc.IsOwner error:", err)
+		writeError(w, r, err, statusCode)
+		return
+	}
+	if !owner {
+		loggers.ErrorLogger.Println("handleAuthorize item.AccID is not wallet of user")
+		writeError(w, r, wallet.ErrNotFound, http.StatusNotFound)
+		return
+	}
+
+	hold, statusCode, err := s.walletSvc.Authorize(r.Context(), middleware.GetPartnerID(r.Context()), item)
+	if err != nil {
+		loggers.ErrorLogger.Println("handleAuthorize s.walletSvc.Authorize error:", err)
+		writeError(w, r, err, statusCode)
+		return
+	}
+
+	err = jsoner(w, hold, statusCode, middleware.GetSecret(r.Context()))
+	if err != nil {
+		loggers.ErrorLogger.Println("handleAuthorize jsoner error:", err)
+		return
+	}
+	loggers.InfoLogger.Println("handleAuthorize finished with any error.")
 }
 
-//function verify gets hmac-sha1 hash from request header and compare it with request body
-func verify(r *http.Request, s string, secret string) bool {
-	h := r.Header.Get("X-Digest")
-	if h == "" {
-		return false
+func (s *Server) handleCapture(w http.ResponseWriter, r *http.Request) {
+	loggers, err := middleware.GetLoggers(r.Context())
+	if err != nil {
+		log.Println("LOGGERS DON'T WORK!!!")
+		writeError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	loggers.InfoLogger.Println("handleCapture started.")
+
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		loggers.ErrorLogger.Println("handleCapture strconv.ParseInt error:", err)
+		writeError(w, r, err, http.StatusBadRequest)
+		return
 	}
-	return h == hasher(s, secret)
+
+	var item *types.Capture
+	err = json.NewDecoder(r.Body).Decode(&item)
+	if err != nil {
+		loggers.ErrorLogger.Println("handleCapture json.NewDecoder error:", err)
+		writeError(w, r, err, http.StatusBadRequest)
+		return
+	}
+
+	transaction, statusCode, err := s.walletSvc.Capture(r.Context(), middleware.GetPartnerID(r.Context()), id, item.Amount)
+	if err != nil {
+		loggers.ErrorLogger.Println("handleCapture s.walletSvc.Capture error:", err)
+		writeError(w, r, err, statusCode)
+		return
+	}
+
+	err = jsoner(w, transaction, statusCode, middleware.GetSecret(r.Context()))
+	if err != nil {
+		loggers.ErrorLogger.Println("handleCapture jsoner error:", err)
+		return
+	}
+	loggers.InfoLogger.Println("handleCapture finished with any error.")
+}
+
+func (s *Server) handleVoid(w http.ResponseWriter, r *http.Request) {
+	loggers, err := middleware.GetLoggers(r.Context())
+	if err != nil {
+		log.Println("LOGGERS DON'T WORK!!!")
+		writeError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	loggers.InfoLogger.Println("handleVoid started.")
+
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		loggers.ErrorLogger.Println("handleVoid strconv.ParseInt error:", err)
+		writeError(w, r, err, http.StatusBadRequest)
+		return
+	}
+
+	hold, statusCode, err := s.walletSvc.Void(r.Context(), middleware.GetPartnerID(r.Context()), id)
+	if err != nil {
+		loggers.ErrorLogger.Println("handleVoid s.walletSvc.Void error:", err)
+		writeError(w, r, err, statusCode)
+		return
+	}
+
+	err = jsoner(w, hold, statusCode, middleware.GetSecret(r.Context()))
+	if err != nil {
+		loggers.ErrorLogger.Println("handleVoid jsoner error:", err)
+		return
+	}
+	loggers.InfoLogger.Println("handleVoid finished with any error.")
+}
+
+func (s *Server) handleGetWallets(w http.ResponseWriter, r *http.Request) {
+	loggers, err := middleware.GetLoggers(r.Context())
+	if err != nil {
+		log.Println("LOGGERS DON'T WORK!!!")
+		writeError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	loggers.InfoLogger.Println("handleGetWallets started.")
+
+	id, err := middleware.GetUserID(r.Context())
+	if err != nil {
+		loggers.ErrorLogger.Println("handleGetWallets middleware.GetUserID error:", err)
+		writeError(w, r, err, http.StatusUnauthorized)
+		return
+	}
+
+	wallets, statusCode, err := s.walletSvc.GetWallets(r.Context(), id)
+	if err != nil {
+		loggers.ErrorLogger.Println("handleGetWallets s.walletSvc.GetWallets error:", err)
+		writeError(w, r, err, statusCode)
+		return
+	}
+
+	views := make([]*types.AccountView, 0, len(wallets))
+	for _, acc := range wallets {
+		views = append(views, types.NewAccountView(acc))
+	}
+
+	err = jsoner(w, views, statusCode, middleware.GetSecret(r.Context()))
+	if err != nil {
+		loggers.ErrorLogger.Println("handleGetWallets jsoner error:", err)
+		return
+	}
+	loggers.InfoLogger.Println("handleGetWallets finished with any error.")
+}
+
+func (s *Server) handleOpenWallet(w http.ResponseWriter, r *http.Request) {
+	loggers, err := middleware.GetLoggers(r.Context())
+	if err != nil {
+		log.Println("LOGGERS DON'T WORK!!!")
+		writeError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	loggers.InfoLogger.Println("handleOpenWallet started.")
+
+	var item *types.WalletInfo
+	err = json.NewDecoder(r.Body).Decode(&item)
+	if err != nil {
+		loggers.ErrorLogger.Println("handleOpenWallet json.NewDecoder error:", err)
+		writeError(w, r, err, http.StatusBadRequest)
+		return
+	}
+
+	id, err := middleware.GetUserID(r.Context())
+	if err != nil {
+		loggers.ErrorLogger.Println("handleOpenWallet middleware.GetUserID error:", err)
+		writeError(w, r, err, http.StatusUnauthorized)
+		return
+	}
+
+	acc, statusCode, err := s.walletSvc.OpenWallet(r.Context(), id, item.Currency)
+	if err != nil {
+		loggers.ErrorLogger.Println("handleOpenWallet s.walletSvc.OpenWallet error:", err)
+		writeError(w, r, err, statusCode)
+		return
+	}
+
+	err = jsoner(w, types.NewAccountView(acc), statusCode, middleware.GetSecret(r.Context()))
+	if err != nil {
+		loggers.ErrorLogger.Println("handleOpenWallet jsoner error:", err)
+		return
+	}
+	loggers.InfoLogger.Println("handleOpenWallet finished with any error.")
+}
+
+func (s *Server) handleGetCurrencies(w http.ResponseWriter, r *http.Request) {
+	loggers, err := middleware.GetLoggers(r.Context())
+	if err != nil {
+		log.Println("LOGGERS DON'T WORK!!!")
+		writeError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	loggers.InfoLogger.Println("handleGetCurrencies started.")
+
+	currencies, statusCode, err := s.walletSvc.GetCurrencies(r.Context())
+	if err != nil {
+		loggers.ErrorLogger.Println("handleGetCurrencies s.walletSvc.GetCurrencies error:", err)
+		writeError(w, r, err, statusCode)
+		return
+	}
+
+	err = jsoner(w, currencies, statusCode, middleware.GetSecret(r.Context()))
+	if err != nil {
+		loggers.ErrorLogger.Println("handleGetCurrencies jsoner error:", err)
+		return
+	}
+	loggers.InfoLogger.Println("handleGetCurrencies finished with any error.")
+}
+
+func (s *Server) handleGetRates(w http.ResponseWriter, r *http.Request) {
+	loggers, err := middleware.GetLoggers(r.Context())
+	if err != nil {
+		log.Println("LOGGERS DON'T WORK!!!")
+		writeError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	loggers.InfoLogger.Println("handleGetRates started.")
+
+	rates, statusCode, err := s.walletSvc.GetRates(r.Context())
+	if err != nil {
+		loggers.ErrorLogger.Println("handleGetRates s.walletSvc.GetRates error:", err)
+		writeError(w, r, err, statusCode)
+		return
+	}
+
+	err = jsoner(w, rates, statusCode, middleware.GetSecret(r.Context()))
+	if err != nil {
+		loggers.ErrorLogger.Println("handleGetRates jsoner error:", err)
+		return
+	}
+	loggers.InfoLogger.Println("handleGetRates finished with any error.")
+}
+
+func (s *Server) handleExchange(w http.ResponseWriter, r *http.Request) {
+	loggers, err := middleware.GetLoggers(r.Context())
+	if err != nil {
+		log.Println("LOGGERS DON'T WORK!!!")
+		writeError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	loggers.InfoLogger.Println("handleExchange started.")
+
+	var item *types.Exchange
+	err = json.NewDecoder(r.Body).Decode(&item)
+	if err != nil {
+		loggers.ErrorLogger.Println("handleExchange json.NewDecoder error:", err)
+		writeError(w, r, err, http.StatusBadRequest)
+		return
+	}
+
+	id, err := middleware.GetUserID(r.Context())
+	if err != nil {
+		loggers.ErrorLogger.Println("handleExchange middleware.GetUserID error:", err)
+		writeError(w, r, err, http.StatusUnauthorized)
+		return
+	}
+
+	for _, accID := range []int64{item.FromAccID, item.ToAccID} {
+		owner, statusCode, err := s.walletSvc.IsOwner(r.Context(), id, accID)
+		if err != nil {
+			loggers.ErrorLogger.Println("handleExchange s.walletSvc.IsOwner error:", err)
+			writeError(w, r, err, statusCode)
+			return
+		}
+		if !owner {
+			loggers.ErrorLogger.Println("handleExchange", accID, "is not wallet of user")
+			writeError(w, r, wallet.ErrNotFound, http.StatusNotFound)
+			return
+		}
+	}
+
+	var exchange *types.ExchangeResult
+	var statusCode int
+	if key := r.Header.Get("Idempotency-Key"); key != "" {
+		exchange, statusCode, err = s.walletSvc.IdempotentExchange(r.Context(), key, item)
+	} else {
+		exchange, statusCode, err = s.walletSvc.Exchange(r.Context(), item)
+	}
+	if err != nil {
+		loggers.ErrorLogger.Println("handleExchange s.walletSvc.Exchange error:", err)
+		writeError(w, r, err, statusCode)
+		return
+	}
+
+	err = jsoner(w, exchange, statusCode, middleware.GetSecret(r.Context()))
+	if err != nil {
+		loggers.ErrorLogger.Println("handleExchange jsoner error:", err)
+		return
+	}
+	loggers.InfoLogger.Println("handleExchange finished with any error.")
+}
+
+func (s *Server) handleLoadRates(w http.ResponseWriter, r *http.Request) {
+	loggers, err := middleware.GetLoggers(r.Context())
+	if err != nil {
+		log.Println("LOGGERS DON'T WORK!!!")
+		writeError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	loggers.InfoLogger.Println("handleLoadRates started by partner", middleware.GetPartnerID(r.Context()))
+
+	var items []*types.ExchangeRate
+	err = json.NewDecoder(r.Body).Decode(&items)
+	if err != nil {
+		loggers.ErrorLogger.Println("handleLoadRates json.NewDecoder error:", err)
+		writeError(w, r, err, http.StatusBadRequest)
+		return
+	}
+
+	rates, statusCode, err := s.walletSvc.LoadRates(r.Context(), items)
+	if err != nil {
+		loggers.ErrorLogger.Println("handleLoadRates s.walletSvc.LoadRates error:", err)
+		writeError(w, r, err, statusCode)
+		return
+	}
+
+	err = jsoner(w, rates, statusCode, middleware.GetSecret(r.Context()))
+	if err != nil {
+		loggers.ErrorLogger.Println("handleLoadRates jsoner error:", err)
+		return
+	}
+	loggers.InfoLogger.Println("handleLoadRates finished with any error.")
+}
+
+func (s *Server) handleGetSchedules(w http.ResponseWriter, r *http.Request) {
+	loggers, err := middleware.GetLoggers(r.Context())
+	if err != nil {
+		log.Println("LOGGERS DON'T WORK!!!")
+		writeError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	loggers.InfoLogger.Println("handleGetSchedules started.")
+
+	id, err := middleware.GetUserID(r.Context())
+	if err != nil {
+		loggers.ErrorLogger.Println("handleGetSchedules middleware.GetUserID error:", err)
+		writeError(w, r, err, http.StatusUnauthorized)
+		return
+	}
+
+	schedules, statusCode, err := s.schedulerSvc.GetSchedules(r.Context(), id)
+	if err != nil {
+		loggers.ErrorLogger.Println("handleGetSchedules s.schedulerSvc.GetSchedules error:", err)
+		writeError(w, r, err, statusCode)
+		return
+	}
+
+	err = jsoner(w, schedules, statusCode, middleware.GetSecret(r.Context()))
+	if err != nil {
+		loggers.ErrorLogger.Println("handleGetSchedules jsoner error:", err)
+		return
+	}
+	loggers.InfoLogger.Println("handleGetSchedules finished with any error.")
+}
+
+func (s *Server) handleCreateSchedule(w http.ResponseWriter, r *http.Request) {
+	loggers, err := middleware.GetLoggers(r.Context())
+	if err != nil {
+		log.Println("LOGGERS DON'T WORK!!!")
+		writeError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	loggers.InfoLogger.Println("handleCreateSchedule started.")
+
+	var item *types.Schedule
+	err = json.NewDecoder(r.Body).Decode(&item)
+	if err != nil {
+		loggers.ErrorLogger.Println("handleCreateSchedule json.NewDecoder error:", err)
+		writeError(w, r, err, http.StatusBadRequest)
+		return
+	}
+
+	id, err := middleware.GetUserID(r.Context())
+	if err != nil {
+		loggers.ErrorLogger.Println("handleCreateSchedule middleware.GetUserID error:", err)
+		writeError(w, r, err, http.StatusUnauthorized)
+		return
+	}
+
+	owner, statusCode, err := s.walletSvc.IsOwner(r.Context(), id, item.AccID)
+	if err != nil {
+		loggers.ErrorLogger.Println("handleCreateSchedule s.walletSvc.IsOwner error:", err)
+		writeError(w, r, err, statusCode)
+		return
+	}
+	if !owner {
+		loggers.ErrorLogger.Println("handleCreateSchedule item.AccID is not wallet of user")
+		writeError(w, r, wallet.ErrNotFound, http.StatusNotFound)
+		return
+	}
+
+	schedule, statusCode, err := s.schedulerSvc.Create(r.Context(), item)
+	if err != nil {
+		loggers.ErrorLogger.Println("handleCreateSchedule s.schedulerSvc.Create error:", err)
+		writeError(w, r, err, statusCode)
+		return
+	}
+
+	err = jsoner(w, schedule, statusCode, middleware.GetSecret(r.Context()))
+	if err != nil {
+		loggers.ErrorLogger.Println("handleCreateSchedule jsoner error:", err)
+		return
+	}
+	loggers.InfoLogger.Println("handleCreateSchedule finished with any error.")
+}
+
+func (s *Server) handleGetSchedule(w http.ResponseWriter, r *http.Request) {
+	loggers, err := middleware.GetLoggers(r.Context())
+	if err != nil {
+		log.Println("LOGGERS DON'T WORK!!!")
+		writeError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	loggers.InfoLogger.Println("handleGetSchedule started.")
+
+	id, err := middleware.GetUserID(r.Context())
+	if err != nil {
+		loggers.ErrorLogger.Println("handleGetSchedule middleware.GetUserID error:", err)
+		writeError(w, r, err, http.StatusUnauthorized)
+		return
+	}
+
+	scheduleID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		loggers.ErrorLogger.Println("handleGetSchedule strconv.ParseInt error:", err)
+		writeError(w, r, err, http.StatusBadRequest)
+		return
+	}
+
+	schedule, statusCode, err := s.schedulerSvc.GetSchedule(r.Context(), id, scheduleID)
+	if err != nil {
+		loggers.ErrorLogger.Println("handleGetSchedule s.schedulerSvc.GetSchedule error:", err)
+		writeError(w, r, err, statusCode)
+		return
+	}
+
+	err = jsoner(w, schedule, statusCode, middleware.GetSecret(r.Context()))
+	if err != nil {
+		loggers.ErrorLogger.Println("handleGetSchedule jsoner error:", err)
+		return
+	}
+	loggers.InfoLogger.Println("handleGetSchedule finished with any error.")
+}
+
+func (s *Server) handleUpdateSchedule(w http.ResponseWriter, r *http.Request) {
+	loggers, err := middleware.GetLoggers(r.Context())
+	if err != nil {
+		log.Println("LOGGERS DON'T WORK!!!")
+		writeError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	loggers.InfoLogger.Println("handleUpdateSchedule started.")
+
+	var item *types.ScheduleUpdate
+	err = json.NewDecoder(r.Body).Decode(&item)
+	if err != nil {
+		loggers.ErrorLogger.Println("handleUpdateSchedule json.NewDecoder error:", err)
+		writeError(w, r, err, http.StatusBadRequest)
+		return
+	}
+
+	id, err := middleware.GetUserID(r.Context())
+	if err != nil {
+		loggers.ErrorLogger.Println("handleUpdateSchedule middleware.GetUserID error:", err)
+		writeError(w, r, err, http.StatusUnauthorized)
+		return
+	}
+
+	scheduleID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		loggers.ErrorLogger.Println("handleUpdateSchedule strconv.ParseInt error:", err)
+		writeError(w, r, err, http.StatusBadRequest)
+		return
+	}
+
+	schedule, statusCode, err := s.schedulerSvc.Update(r.Context(), id, scheduleID, item)
+	if err != nil {
+		loggers.ErrorLogger.Println("handleUpdateSchedule s.schedulerSvc.Update error:", err)
+		writeError(w, r, err, statusCode)
+		return
+	}
+
+	err = jsoner(w, schedule, statusCode, middleware.GetSecret(r.Context()))
+	if err != nil {
+		loggers.ErrorLogger.Println("handleUpdateSchedule jsoner error:", err)
+		return
+	}
+	loggers.InfoLogger.Println("handleUpdateSchedule finished with any error.")
+}
+
+func (s *Server) handleDeleteSchedule(w http.ResponseWriter, r *http.Request) {
+	loggers, err := middleware.GetLoggers(r.Context())
+	if err != nil {
+		log.Println("LOGGERS DON'T WORK!!!")
+		writeError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	loggers.InfoLogger.Println("handleDeleteSchedule started.")
+
+	id, err := middleware.GetUserID(r.Context())
+	if err != nil {
+		loggers.ErrorLogger.Println("handleDeleteSchedule middleware.GetUserID error:", err)
+		writeError(w, r, err, http.StatusUnauthorized)
+		return
+	}
+
+	scheduleID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		loggers.ErrorLogger.Println("handleDeleteSchedule strconv.ParseInt error:", err)
+		writeError(w, r, err, http.StatusBadRequest)
+		return
+	}
+
+	statusCode, err := s.schedulerSvc.Delete(r.Context(), id, scheduleID)
+	if err != nil {
+		loggers.ErrorLogger.Println("handleDeleteSchedule s.schedulerSvc.Delete error:", err)
+		writeError(w, r, err, statusCode)
+		return
+	}
+
+	w.WriteHeader(statusCode)
+	loggers.InfoLogger.Println("handleDeleteSchedule finished with any error.")
+}
+
+func (s *Server) handleGetScheduleRuns(w http.ResponseWriter, r *http.Request) {
+	loggers, err := middleware.GetLoggers(r.Context())
+	if err != nil {
+		log.Println("LOGGERS DON'T WORK!!!")
+		writeError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	loggers.InfoLogger.Println("handleGetScheduleRuns started.")
+
+	id, err := middleware.GetUserID(r.Context())
+	if err != nil {
+		loggers.ErrorLogger.Println("handleGetScheduleRuns middleware.GetUserID error:", err)
+		writeError(w, r, err, http.StatusUnauthorized)
+		return
+	}
+
+	scheduleID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		loggers.ErrorLogger.Println("handleGetScheduleRuns strconv.ParseInt error:", err)
+		writeError(w, r, err, http.StatusBadRequest)
+		return
+	}
+
+	runs, statusCode, err := s.schedulerSvc.GetRuns(r.Context(), id, scheduleID)
+	if err != nil {
+		loggers.ErrorLogger.Println("handleGetScheduleRuns s.schedulerSvc.GetRuns error:", err)
+		writeError(w, r, err, statusCode)
+		return
+	}
+
+	err = jsoner(w, runs, statusCode, middleware.GetSecret(r.Context()))
+	if err != nil {
+		loggers.ErrorLogger.Println("handleGetScheduleRuns jsoner error:", err)
+		return
+	}
+	loggers.InfoLogger.Println("handleGetScheduleRuns finished with any error.")
+}
+
+func (s *Server) handleReverse(w http.ResponseWriter, r *http.Request) {
+	loggers, err := middleware.GetLoggers(r.Context())
+	if err != nil {
+		log.Println("LOGGERS DON'T WORK!!!")
+		writeError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	loggers.InfoLogger.Println("handleReverse started by partner", middleware.GetPartnerID(r.Context()))
+
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		loggers.ErrorLogger.Println("handleReverse strconv.ParseInt error:", err)
+		writeError(w, r, err, http.StatusBadRequest)
+		return
+	}
+
+	var item *types.Reversal
+	err = json.NewDecoder(r.Body).Decode(&item)
+	if err != nil {
+		loggers.ErrorLogger.Println("handleReverse json.NewDecoder error:", err)
+		writeError(w, r, err, http.StatusBadRequest)
+		return
+	}
+
+	reversal, statusCode, err := s.walletSvc.Reverse(r.Context(), id, item)
+	if err != nil {
+		loggers.ErrorLogger.Println("handleReverse s.walletSvc.Reverse error:", err)
+		writeError(w, r, err, statusCode)
+		return
+	}
+
+	err = jsoner(w, reversal, statusCode, middleware.GetSecret(r.Context()))
+	if err != nil {
+		loggers.ErrorLogger.Println("handleReverse jsoner error:", err)
+		return
+	}
+	loggers.InfoLogger.Println("handleReverse finished with any error.")
+}
+
+//function walletID returns wallet of user from wallet_id query parameter or main account of user if parameter is absent
+func (s *Server) walletID(r *http.Request, userID int64) (int64, int, error) {
+	value := r.URL.Query().Get("wallet_id")
+	if value == "" {
+		return userID, http.StatusOK, nil
+	}
+	id, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, http.StatusBadRequest, err
+	}
+	owner, statusCode, err := s.walletSvc.IsOwner(r.Context(), userID, id)
+	if err != nil {
+		return 0, statusCode, err
+	}
+	if !owner {
+		return 0, http.StatusNotFound, wallet.ErrNotFound
+	}
+	return id, http.StatusOK, nil
+}
+
+//type trackingWriter remembers if anything was written, so handler knows if it still can write error status
+type trackingWriter struct {
+	w       io.Writer
+	written bool
+}
+
+func (t *trackingWriter) Write(p []byte) (int, error) {
+	t.written = true
+	return t.w.Write(p)
+}
+
+//type errorBody is json body of every error response, limit describes hit limit of limit errors
+type errorBody struct {
+	Error string             `json:"error"`
+	Limit *wallet.LimitError `json:"limit,omitempty"`
+}
+
+//function writeError writes error as errorBody. Message is text of err for client errors and status text for server
+//errors and errors without cause
+func writeError(w http.ResponseWriter, r *http.Request, err error, code int) {
+	body := errorBody{Error: http.StatusText(code)}
+	if err != nil && code < http.StatusInternalServerError {
+		body.Error = err.Error()
+	}
+	errors.As(err, &body.Limit)
+	jsoner(w, body, code, middleware.GetSecret(r.Context()))
+}
+
+//function jsoner marshal interfaces to json and write to response writer
+func jsoner(w http.ResponseWriter, v interface{}, code int, secretKey string) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		log.Println("jsoner json.Marshal error:", err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return err
+	}
+
+	w.Header().Set("X-Signature", "sha256="+hex.EncodeToString(middleware.Sign(secretKey, data)))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_, err = w.Write(data)
+	if err != nil {
+		log.Println("jsoner w.Write error:", err)
+		return err
+	}
+	return nil
 }
@@ -0,0 +1,829 @@
+package app_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SYSTEMTerror/GoWallet/internal/app"
+	"github.com/SYSTEMTerror/GoWallet/internal/app/middleware"
+	"github.com/SYSTEMTerror/GoWallet/internal/pkg/partner"
+	"github.com/SYSTEMTerror/GoWallet/internal/pkg/scheduler"
+	"github.com/SYSTEMTerror/GoWallet/internal/pkg/statement"
+	"github.com/SYSTEMTerror/GoWallet/internal/pkg/types"
+	"github.com/SYSTEMTerror/GoWallet/internal/pkg/wallet"
+	"github.com/gorilla/mux"
+)
+
+// Scenarios of test/Request.http against fixtures of test/testData.sql, served from in-memory repository
+
+const (
+	testPartner = "test"
+	testKey     = "1"
+	testSecret  = "Secret"
+
+	// shopPartner may call every wallet endpoint, but not operator ones
+	shopPartner = "shop"
+	shopSecret  = "ShopSecret"
+
+	// kioskPartner may call kioskEndpoints only
+	kioskPartner = "kiosk"
+	kioskSecret  = "KioskSecret"
+
+	// bcrypt hash of "12345678", password of all fixture accounts
+	testPassword     = "12345678"
+	testPasswordHash = "$2a$10$W1uTjnpz.h/hbfWuRhO04ekfs6FffeMsIbtFpxLiFhE6eMgW7oMUi"
+)
+
+func TestMain(m *testing.M) {
+	// request loggers append to "../log.log", keep it out of source tree
+	dir, err := os.MkdirTemp("", "gowallet-app")
+	if err != nil {
+		log.Fatal(err)
+	}
+	work := filepath.Join(dir, "work")
+	if err := os.Mkdir(work, 0755); err != nil {
+		log.Fatal(err)
+	}
+	if err := os.Chdir(work); err != nil {
+		log.Fatal(err)
+	}
+	log.SetOutput(io.Discard)
+
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// testServer is wallet API over httptest server with fixtures of test/testData.sql
+type testServer struct {
+	t         *testing.T
+	srv       *httptest.Server
+	repo      *wallet.MemoryRepository
+	scheduler *scheduler.Service
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+	repo := wallet.NewMemoryRepository()
+	seed(t, repo)
+
+	partners := partner.NewMemoryRepository()
+	partners.AddPartner(testPartner, true, partner.AllEndpoints, partner.OperatorEndpoints)
+	partners.AddKey(&types.PartnerKey{PartnerID: testPartner, KeyID: testKey, Secret: testSecret, Active: true})
+	partners.AddPartner(shopPartner, true, partner.AllEndpoints)
+	partners.AddKey(&types.PartnerKey{PartnerID: shopPartner, KeyID: testKey, Secret: shopSecret, Active: true})
+	partners.AddPartner(kioskPartner, true, kioskEndpoints...)
+	partners.AddKey(&types.PartnerKey{PartnerID: kioskPartner, KeyID: testKey, Secret: kioskSecret, Active: true})
+
+	walletSvc := wallet.NewService(repo, &wallet.TokenConfig{Secret: "test", TTL: time.Hour}, &wallet.HoldConfig{TTL: time.Hour, ExpiryInterval: time.Minute}, time.UTC)
+	schedulerSvc := scheduler.NewService(scheduler.NewMemoryRepository(), walletSvc, &scheduler.Config{Interval: time.Minute, RetryDelay: time.Minute, MaxAttempts: 3}, time.UTC)
+	server := app.NewServer(mux.NewRouter(), walletSvc, partner.NewService(partners), schedulerSvc, &middleware.SignatureConfig{ClockSkew: time.Minute})
+	server.Init()
+
+	srv := httptest.NewServer(server)
+	t.Cleanup(srv.Close)
+	return &testServer{t: t, srv: srv, repo: repo, scheduler: schedulerSvc}
+}
+
+// seed puts currencies, limits and fees of migration and accounts, balances and rates of test/testData.sql to repo
+func seed(t *testing.T, repo *wallet.MemoryRepository) {
+	t.Helper()
+	ctx := context.Background()
+
+	for _, code := range []string{"TJS", "USD", "EUR", "RUB"} {
+		repo.AddCurrency(&types.Currency{Code: code, Name: code, MinorUnits: 2})
+	}
+	limits := []struct {
+		currency string
+		scale    int64
+	}{{"TJS", 100}, {"USD", 10}, {"EUR", 10}, {"RUB", 600}}
+	for _, l := range limits {
+		for _, rule := range []*types.LimitRule{
+			{Tier: wallet.TierAnonymous, Kind: wallet.LimitMaxBalance, Direction: wallet.DirectionCredit, Amount: 10000},
+			{Tier: wallet.TierAnonymous, Kind: wallet.LimitMaxOperation, Direction: wallet.DirectionAny, Amount: 10000},
+			{Tier: wallet.TierAnonymous, Kind: wallet.LimitMonthlyTurnover, Direction: wallet.DirectionAny, Amount: 50000},
+			{Tier: wallet.TierSimplified, Kind: wallet.LimitMaxBalance, Direction: wallet.DirectionCredit, Amount: 30000},
+			{Tier: wallet.TierSimplified, Kind: wallet.LimitMaxOperation, Direction: wallet.DirectionAny, Amount: 30000},
+			{Tier: wallet.TierSimplified, Kind: wallet.LimitMonthlyTurnover, Direction: wallet.DirectionAny, Amount: 200000},
+			{Tier: wallet.TierFull, Kind: wallet.LimitMaxBalance, Direction: wallet.DirectionCredit, Amount: 100000},
+			{Tier: wallet.TierFull, Kind: wallet.LimitMaxOperation, Direction: wallet.DirectionAny, Amount: 100000},
+			{Tier: wallet.TierFull, Kind: wallet.LimitDailyTurnover, Direction: wallet.DirectionAny, Amount: 150000},
+			{Tier: wallet.TierFull, Kind: wallet.LimitMonthlyTurnover, Direction: wallet.DirectionAny, Amount: 1000000},
+		} {
+			rule.Currency, rule.Amount = l.currency, rule.Amount*l.scale
+			repo.AddLimit(rule)
+		}
+	}
+	maxWithdrawalFee, maxTransferFee := int64(5000), int64(10000)
+	repo.AddFee(&types.FeeRule{Operation: wallet.EntryWithdrawal, Currency: "TJS", Percent: "1", MinFee: 100, MaxFee: &maxWithdrawalFee})
+	repo.AddFee(&types.FeeRule{Operation: wallet.EntryTransfer, Currency: "TJS", MinAmount: 100000, Percent: "0.5", MaxFee: &maxTransferFee})
+	repo.AddFee(&types.FeeRule{Operation: wallet.EntryTransfer, Tier: wallet.TierFull, Currency: "TJS", Percent: "0"})
+
+	accounts := []struct {
+		balance int64
+		tier    string
+	}{{0, wallet.TierAnonymous}, {1000000, wallet.TierAnonymous}, {10000000, wallet.TierFull}}
+	for i, item := range accounts {
+		name := strconv.Itoa(i + 1)
+		acc := &types.Account{Currency: "TJS", Tier: wallet.TierAnonymous, Username: name, Phone: name, Password: testPasswordHash}
+		err := repo.InTx(ctx, func(tx wallet.Store) error {
+			if err := tx.CreateAccount(ctx, acc); err != nil {
+				return err
+			}
+			if item.tier != wallet.TierAnonymous {
+				if err := tx.SetTier(ctx, acc.ID, item.tier, true); err != nil {
+					return err
+				}
+			}
+			if item.balance == 0 {
+				return nil
+			}
+			entryID, err := tx.Post(ctx, wallet.EntryTopUp, []*types.Posting{
+				{AccID: &acc.ID, Currency: "TJS", Amount: item.balance},
+				{System: wallet.SystemCashIn, Currency: "TJS", Amount: -item.balance},
+			})
+			if err != nil {
+				return err
+			}
+			return tx.CreateTransaction(ctx, &types.Transaction{AccID: acc.ID, Currency: "TJS", Amount: item.balance}, entryID)
+		})
+		if err != nil {
+			t.Fatalf("seed account %s error: %v", name, err)
+		}
+	}
+
+	for _, rate := range []*types.ExchangeRate{
+		{Base: "USD", Quote: "TJS", Rate: "10.95", Spread: "0.01"},
+		{Base: "EUR", Quote: "TJS", Rate: "11.85", Spread: "0.01"},
+		{Base: "RUB", Quote: "TJS", Rate: "0.1325", Spread: "0.015"},
+	} {
+		rate.ValidFrom = time.Now().Add(-time.Hour)
+		if err := repo.CreateRate(ctx, rate); err != nil {
+			t.Fatalf("seed rate error: %v", err)
+		}
+	}
+}
+
+// request describes call to API signed by key of partner, test partner by default.
+// Body is marshalled to json unless it is string
+type request struct {
+	method  string
+	path    string
+	token   string
+	body    interface{}
+	headers map[string]string
+	partner string
+}
+
+// kioskEndpoints are route templates allowed for kiosk partner
+var kioskEndpoints = []string{"/api/wallet/exist/{phone}", "/api/wallet/login", "/api/wallet/balance", "/api/wallet/transaction"}
+
+// partnerSecret returns secret of key of partner
+func partnerSecret(partnerID string) string {
+	switch partnerID {
+	case shopPartner:
+		return shopSecret
+	case kioskPartner:
+		return kioskSecret
+	}
+	return testSecret
+}
+
+func nonce(t *testing.T) string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		t.Fatal(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+// newRequest returns request signed by key of partner like test/Request.http
+func (s *testServer) newRequest(req request) *http.Request {
+	s.t.Helper()
+	var body []byte
+	switch v := req.body.(type) {
+	case nil:
+	case string:
+		body = []byte(v)
+	default:
+		var err error
+		body, err = json.Marshal(v)
+		if err != nil {
+			s.t.Fatal(err)
+		}
+	}
+
+	r, err := http.NewRequest(req.method, s.srv.URL+req.path, bytes.NewReader(body))
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	partnerID := req.partner
+	if partnerID == "" {
+		partnerID = testPartner
+	}
+	timestamp, n := strconv.FormatInt(time.Now().Unix(), 10), nonce(s.t)
+	r.Header.Set("X-Partner-ID", partnerID)
+	r.Header.Set("X-Key-ID", testKey)
+	r.Header.Set("X-Timestamp", timestamp)
+	r.Header.Set("X-Nonce", n)
+	r.Header.Set("X-Signature", "sha256="+hex.EncodeToString(middleware.Sign(partnerSecret(partnerID), middleware.Canonical(req.method, r.URL.RequestURI(), timestamp, n, body))))
+	if body != nil {
+		r.Header.Set("Content-Type", "application/json")
+	}
+	if req.token != "" {
+		r.Header.Set("Authorization", "Bearer "+req.token)
+	}
+	for key, value := range req.headers {
+		r.Header.Set(key, value)
+	}
+	return r
+}
+
+// send sends request, checks its status code and signature of successful response and returns response body
+func (s *testServer) send(r *http.Request, wantCode int) []byte {
+	s.t.Helper()
+	resp, err := s.srv.Client().Do(r)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	if resp.StatusCode != wantCode {
+		s.t.Fatalf("%s %s = %d %s, want %d", r.Method, r.URL.Path, resp.StatusCode, body, wantCode)
+	}
+
+	if resp.StatusCode >= 400 {
+		var e struct {
+			Error string `json:"error"`
+		}
+		if resp.Header.Get("Content-Type") != "application/json" || json.Unmarshal(body, &e) != nil || e.Error == "" {
+			s.t.Errorf("%s %s error response %s of %s, want json with error", r.Method, r.URL.Path, body, resp.Header.Get("Content-Type"))
+		}
+	}
+	if resp.StatusCode < 300 && len(body) > 0 {
+		signature := resp.Header.Get("X-Signature")
+		if signature == "" {
+			signature = resp.Trailer.Get("X-Signature")
+		}
+		got, err := hex.DecodeString(strings.TrimPrefix(signature, "sha256="))
+		if err != nil || !hmac.Equal(got, middleware.Sign(partnerSecret(r.Header.Get("X-Partner-ID")), body)) {
+			s.t.Errorf("%s %s response signature %q is invalid", r.Method, r.URL.Path, signature)
+		}
+	}
+	return body
+}
+
+// call sends signed request and decodes json response to v, if v is not nil
+func (s *testServer) call(req request, wantCode int, v interface{}) {
+	s.t.Helper()
+	body := s.send(s.newRequest(req), wantCode)
+	if v != nil {
+		if err := json.Unmarshal(body, v); err != nil {
+			s.t.Fatalf("%s %s response %s error: %v", req.method, req.path, body, err)
+		}
+	}
+}
+
+// login returns access token of account with phone
+func (s *testServer) login(phone string) string {
+	s.t.Helper()
+	var token types.Token
+	s.call(request{method: "POST", path: "/api/wallet/login", body: types.LoginInfo{Phone: phone, Password: testPassword}}, http.StatusOK, &token)
+	return token.Token
+}
+
+func TestServer_Signature(t *testing.T) {
+	s := newTestServer(t)
+
+	t.Run("valid", func(t *testing.T) {
+		s.call(request{method: "GET", path: "/api/wallet/exist/2"}, http.StatusOK, nil)
+	})
+	t.Run("bad digest", func(t *testing.T) {
+		r := s.newRequest(request{method: "GET", path: "/api/wallet/exist/2"})
+		r.Header.Set("X-Signature", "sha256="+hex.EncodeToString(middleware.Sign("wrong", []byte("GET"))))
+		s.send(r, http.StatusUnauthorized)
+	})
+	t.Run("body changed after signing", func(t *testing.T) {
+		r := s.newRequest(request{method: "POST", path: "/api/wallet/login", body: types.LoginInfo{Phone: "2", Password: testPassword}})
+		r.Body = io.NopCloser(strings.NewReader(`{"phone":"3","password":"12345678"}`))
+		r.ContentLength = -1
+		s.send(r, http.StatusUnauthorized)
+	})
+	for _, header := range []string{"X-Timestamp", "X-Nonce", "X-Signature"} {
+		t.Run("missing "+header, func(t *testing.T) {
+			r := s.newRequest(request{method: "GET", path: "/api/wallet/exist/2"})
+			r.Header.Del(header)
+			s.send(r, http.StatusUnauthorized)
+		})
+	}
+	t.Run("unknown key", func(t *testing.T) {
+		r := s.newRequest(request{method: "GET", path: "/api/wallet/exist/2"})
+		r.Header.Set("X-Key-ID", "2")
+		s.send(r, http.StatusUnauthorized)
+	})
+	t.Run("expired timestamp", func(t *testing.T) {
+		r := s.newRequest(request{method: "GET", path: "/api/wallet/exist/2"})
+		r.Header.Set("X-Timestamp", strconv.FormatInt(time.Now().Add(-time.Hour).Unix(), 10))
+		s.send(r, http.StatusUnauthorized)
+	})
+	t.Run("replayed nonce", func(t *testing.T) {
+		r := s.newRequest(request{method: "GET", path: "/api/wallet/exist/2"})
+		replay := r.Clone(context.Background())
+		s.send(r, http.StatusOK)
+		s.send(replay, http.StatusUnauthorized)
+	})
+	t.Run("operator route", func(t *testing.T) {
+		r := s.newRequest(request{method: "POST", path: "/api/operator/rates", body: []*types.ExchangeRate{}})
+		r.Header.Set("X-Signature", "sha256=00")
+		s.send(r, http.StatusUnauthorized)
+	})
+}
+
+func TestServer_OperatorPermission(t *testing.T) {
+	s := newTestServer(t)
+
+	// wildcard of shop partner covers wallet routes only
+	s.call(request{method: "GET", path: "/api/wallet/exist/2", partner: shopPartner}, http.StatusOK, nil)
+	for _, path := range []string{
+		"/api/operator/transactions/1/reverse",
+		"/api/operator/identifications/1/approve",
+		"/api/operator/identifications/1/reject",
+		"/api/operator/rates",
+	} {
+		t.Run(path, func(t *testing.T) {
+			s.call(request{method: "POST", path: path, body: "{}", partner: shopPartner}, http.StatusForbidden, nil)
+		})
+	}
+
+	// test partner has operator permission besides wildcard
+	s.call(request{method: "POST", path: "/api/operator/identifications/1000/approve"}, http.StatusNotFound, nil)
+}
+
+func TestServer_EndpointAllowlist(t *testing.T) {
+	s := newTestServer(t)
+
+	s.call(request{method: "GET", path: "/api/wallet/exist/2", partner: kioskPartner}, http.StatusOK, nil)
+	var token types.Token
+	s.call(request{method: "POST", path: "/api/wallet/login", body: types.LoginInfo{Phone: "2", Password: testPassword}, partner: kioskPartner}, http.StatusOK, &token)
+	s.call(request{method: "GET", path: "/api/wallet/balance", token: token.Token, partner: kioskPartner}, http.StatusOK, nil)
+	s.call(request{method: "POST", path: "/api/wallet/transaction", token: token.Token, body: types.Transaction{AccID: 2, Amount: -1000}, partner: kioskPartner}, http.StatusOK, nil)
+
+	for _, r := range []request{
+		{method: "POST", path: "/api/wallet/register", body: types.RegInfo{Username: "kiosk", Phone: "992000000099", Password: testPassword}},
+		{method: "POST", path: "/api/wallet/transfer", body: types.Transfer{AccID: 2, Phone: "3", Amount: 100}},
+		{method: "GET", path: "/api/wallet/account"},
+		{method: "GET", path: "/api/wallet/transactions"},
+		{method: "GET", path: "/api/wallet/statement"},
+		{method: "POST", path: "/api/wallet/holds", body: "{}"},
+		{method: "POST", path: "/api/wallet/holds/1/capture", body: "{}"},
+		{method: "GET", path: "/api/wallet/schedules/1"},
+		{method: "POST", path: "/api/operator/rates", body: "[]"},
+	} {
+		t.Run(r.method+" "+r.path, func(t *testing.T) {
+			r.token, r.partner = token.Token, kioskPartner
+			s.call(r, http.StatusForbidden, nil)
+		})
+	}
+
+	// partner is forbidden only after its signature is verified
+	r := s.newRequest(request{method: "POST", path: "/api/wallet/transfer", token: token.Token, body: "{}", partner: kioskPartner})
+	r.Header.Set("X-Signature", "sha256=00")
+	s.send(r, http.StatusUnauthorized)
+
+	// only allowed withdrawal with its minimal fee changed balance of fixture account 2
+	var balance types.Balance
+	s.call(request{method: "GET", path: "/api/wallet/balance", token: token.Token, partner: kioskPartner}, http.StatusOK, &balance)
+	if balance.Balance != 1000000-1000-100 {
+		t.Errorf("balance = %+v, want %d", balance, 1000000-1000-100)
+	}
+}
+
+func TestServer_ErrorBody(t *testing.T) {
+	s := newTestServer(t)
+	token := s.login("2")
+
+	tests := []struct {
+		name string
+		req  request
+		code int
+		want string
+	}{
+		{"malformed json", request{method: "POST", path: "/api/wallet/transaction", token: token, body: "{"}, http.StatusBadRequest, "unexpected EOF"},
+		{"service error", request{method: "POST", path: "/api/wallet/transfer", token: token, body: types.Transfer{AccID: 2, Phone: "3", Amount: 1 << 40}}, http.StatusBadRequest, wallet.ErrNotEnoughFunds.Error()},
+		{"wallet of other user", request{method: "POST", path: "/api/wallet/transaction", token: token, body: types.Transaction{AccID: 3, Amount: 100}}, http.StatusNotFound, wallet.ErrNotFound.Error()},
+		{"middleware", request{method: "GET", path: "/api/wallet/account", token: "invalid"}, http.StatusUnauthorized, http.StatusText(http.StatusUnauthorized)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var body struct {
+				Error string          `json:"error"`
+				Limit json.RawMessage `json:"limit"`
+			}
+			s.call(tt.req, tt.code, &body)
+			if body.Error != tt.want || body.Limit != nil {
+				t.Errorf("error body = %+v, want error %q without limit", body, tt.want)
+			}
+		})
+	}
+}
+
+func TestServer_Authentication(t *testing.T) {
+	s := newTestServer(t)
+
+	routes := []struct {
+		method string
+		path   string
+		body   interface{}
+	}{
+		{"POST", "/api/wallet/transaction", types.Transaction{AccID: 2, Amount: 100}},
+		{"POST", "/api/wallet/transfer", types.Transfer{AccID: 2, Phone: "1", Amount: 100}},
+		{"GET", "/api/wallet/transactions", nil},
+		{"GET", "/api/wallet/statement", nil},
+		{"GET", "/api/wallet/account", nil},
+		{"GET", "/api/wallet/balance", nil},
+		{"POST", "/api/wallet/identify", types.Identification{Tier: wallet.TierSimplified}},
+		{"GET", "/api/wallet/identify", nil},
+		{"POST", "/api/wallet/holds", types.Hold{AccID: 2, Amount: 100}},
+		{"GET", "/api/wallet/wallets", nil},
+		{"POST", "/api/wallet/wallets", types.WalletInfo{Currency: "USD"}},
+		{"POST", "/api/wallet/exchange", types.Exchange{FromAccID: 2, ToAccID: 3, Amount: 100}},
+		{"GET", "/api/wallet/schedules", nil},
+		{"POST", "/api/wallet/schedules", types.Schedule{AccID: 2, Kind: "transaction", Amount: 100, Period: "daily"}},
+		{"GET", "/api/wallet/schedules/1", nil},
+		{"PUT", "/api/wallet/schedules/1", types.ScheduleUpdate{Amount: 100, Period: "daily"}},
+		{"DELETE", "/api/wallet/schedules/1", nil},
+		{"GET", "/api/wallet/schedules/1/runs", nil},
+	}
+	for _, route := range routes {
+		t.Run(route.method+" "+route.path, func(t *testing.T) {
+			s.call(request{method: route.method, path: route.path, body: route.body}, http.StatusUnauthorized, nil)
+			s.call(request{method: route.method, path: route.path, body: route.body, token: "invalid"}, http.StatusUnauthorized, nil)
+		})
+	}
+
+	t.Run("not bearer", func(t *testing.T) {
+		r := s.newRequest(request{method: "GET", path: "/api/wallet/account"})
+		r.Header.Set("Authorization", "Basic "+s.login("2"))
+		s.send(r, http.StatusUnauthorized)
+	})
+}
+
+func TestServer_RegisterAndLogin(t *testing.T) {
+	s := newTestServer(t)
+
+	var exist types.ExistView
+	s.call(request{method: "GET", path: "/api/wallet/exist/test"}, http.StatusOK, &exist)
+	if exist.Exist {
+		t.Errorf("exist before register = true, want false")
+	}
+
+	reg := types.RegInfo{Username: "test", Phone: "test", Password: testPassword, Currency: "TJS"}
+	var acc types.AccountView
+	s.call(request{method: "POST", path: "/api/wallet/register", body: reg}, http.StatusOK, &acc)
+	if acc.ID == 0 || acc.Phone != "test" || acc.Tier != wallet.TierAnonymous {
+		t.Errorf("register = %+v, want anonymous account with phone test", acc)
+	}
+	s.call(request{method: "POST", path: "/api/wallet/register", body: reg}, http.StatusConflict, nil)
+	s.call(request{method: "POST", path: "/api/wallet/register", body: "{"}, http.StatusBadRequest, nil)
+
+	s.call(request{method: "GET", path: "/api/wallet/exist/test"}, http.StatusOK, &exist)
+	if !exist.Exist {
+		t.Errorf("exist after register = false, want true")
+	}
+
+	token := s.login("test")
+	var got types.AccountView
+	s.call(request{method: "GET", path: "/api/wallet/account", token: token}, http.StatusOK, &got)
+	if got.ID != acc.ID {
+		t.Errorf("account = %+v, want %+v", got, acc)
+	}
+
+	s.call(request{method: "POST", path: "/api/wallet/login", body: types.LoginInfo{Phone: "test", Password: "wrong password"}}, http.StatusUnauthorized, nil)
+	s.call(request{method: "POST", path: "/api/wallet/login", body: types.LoginInfo{Phone: "unknown", Password: testPassword}}, http.StatusUnauthorized, nil)
+}
+
+func TestServer_TransactionAndTransfer(t *testing.T) {
+	s := newTestServer(t)
+	token := s.login("3")
+
+	var withdrawal types.Transaction
+	s.call(request{method: "POST", path: "/api/wallet/transaction", token: token, body: types.Transaction{AccID: 3, Amount: -100000}}, http.StatusOK, &withdrawal)
+	if withdrawal.Amount != -100000 || withdrawal.Fee != 1000 {
+		t.Errorf("withdrawal = %+v, want amount -100000 and fee 1000", withdrawal)
+	}
+
+	t.Run("idempotent", func(t *testing.T) {
+		req := request{method: "POST", path: "/api/wallet/transaction", token: token, body: types.Transaction{AccID: 3, Amount: -500}, headers: map[string]string{"Idempotency-Key": "4f1c2a9e-topup-1"}}
+		var first, second types.Transaction
+		s.call(req, http.StatusOK, &first)
+		s.call(req, http.StatusOK, &second)
+		if first.ID != second.ID {
+			t.Errorf("replayed transaction id = %d, want %d", second.ID, first.ID)
+		}
+	})
+
+	t.Run("out of limit", func(t *testing.T) {
+		var resp struct {
+			Error string             `json:"error"`
+			Limit *wallet.LimitError `json:"limit"`
+		}
+		s.call(request{method: "POST", path: "/api/wallet/transaction", token: s.login("2"), body: types.Transaction{AccID: 2, Amount: 300}}, http.StatusBadRequest, &resp)
+		if resp.Limit == nil || resp.Limit.Kind != wallet.LimitMaxBalance || resp.Limit.Limit != 1000000 {
+			t.Errorf("out of limit response = %+v, want max_balance limit 1000000", resp)
+		}
+	})
+	t.Run("not owner", func(t *testing.T) {
+		s.call(request{method: "POST", path: "/api/wallet/transaction", token: token, body: types.Transaction{AccID: 2, Amount: -100}}, http.StatusNotFound, nil)
+	})
+	t.Run("unknown account", func(t *testing.T) {
+		s.call(request{method: "POST", path: "/api/wallet/transaction", token: token, body: types.Transaction{AccID: 100, Amount: -100}}, http.StatusNotFound, nil)
+	})
+	t.Run("invalid body", func(t *testing.T) {
+		s.call(request{method: "POST", path: "/api/wallet/transaction", token: token, body: "["}, http.StatusBadRequest, nil)
+	})
+
+	var transfer types.TransferResult
+	s.call(request{method: "POST", path: "/api/wallet/transfer", token: token, body: types.Transfer{AccID: 3, Phone: "1", Amount: 50000}}, http.StatusOK, &transfer)
+	if transfer.Debit == nil || transfer.Credit == nil || transfer.Debit.Amount != -50000 || transfer.Credit.AccID != 1 || transfer.Debit.Fee != 0 {
+		t.Errorf("transfer = %+v, want 50000 from 3 to 1 without fee", transfer)
+	}
+	t.Run("transfer to unknown phone", func(t *testing.T) {
+		s.call(request{method: "POST", path: "/api/wallet/transfer", token: token, body: types.Transfer{AccID: 3, Phone: "unknown", Amount: 100}}, http.StatusNotFound, nil)
+	})
+
+	var balance types.Balance
+	s.call(request{method: "GET", path: "/api/wallet/balance", token: token}, http.StatusOK, &balance)
+	if want := int64(10000000 - 101000 - 500 - 100 - 50000); balance.Balance != want || balance.Available != want {
+		t.Errorf("balance = %+v, want %d", balance, want)
+	}
+	s.call(request{method: "GET", path: "/api/wallet/balance", token: s.login("1")}, http.StatusOK, &balance)
+	if balance.Balance != 50000 {
+		t.Errorf("balance of recipient = %+v, want 50000", balance)
+	}
+
+	var page types.TransactionsPerMonth
+	s.call(request{method: "GET", path: "/api/wallet/transactions?direction=debit&limit=2", token: token}, http.StatusOK, &page)
+	if len(page.Transactions) != 2 || page.Transactions[0].ID != withdrawal.ID || page.NextCursor == "" {
+		t.Errorf("transactions page = %+v, want 2 debits from withdrawal and next cursor", page)
+	}
+	var next types.TransactionsPerMonth
+	s.call(request{method: "GET", path: "/api/wallet/transactions?direction=debit&cursor=" + page.NextCursor, token: token}, http.StatusOK, &next)
+	if len(next.Transactions) != 1 || next.Transactions[0].ID != transfer.Debit.ID || next.NextCursor != "" {
+		t.Errorf("second transactions page = %+v, want last page with transfer", next)
+	}
+	s.call(request{method: "GET", path: "/api/wallet/transactions?direction=sideways", token: token}, http.StatusBadRequest, nil)
+
+	var reversal types.Transaction
+	s.call(request{method: "POST", path: "/api/operator/transactions/" + strconv.FormatInt(withdrawal.ID, 10) + "/reverse", body: types.Reversal{Amount: 40000}}, http.StatusOK, &reversal)
+	if reversal.Amount != 40000 || reversal.ReversalOf == nil || *reversal.ReversalOf != withdrawal.ID {
+		t.Errorf("reversal = %+v, want 40000 of transaction %d", reversal, withdrawal.ID)
+	}
+	s.call(request{method: "POST", path: "/api/operator/transactions/" + strconv.FormatInt(withdrawal.ID, 10) + "/reverse", body: types.Reversal{Amount: 100000}}, http.StatusBadRequest, nil)
+	s.call(request{method: "POST", path: "/api/operator/transactions/1000/reverse", body: types.Reversal{}}, http.StatusNotFound, nil)
+
+	if _, err := wallet.NewService(s.repo, &wallet.TokenConfig{}, &wallet.HoldConfig{}, time.UTC).CheckLedger(context.Background()); err != nil {
+		t.Errorf("CheckLedger error: %v", err)
+	}
+}
+
+func TestServer_Statement(t *testing.T) {
+	s := newTestServer(t)
+	token := s.login("3")
+	s.call(request{method: "POST", path: "/api/wallet/transaction", token: token, body: types.Transaction{AccID: 3, Amount: -100000}}, http.StatusOK, nil)
+
+	body := s.send(s.newRequest(request{method: "GET", path: "/api/wallet/statement?format=jsonl", token: token}), http.StatusOK)
+	lines := strings.Split(strings.TrimSpace(string(body)), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("statement = %q, want opening, 2 transactions and closing", body)
+	}
+	var closing types.Statement
+	if err := json.Unmarshal([]byte(lines[3]), &closing); err != nil {
+		t.Fatalf("statement closing %q error: %v", lines[3], err)
+	}
+	if closing.Closing != 10000000-101000 || closing.Count != 2 {
+		t.Errorf("statement closing = %+v, want closing %d of 2 transactions", closing, 10000000-101000)
+	}
+
+	body = s.send(s.newRequest(request{method: "GET", path: "/api/wallet/statement?format=csv", token: token}), http.StatusOK)
+	rows, err := csv.NewReader(bytes.NewReader(body)).ReadAll()
+	if err != nil || len(rows) != 5 {
+		t.Fatalf("csv statement = %q, %v, want header, opening, 2 transactions and closing", body, err)
+	}
+	if withdrawal := rows[3]; withdrawal[0] != statement.RowTransaction || withdrawal[3] != "-1000.00" || withdrawal[4] != "10.00" || withdrawal[5] != "98990.00" {
+		t.Errorf("csv withdrawal row = %q, want amount -1000.00, fee 10.00 and balance 98990.00", withdrawal)
+	}
+	if closingRow := rows[4]; closingRow[0] != statement.RowClosing || closingRow[4] != "10.00" || closingRow[5] != "98990.00" {
+		t.Errorf("csv closing row = %q, want fees 10.00 and closing balance 98990.00", closingRow)
+	}
+	body = s.send(s.newRequest(request{method: "GET", path: "/api/wallet/statement?format=mt940", token: token}), http.StatusOK)
+	if !bytes.Contains(body, []byte(":20:")) {
+		t.Errorf("mt940 statement = %q, want transaction reference field", body)
+	}
+
+	s.call(request{method: "GET", path: "/api/wallet/statement?format=pdf", token: token}, http.StatusBadRequest, nil)
+	s.call(request{method: "GET", path: "/api/wallet/statement?from=yesterday", token: token}, http.StatusBadRequest, nil)
+	s.call(request{method: "GET", path: "/api/wallet/statement?wallet_id=1", token: token}, http.StatusNotFound, nil)
+}
+
+func TestServer_Identification(t *testing.T) {
+	s := newTestServer(t)
+	token := s.login("1")
+
+	var state types.IdentificationState
+	s.call(request{method: "GET", path: "/api/wallet/identify", token: token}, http.StatusOK, &state)
+	if state.Tier != wallet.TierAnonymous || state.Latest != nil {
+		t.Errorf("state before identification = %+v, want anonymous without request", state)
+	}
+
+	item := types.Identification{Tier: wallet.TierSimplified, FullName: "Test", BirthDate: "1990-01-01", DocumentType: "passport", DocumentNumber: "A1"}
+	s.call(request{method: "POST", path: "/api/wallet/identify", token: token, body: types.Identification{Tier: wallet.TierSimplified}}, http.StatusBadRequest, nil)
+	var first types.Identification
+	s.call(request{method: "POST", path: "/api/wallet/identify", token: token, body: item}, http.StatusOK, &first)
+	s.call(request{method: "POST", path: "/api/wallet/identify", token: token, body: item}, http.StatusConflict, nil)
+
+	var rejected types.Identification
+	s.call(request{method: "POST", path: "/api/operator/identifications/" + strconv.FormatInt(first.ID, 10) + "/reject", body: types.Rejection{Reason: "blurred"}}, http.StatusOK, &rejected)
+	if rejected.Status != wallet.IdentificationRejected || rejected.ReviewedBy != testPartner {
+		t.Errorf("rejected = %+v, want rejected by %s", rejected, testPartner)
+	}
+
+	var second types.Identification
+	s.call(request{method: "POST", path: "/api/wallet/identify", token: token, body: item}, http.StatusOK, &second)
+	s.call(request{method: "POST", path: "/api/operator/identifications/" + strconv.FormatInt(second.ID, 10) + "/approve"}, http.StatusOK, nil)
+	s.call(request{method: "POST", path: "/api/operator/identifications/" + strconv.FormatInt(second.ID, 10) + "/approve"}, http.StatusConflict, nil)
+	s.call(request{method: "POST", path: "/api/operator/identifications/1000/approve"}, http.StatusNotFound, nil)
+
+	s.call(request{method: "GET", path: "/api/wallet/identify", token: token}, http.StatusOK, &state)
+	if state.Tier != wallet.TierSimplified || state.Status != wallet.IdentificationVerified {
+		t.Errorf("state after approval = %+v, want verified simplified tier", state)
+	}
+}
+
+func TestServer_Holds(t *testing.T) {
+	s := newTestServer(t)
+	token := s.login("3")
+
+	var hold types.Hold
+	s.call(request{method: "POST", path: "/api/wallet/holds", token: token, body: types.Hold{AccID: 3, Amount: 10000}}, http.StatusOK, &hold)
+	if hold.Status != wallet.HoldActive || hold.PartnerID != testPartner {
+		t.Errorf("hold = %+v, want active hold of %s", hold, testPartner)
+	}
+	var balance types.Balance
+	s.call(request{method: "GET", path: "/api/wallet/balance", token: token}, http.StatusOK, &balance)
+	if balance.Held != 10000 || balance.Available != 10000000-10000 {
+		t.Errorf("balance with hold = %+v, want 10000 held", balance)
+	}
+
+	holdPath := "/api/wallet/holds/" + strconv.FormatInt(hold.ID, 10)
+	var captured types.Transaction
+	s.call(request{method: "POST", path: holdPath + "/capture", token: token, body: types.Capture{Amount: 5000}}, http.StatusOK, &captured)
+	if captured.Amount != -5000 || captured.HoldID == nil || *captured.HoldID != hold.ID {
+		t.Errorf("capture = %+v, want -5000 of hold %d", captured, hold.ID)
+	}
+	s.call(request{method: "POST", path: holdPath + "/capture", token: token, body: types.Capture{}}, http.StatusConflict, nil)
+	s.call(request{method: "POST", path: holdPath + "/void", token: token}, http.StatusConflict, nil)
+
+	s.call(request{method: "POST", path: "/api/wallet/holds", token: token, body: types.Hold{AccID: 3, Amount: 20000}}, http.StatusOK, &hold)
+	var voided types.Hold
+	s.call(request{method: "POST", path: "/api/wallet/holds/" + strconv.FormatInt(hold.ID, 10) + "/void", token: token}, http.StatusOK, &voided)
+	if voided.Status != wallet.HoldVoided {
+		t.Errorf("void = %+v, want voided hold", voided)
+	}
+
+	s.call(request{method: "POST", path: "/api/wallet/holds", token: token, body: types.Hold{AccID: 3, Amount: 0}}, http.StatusBadRequest, nil)
+	s.call(request{method: "POST", path: "/api/wallet/holds", token: token, body: types.Hold{AccID: 3, Amount: 20000000}}, http.StatusBadRequest, nil)
+	s.call(request{method: "POST", path: "/api/wallet/holds", token: token, body: types.Hold{AccID: 1, Amount: 100}}, http.StatusNotFound, nil)
+	s.call(request{method: "POST", path: "/api/wallet/holds/1000/void", token: token}, http.StatusNotFound, nil)
+	s.call(request{method: "POST", path: "/api/wallet/holds/x/void", token: token}, http.StatusBadRequest, nil)
+
+	s.call(request{method: "GET", path: "/api/wallet/balance", token: token}, http.StatusOK, &balance)
+	if balance.Balance != 10000000-5000 || balance.Held != 0 {
+		t.Errorf("balance after capture and void = %+v, want %d without holds", balance, 10000000-5000)
+	}
+}
+
+func TestServer_WalletsAndExchange(t *testing.T) {
+	s := newTestServer(t)
+	token := s.login("3")
+
+	var currencies []*types.Currency
+	s.call(request{method: "GET", path: "/api/wallet/currencies", token: token}, http.StatusOK, &currencies)
+	if len(currencies) != 4 {
+		t.Errorf("currencies = %d, want 4", len(currencies))
+	}
+	var rates []*types.ExchangeRate
+	s.call(request{method: "GET", path: "/api/wallet/rates"}, http.StatusOK, &rates)
+	if len(rates) != 3 {
+		t.Errorf("rates = %d, want 3", len(rates))
+	}
+
+	var usd types.AccountView
+	s.call(request{method: "POST", path: "/api/wallet/wallets", token: token, body: types.WalletInfo{Currency: "USD"}}, http.StatusOK, &usd)
+	if usd.Currency != "USD" || usd.OwnerID == nil || *usd.OwnerID != 3 || usd.Tier != wallet.TierFull {
+		t.Errorf("open wallet = %+v, want full tier USD wallet of 3", usd)
+	}
+	s.call(request{method: "POST", path: "/api/wallet/wallets", token: token, body: types.WalletInfo{Currency: "USD"}}, http.StatusConflict, nil)
+	s.call(request{method: "POST", path: "/api/wallet/wallets", token: token, body: types.WalletInfo{Currency: "XXX"}}, http.StatusBadRequest, nil)
+
+	var wallets []*types.AccountView
+	s.call(request{method: "GET", path: "/api/wallet/wallets", token: token}, http.StatusOK, &wallets)
+	if len(wallets) != 2 {
+		t.Errorf("wallets = %d, want 2", len(wallets))
+	}
+
+	var exchange types.ExchangeResult
+	s.call(request{method: "POST", path: "/api/wallet/exchange", token: token, body: types.Exchange{FromAccID: 3, ToAccID: usd.ID, Amount: 109500}}, http.StatusOK, &exchange)
+	if exchange.Debit == nil || exchange.Credit == nil || exchange.Debit.Amount != -109500 || exchange.Credit.Amount <= 0 || exchange.Credit.Amount >= 10000 {
+		t.Errorf("exchange = %+v, want 1095 TJS to less than 100 USD", exchange)
+	}
+	s.call(request{method: "GET", path: "/api/wallet/balance?wallet_id=" + strconv.FormatInt(usd.ID, 10), token: token}, http.StatusOK, nil)
+	s.call(request{method: "POST", path: "/api/wallet/exchange", token: token, body: types.Exchange{FromAccID: 3, ToAccID: 2, Amount: 100}}, http.StatusNotFound, nil)
+	s.call(request{method: "POST", path: "/api/wallet/exchange", token: token, body: types.Exchange{FromAccID: 3, ToAccID: 3, Amount: 100}}, http.StatusBadRequest, nil)
+
+	loaded := []*types.ExchangeRate{{Base: "usd", Quote: "tjs", Rate: "11", Spread: "0.02"}}
+	s.call(request{method: "POST", path: "/api/operator/rates", body: loaded}, http.StatusOK, &rates)
+	if len(rates) != 1 || rates[0].ID == 0 || rates[0].Base != "USD" {
+		t.Errorf("loaded rates = %+v, want USD/TJS", rates)
+	}
+	s.call(request{method: "POST", path: "/api/operator/rates", body: []*types.ExchangeRate{{Base: "USD", Quote: "TJS", Rate: "-1"}}}, http.StatusBadRequest, nil)
+}
+
+func TestServer_Schedules(t *testing.T) {
+	s := newTestServer(t)
+	token := s.login("3")
+
+	var schedule types.Schedule
+	s.call(request{method: "POST", path: "/api/wallet/schedules", token: token, body: types.Schedule{AccID: 3, Kind: "transfer", Phone: "1", Amount: 1000, Period: "monthly"}}, http.StatusOK, &schedule)
+	if schedule.ID == 0 || !schedule.Active {
+		t.Errorf("schedule = %+v, want active schedule", schedule)
+	}
+	for _, invalid := range []types.Schedule{
+		{AccID: 3, Kind: "transfer", Phone: "1", Amount: 1000, Period: "1s"},
+		{AccID: 3, Kind: "transfer", Amount: 1000, Period: "daily"},
+		{AccID: 3, Kind: "transfer", Phone: "1", Amount: -1000, Period: "daily"},
+		{AccID: 3, Kind: "transaction", Period: "daily"},
+		{AccID: 3, Kind: "exchange", Amount: 1000, Period: "daily"},
+	} {
+		s.call(request{method: "POST", path: "/api/wallet/schedules", token: token, body: invalid}, http.StatusBadRequest, nil)
+	}
+	s.call(request{method: "POST", path: "/api/wallet/schedules", token: token, body: types.Schedule{AccID: 2, Kind: "transaction", Amount: 1000, Period: "daily"}}, http.StatusNotFound, nil)
+
+	path := "/api/wallet/schedules/" + strconv.FormatInt(schedule.ID, 10)
+	var schedules []*types.Schedule
+	s.call(request{method: "GET", path: "/api/wallet/schedules", token: token}, http.StatusOK, &schedules)
+	if len(schedules) != 1 {
+		t.Errorf("schedules = %d, want 1", len(schedules))
+	}
+	s.call(request{method: "GET", path: path, token: s.login("2")}, http.StatusNotFound, nil)
+
+	var updated types.Schedule
+	s.call(request{method: "PUT", path: path, token: token, body: types.Schedule{Phone: "1", Amount: 2000, Period: "weekly", Active: true}}, http.StatusOK, &updated)
+	if updated.Amount != 2000 || updated.Period != scheduler.PeriodWeekly {
+		t.Errorf("updated = %+v, want weekly 2000", updated)
+	}
+	s.call(request{method: "GET", path: path, token: token}, http.StatusOK, &schedule)
+	if schedule.Amount != 2000 {
+		t.Errorf("schedule after update = %+v, want amount 2000", schedule)
+	}
+	if !schedule.Anchor.Equal(updated.NextRun) {
+		t.Errorf("schedule after update = %+v, want anchor at next run", schedule)
+	}
+
+	// schedule created without next run is due at once
+	count, err := s.scheduler.RunDue(context.Background())
+	if err != nil || count != 1 {
+		t.Fatalf("RunDue = %d, %v, want 1", count, err)
+	}
+	var runs []*types.ScheduleRun
+	s.call(request{method: "GET", path: path + "/runs", token: token}, http.StatusOK, &runs)
+	if len(runs) != 1 || runs[0].Status != scheduler.RunSucceeded || runs[0].TransactionID == nil {
+		t.Errorf("runs = %+v, want one succeeded run", runs)
+	}
+	s.call(request{method: "GET", path: path, token: token}, http.StatusOK, &schedule)
+	if !schedule.NextRun.After(time.Now()) {
+		t.Errorf("schedule after run = %+v, want next run in a week", schedule)
+	}
+	s.call(request{method: "GET", path: path + "/runs", token: s.login("2")}, http.StatusNotFound, nil)
+
+	s.call(request{method: "DELETE", path: path, token: token}, http.StatusNoContent, nil)
+	s.call(request{method: "DELETE", path: path, token: token}, http.StatusNotFound, nil)
+	s.call(request{method: "GET", path: "/api/wallet/schedules/x", token: token}, http.StatusBadRequest, nil)
+}
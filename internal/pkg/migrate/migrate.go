@@ -0,0 +1,324 @@
+package migrate
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// lockID is key of advisory lock held while migrations run, so two instances cannot migrate concurrently
+const lockID = 7266348713
+
+// Migrations are numbered <version>_<name>.up.sql and <version>_<name>.down.sql files of migrations directory
+//
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+var (
+	ErrInvalidName    = errors.New("invalid migration file name")
+	ErrDuplicate      = errors.New("duplicate migration version")
+	ErrMissingDown    = errors.New("migration has no down file")
+	ErrUnknownVersion = errors.New("applied migration version is unknown")
+	ErrNotVersioned   = errors.New("database has schema without applied migrations, run migrate baseline")
+	ErrNoSchema       = errors.New("database has no schema to baseline")
+	ErrVersioned      = errors.New("database already has applied migrations")
+)
+
+// Migration is pair of SQL scripts which change schema to version and back
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Status is migration with time it was applied, nil if it is pending
+type Status struct {
+	*Migration
+	Applied *time.Time
+}
+
+type Migrator struct {
+	pool       *pgxpool.Pool
+	migrations []*Migration
+}
+
+// New returns migrator of migrations embedded in binary
+func New(pool *pgxpool.Pool) (*Migrator, error) {
+	sub, err := fs.Sub(migrations, "migrations")
+	if err != nil {
+		return nil, err
+	}
+	list, err := Load(sub)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{pool: pool, migrations: list}, nil
+}
+
+// Load reads migrations from root of fsys sorted by version. Every version must have both up and down file
+func Load(fsys fs.FS) ([]*Migration, error) {
+	names, err := fs.Glob(fsys, "*.sql")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int64]*Migration{}
+	for _, name := range names {
+		base := strings.TrimSuffix(path.Base(name), ".sql")
+		direction := path.Ext(base)
+		base = strings.TrimSuffix(base, direction)
+		parts := strings.SplitN(base, "_", 2)
+		if (direction != ".up" && direction != ".down") || len(parts) != 2 || parts[1] == "" {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidName, name)
+		}
+		version, err := strconv.ParseInt(parts[0], 10, 64)
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidName, name)
+		}
+
+		data, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return nil, err
+		}
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: parts[1]}
+			byVersion[version] = migration
+		}
+		if migration.Name != parts[1] {
+			return nil, fmt.Errorf("%w: %s", ErrDuplicate, name)
+		}
+		script := &migration.Up
+		if direction == ".down" {
+			script = &migration.Down
+		}
+		if *script != "" {
+			return nil, fmt.Errorf("%w: %s", ErrDuplicate, name)
+		}
+		*script = string(data)
+	}
+
+	list := make([]*Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Up == "" || migration.Down == "" {
+			return nil, fmt.Errorf("%w: %d_%s", ErrMissingDown, migration.Version, migration.Name)
+		}
+		list = append(list, migration)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Version < list[j].Version
+	})
+	return list, nil
+}
+
+// Up applies pending migrations in order, each in its own transaction, and returns applied ones
+func (m *Migrator) Up(ctx context.Context) ([]*Migration, error) {
+	var done []*Migration
+	err := m.locked(ctx, func(conn *pgxpool.Conn) error {
+		applied, err := m.applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+		if len(applied) == 0 {
+			exists, err := baselineExists(ctx, conn)
+			if err != nil {
+				return err
+			}
+			if exists {
+				return ErrNotVersioned
+			}
+		}
+		for _, migration := range m.migrations {
+			if _, ok := applied[migration.Version]; ok {
+				continue
+			}
+			err = m.apply(ctx, conn, migration.Up, `INSERT INTO schema_migrations (version, name) VALUES ($1, $2)`, migration.Version, migration.Name)
+			if err != nil {
+				return fmt.Errorf("migration %d_%s up: %w", migration.Version, migration.Name, err)
+			}
+			done = append(done, migration)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Down reverts up to steps last applied migrations in reverse order and returns reverted ones
+func (m *Migrator) Down(ctx context.Context, steps int) ([]*Migration, error) {
+	var done []*Migration
+	err := m.locked(ctx, func(conn *pgxpool.Conn) error {
+		applied, err := m.applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for i := len(m.migrations) - 1; i >= 0 && len(done) < steps; i-- {
+			migration := m.migrations[i]
+			if _, ok := applied[migration.Version]; !ok {
+				continue
+			}
+			err = m.apply(ctx, conn, migration.Down, `DELETE FROM schema_migrations WHERE version = $1`, migration.Version)
+			if err != nil {
+				return fmt.Errorf("migration %d_%s down: %w", migration.Version, migration.Name, err)
+			}
+			done = append(done, migration)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Baseline marks the first migration as applied without running it. It adopts database created from schema.sql
+// before migrations were introduced, so Up applies only later migrations to it
+func (m *Migrator) Baseline(ctx context.Context) (*Migration, error) {
+	baseline := m.migrations[0]
+	err := m.locked(ctx, func(conn *pgxpool.Conn) error {
+		applied, err := m.applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+		if len(applied) != 0 {
+			return ErrVersioned
+		}
+		exists, err := baselineExists(ctx, conn)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return ErrNoSchema
+		}
+		_, err = conn.Exec(ctx, `INSERT INTO schema_migrations (version, name) VALUES ($1, $2)`, baseline.Version, baseline.Name)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return baseline, nil
+}
+
+// Status returns all migrations with time they were applied
+func (m *Migrator) Status(ctx context.Context) ([]*Status, error) {
+	var list []*Status
+	err := m.locked(ctx, func(conn *pgxpool.Conn) error {
+		applied, err := m.applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, migration := range m.migrations {
+			status := &Status{Migration: migration}
+			if created, ok := applied[migration.Version]; ok {
+				status.Applied = &created
+			}
+			list = append(list, status)
+		}
+		return nil
+	})
+	return list, err
+}
+
+// locked runs fn on one connection holding advisory lock, which waits for migrations of other instance to finish
+func (m *Migrator) locked(ctx context.Context, fn func(conn *pgxpool.Conn) error) error {
+	conn, err := m.pool.Acquire(ctx)
+	if err != nil {
+		log.Println("locked m.pool.Acquire error:", err)
+		return err
+	}
+	defer conn.Release()
+
+	_, err = conn.Exec(ctx, `SELECT pg_advisory_lock($1)`, lockID)
+	if err != nil {
+		log.Println("locked pg_advisory_lock error:", err)
+		return err
+	}
+	defer func() {
+		_, err := conn.Exec(context.Background(), `SELECT pg_advisory_unlock($1)`, lockID)
+		if err != nil {
+			log.Println("locked pg_advisory_unlock error:", err)
+		}
+	}()
+
+	_, err = conn.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version BIGINT PRIMARY KEY,
+			name TEXT NOT NULL,
+			applied TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+		)
+	`)
+	if err != nil {
+		log.Println("locked create schema_migrations error:", err)
+		return err
+	}
+	return fn(conn)
+}
+
+// applied returns versions of applied migrations with time they were applied.
+// Version unknown to binary means database is newer than binary, so nothing is changed
+func (m *Migrator) applied(ctx context.Context, conn *pgxpool.Conn) (map[int64]time.Time, error) {
+	rows, err := conn.Query(ctx, `SELECT version, applied FROM schema_migrations`)
+	if err != nil {
+		log.Println("applied conn.Query error:", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	known := map[int64]bool{}
+	for _, migration := range m.migrations {
+		known[migration.Version] = true
+	}
+
+	applied := map[int64]time.Time{}
+	for rows.Next() {
+		var version int64
+		var created time.Time
+		err = rows.Scan(&version, &created)
+		if err != nil {
+			log.Println("applied rows.Scan error:", err)
+			return nil, err
+		}
+		if !known[version] {
+			return nil, fmt.Errorf("%w: %d", ErrUnknownVersion, version)
+		}
+		applied[version] = created
+	}
+	return applied, rows.Err()
+}
+
+// baselineExists reports if tables of the first migration exist in current schema
+func baselineExists(ctx context.Context, conn *pgxpool.Conn) (bool, error) {
+	var exists bool
+	err := conn.QueryRow(ctx, `SELECT to_regclass('accounts') IS NOT NULL`).Scan(&exists)
+	if err != nil {
+		log.Println("baselineExists conn.QueryRow error:", err)
+	}
+	return exists, err
+}
+
+// apply runs script and bookkeeping statement of schema_migrations in one transaction
+func (m *Migrator) apply(ctx context.Context, conn *pgxpool.Conn, script string, query string, args ...interface{}) error {
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	// script without arguments is sent by simple protocol, so it may contain several statements
+	_, err = tx.Exec(ctx, script)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
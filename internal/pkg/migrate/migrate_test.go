@@ -0,0 +1,236 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"reflect"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+func TestLoad_Embedded(t *testing.T) {
+	sub, err := fs.Sub(migrations, "migrations")
+	if err != nil {
+		t.Fatalf("fs.Sub error: %v", err)
+	}
+	list, err := Load(sub)
+	if err != nil {
+		t.Fatalf("Load error: %v", err)
+	}
+	if len(list) == 0 {
+		t.Fatal("no embedded migrations")
+	}
+	for i, migration := range list {
+		if migration.Version != int64(i+1) {
+			t.Errorf("migration %d has version %d, want %d", i, migration.Version, i+1)
+		}
+	}
+}
+
+func TestLoad_SortsByVersion(t *testing.T) {
+	list, err := Load(fstest.MapFS{
+		"0010_fees.up.sql":   {Data: []byte("ALTER TABLE a ADD fee INTEGER;")},
+		"0010_fees.down.sql": {Data: []byte("ALTER TABLE a DROP fee;")},
+		"0002_init.up.sql":   {Data: []byte("CREATE TABLE a (id INTEGER);")},
+		"0002_init.down.sql": {Data: []byte("DROP TABLE a;")},
+	})
+	if err != nil {
+		t.Fatalf("Load error: %v", err)
+	}
+	if len(list) != 2 || list[0].Version != 2 || list[1].Version != 10 {
+		t.Fatalf("Load = %+v, want versions 2 and 10", list)
+	}
+	if list[1].Name != "fees" || list[1].Down != "ALTER TABLE a DROP fee;" {
+		t.Errorf("Load migration 10 = %+v", list[1])
+	}
+}
+
+func TestLoad_Errors(t *testing.T) {
+	tests := []struct {
+		name  string
+		files fstest.MapFS
+		want  error
+	}{
+		{"no version", fstest.MapFS{"init.up.sql": {Data: []byte("SELECT 1;")}}, ErrInvalidName},
+		{"no direction", fstest.MapFS{"0001_init.sql": {Data: []byte("SELECT 1;")}}, ErrInvalidName},
+		{"missing down", fstest.MapFS{"0001_init.up.sql": {Data: []byte("SELECT 1;")}}, ErrMissingDown},
+		{"duplicate version", fstest.MapFS{
+			"0001_init.up.sql":  {Data: []byte("SELECT 1;")},
+			"0001_other.up.sql": {Data: []byte("SELECT 1;")},
+		}, ErrDuplicate},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Load(tt.files)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("Load error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+// newTestMigrator connects to database from GOWALLET_TEST_DSN with search path of new empty schema,
+// so migrations don't touch schema of other tests. Schema is dropped on cleanup
+func newTestMigrator(t *testing.T) (*Migrator, *pgxpool.Pool) {
+	t.Helper()
+	dsn := os.Getenv("GOWALLET_TEST_DSN")
+	if dsn == "" {
+		t.Skip("GOWALLET_TEST_DSN is not set")
+	}
+	ctx := context.Background()
+
+	schema := fmt.Sprintf("migrate_test_%d", time.Now().UnixNano())
+	admin, err := pgxpool.Connect(ctx, dsn)
+	if err != nil {
+		t.Fatalf("pgxpool.Connect error: %v", err)
+	}
+	t.Cleanup(admin.Close)
+	if _, err = admin.Exec(ctx, `CREATE SCHEMA `+schema); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if _, err := admin.Exec(context.Background(), `DROP SCHEMA `+schema+` CASCADE`); err != nil {
+			t.Errorf("drop schema error: %v", err)
+		}
+	})
+
+	config, err := pgxpool.ParseConfig(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.ConnConfig.RuntimeParams["search_path"] = schema
+	pool, err := pgxpool.ConnectConfig(ctx, config)
+	if err != nil {
+		t.Fatalf("pgxpool.ConnectConfig error: %v", err)
+	}
+	t.Cleanup(pool.Close)
+
+	migrator, err := New(pool)
+	if err != nil {
+		t.Fatalf("New error: %v", err)
+	}
+	return migrator, pool
+}
+
+// columns returns table.column: type of every column of current schema
+func columns(t *testing.T, pool *pgxpool.Pool) map[string]string {
+	t.Helper()
+	rows, err := pool.Query(context.Background(), `
+		SELECT table_name, column_name, data_type, is_nullable FROM information_schema.columns
+		WHERE table_schema = current_schema() AND table_name <> 'schema_migrations'
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	list := map[string]string{}
+	for rows.Next() {
+		var table, column, dataType, nullable string
+		if err = rows.Scan(&table, &column, &dataType, &nullable); err != nil {
+			t.Fatal(err)
+		}
+		list[table+"."+column] = dataType + " " + nullable
+	}
+	if err = rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return list
+}
+
+func TestMigrator_UpDownUp(t *testing.T) {
+	m, pool := newTestMigrator(t)
+	ctx := context.Background()
+	count := len(m.migrations)
+
+	done, err := m.Up(ctx)
+	if err != nil || len(done) != count {
+		t.Fatalf("Up = %d migrations, %v, want %d", len(done), err, count)
+	}
+	schema := columns(t, pool)
+	if len(schema) == 0 {
+		t.Fatal("no columns after Up")
+	}
+
+	// account of baseline schema with balance and identification keeps them through later migrations
+	done, err = m.Down(ctx, count-1)
+	if err != nil || len(done) != count-1 {
+		t.Fatalf("Down to baseline = %d migrations, %v, want %d", len(done), err, count-1)
+	}
+	_, err = pool.Exec(ctx, `INSERT INTO accounts (balance, identified, name, phone, password) VALUES (500, TRUE, 'test', '992000000001', 'hash')`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	done, err = m.Up(ctx)
+	if err != nil || len(done) != count-1 {
+		t.Fatalf("Up from baseline = %d migrations, %v, want %d", len(done), err, count-1)
+	}
+	var balance, posted int64
+	var tier, currency string
+	err = pool.QueryRow(ctx, `
+		SELECT a.balance, a.tier, a.currency, (SELECT COALESCE(SUM(amount), 0) FROM postings WHERE acc_id = a.id)
+		FROM accounts a WHERE phone = '992000000001'
+	`).Scan(&balance, &tier, &currency, &posted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if balance != 500 || posted != 500 || tier != "full" || currency != "TJS" {
+		t.Errorf("migrated account = balance %d, postings %d, tier %s, currency %s, want 500, 500, full, TJS", balance, posted, tier, currency)
+	}
+	var unbalanced int
+	if err = pool.QueryRow(ctx, `SELECT COUNT(*) FROM (SELECT entry_id FROM postings GROUP BY entry_id HAVING SUM(amount) <> 0) e`).Scan(&unbalanced); err != nil || unbalanced != 0 {
+		t.Errorf("unbalanced journal entries = %d, %v, want 0", unbalanced, err)
+	}
+
+	done, err = m.Down(ctx, count)
+	if err != nil || len(done) != count {
+		t.Fatalf("Down = %d migrations, %v, want %d", len(done), err, count)
+	}
+	if left := columns(t, pool); len(left) != 0 {
+		t.Errorf("columns after Down = %v, want none", left)
+	}
+
+	done, err = m.Up(ctx)
+	if err != nil || len(done) != count {
+		t.Fatalf("Up after Down = %d migrations, %v, want %d", len(done), err, count)
+	}
+	if again := columns(t, pool); !reflect.DeepEqual(again, schema) {
+		t.Errorf("schema after up, down and up = %v, want %v", again, schema)
+	}
+}
+
+func TestMigrator_BaselineOfSchemaWithoutMigrations(t *testing.T) {
+	m, pool := newTestMigrator(t)
+	ctx := context.Background()
+	count := len(m.migrations)
+
+	if _, err := m.Baseline(ctx); err != ErrNoSchema {
+		t.Errorf("Baseline of empty database error = %v, want %v", err, ErrNoSchema)
+	}
+
+	// database created from schema.sql has baseline tables, but no applied migrations
+	if _, err := pool.Exec(ctx, m.migrations[0].Up); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Up(ctx); err != ErrNotVersioned {
+		t.Fatalf("Up of database without migrations error = %v, want %v", err, ErrNotVersioned)
+	}
+	baseline, err := m.Baseline(ctx)
+	if err != nil || baseline.Version != m.migrations[0].Version {
+		t.Fatalf("Baseline = %+v, %v, want the first migration", baseline, err)
+	}
+	if _, err = m.Baseline(ctx); err != ErrVersioned {
+		t.Errorf("second Baseline error = %v, want %v", err, ErrVersioned)
+	}
+
+	done, err := m.Up(ctx)
+	if err != nil || len(done) != count-1 {
+		t.Fatalf("Up after Baseline = %d migrations, %v, want %d", len(done), err, count-1)
+	}
+}
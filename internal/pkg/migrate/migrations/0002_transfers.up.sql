@@ -0,0 +1,2 @@
+--transfer transactions reference the opposite transaction of transfer
+ALTER TABLE transactions ADD COLUMN ref_id BIGINT REFERENCES transactions;
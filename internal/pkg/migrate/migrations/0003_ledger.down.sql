@@ -0,0 +1,3 @@
+ALTER TABLE transactions DROP COLUMN entry_id;
+DROP TABLE postings;
+DROP TABLE journal_entries;
@@ -0,0 +1,28 @@
+--table of journal entries of double-entry ledger
+CREATE TABLE journal_entries
+(
+    id BIGSERIAL PRIMARY KEY,
+    kind TEXT NOT NULL,
+    created TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+--table of postings, wallet (acc_id) or system (cash_in, cash_out, fee) side of journal entry
+CREATE TABLE postings
+(
+    id BIGSERIAL PRIMARY KEY,
+    entry_id BIGINT NOT NULL REFERENCES journal_entries,
+    acc_id BIGINT REFERENCES accounts,
+    system TEXT,
+    amount BIGINT NOT NULL,
+    CHECK ((acc_id IS NULL) <> (system IS NULL))
+);
+
+ALTER TABLE transactions ADD COLUMN entry_id BIGINT REFERENCES journal_entries;
+
+-- existing balances are posted as opening entries from cash_in, so balances equal sums of postings of accounts
+CREATE TEMPORARY TABLE opening_balances ON COMMIT DROP AS
+SELECT id AS acc_id, balance, nextval('journal_entries_id_seq') AS entry_id FROM accounts WHERE balance <> 0;
+
+INSERT INTO journal_entries (id, kind) SELECT entry_id, 'opening' FROM opening_balances;
+INSERT INTO postings (entry_id, acc_id, amount) SELECT entry_id, acc_id, balance FROM opening_balances;
+INSERT INTO postings (entry_id, system, amount) SELECT entry_id, 'cash_in', -balance FROM opening_balances;
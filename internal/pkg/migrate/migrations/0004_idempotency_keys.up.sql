@@ -0,0 +1,11 @@
+--table of idempotency keys of money-moving requests with stored results
+CREATE TABLE idempotency_keys
+(
+    acc_id BIGINT NOT NULL REFERENCES accounts,
+    endpoint TEXT NOT NULL,
+    key TEXT NOT NULL,
+    request_hash TEXT NOT NULL,
+    response JSONB,
+    created TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (acc_id, endpoint, key)
+);
@@ -0,0 +1,22 @@
+--table of partner integrations, endpoints are allowed route templates, '*' for all but operator routes
+--and 'operator' for all operator routes
+CREATE TABLE partners
+(
+    id TEXT PRIMARY KEY,
+    name TEXT NOT NULL,
+    endpoints TEXT[] NOT NULL DEFAULT '{}',
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    created TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+--table of partner keys, keys of partner overlap in validity while key is rotated
+CREATE TABLE partner_keys
+(
+    partner_id TEXT NOT NULL REFERENCES partners,
+    key_id TEXT NOT NULL,
+    secret TEXT NOT NULL,
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    valid_from TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    valid_to TIMESTAMP,
+    PRIMARY KEY (partner_id, key_id)
+);
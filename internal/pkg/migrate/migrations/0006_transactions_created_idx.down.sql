@@ -0,0 +1 @@
+DROP INDEX transactions_acc_id_created_idx;
@@ -0,0 +1,2 @@
+--statements and history select transactions of account by created
+CREATE INDEX transactions_acc_id_created_idx ON transactions (acc_id, created);
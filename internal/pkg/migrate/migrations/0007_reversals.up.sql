@@ -0,0 +1,4 @@
+--reversal transactions reference reversed transaction
+ALTER TABLE transactions ADD COLUMN reversal_of BIGINT REFERENCES transactions;
+
+CREATE INDEX transactions_reversal_of_idx ON transactions (reversal_of);
@@ -0,0 +1,2 @@
+ALTER TABLE transactions DROP COLUMN hold_id;
+DROP TABLE holds;
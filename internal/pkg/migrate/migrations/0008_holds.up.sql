@@ -0,0 +1,16 @@
+--table of holds, reserved funds which are not available until hold is captured, voided or expired
+CREATE TABLE holds
+(
+    id BIGSERIAL PRIMARY KEY,
+    acc_id BIGINT NOT NULL REFERENCES accounts,
+    partner_id TEXT NOT NULL,
+    amount BIGINT NOT NULL CHECK (amount > 0),
+    captured BIGINT NOT NULL DEFAULT 0,
+    status TEXT NOT NULL DEFAULT 'active',
+    expires TIMESTAMP NOT NULL,
+    created TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX holds_acc_id_status_idx ON holds (acc_id, status);
+
+ALTER TABLE transactions ADD COLUMN hold_id BIGINT REFERENCES holds;
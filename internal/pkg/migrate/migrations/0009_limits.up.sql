@@ -0,0 +1,21 @@
+--table of limit rules of identification tiers: max_balance, max_operation, daily_turnover, monthly_turnover
+--of credit, debit or any operations
+CREATE TABLE limits
+(
+    id BIGSERIAL PRIMARY KEY,
+    tier TEXT NOT NULL,
+    kind TEXT NOT NULL,
+    direction TEXT NOT NULL DEFAULT 'any',
+    amount BIGINT NOT NULL,
+    active BOOLEAN NOT NULL DEFAULT TRUE
+);
+
+-- default limits in dirams
+INSERT INTO limits (tier, kind, direction, amount) VALUES 
+('unidentified', 'max_balance', 'credit', 1000000),
+('unidentified', 'max_operation', 'any', 1000000),
+('unidentified', 'monthly_turnover', 'any', 5000000),
+('identified', 'max_balance', 'credit', 10000000),
+('identified', 'max_operation', 'any', 10000000),
+('identified', 'daily_turnover', 'any', 15000000),
+('identified', 'monthly_turnover', 'any', 100000000);
@@ -0,0 +1,7 @@
+DELETE FROM limits WHERE tier = 'simplified';
+UPDATE limits SET tier = 'identified' WHERE tier = 'full';
+UPDATE limits SET tier = 'unidentified' WHERE tier = 'anonymous';
+
+DROP TABLE identifications;
+
+ALTER TABLE accounts DROP COLUMN tier;
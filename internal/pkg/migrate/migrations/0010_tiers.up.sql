@@ -0,0 +1,33 @@
+--identified accounts are fully identified, others are anonymous
+ALTER TABLE accounts ADD COLUMN tier TEXT NOT NULL DEFAULT 'anonymous';
+
+UPDATE accounts SET tier = 'full' WHERE identified;
+
+--table of identification requests: status is pending, verified or rejected
+CREATE TABLE identifications
+(
+    id BIGSERIAL PRIMARY KEY,
+    acc_id BIGINT NOT NULL REFERENCES accounts,
+    tier TEXT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending',
+    full_name TEXT NOT NULL,
+    birth_date TEXT NOT NULL,
+    document_type TEXT NOT NULL,
+    document_number TEXT NOT NULL,
+    documents TEXT[] NOT NULL DEFAULT '{}',
+    reason TEXT NOT NULL DEFAULT '',
+    reviewed_by TEXT NOT NULL DEFAULT '',
+    created TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    reviewed TIMESTAMP
+);
+
+CREATE INDEX identifications_acc_id_idx ON identifications (acc_id);
+
+--limit rules of identification tiers (anonymous, simplified, full)
+UPDATE limits SET tier = 'anonymous' WHERE tier = 'unidentified';
+UPDATE limits SET tier = 'full' WHERE tier = 'identified';
+
+INSERT INTO limits (tier, kind, direction, amount) VALUES 
+('simplified', 'max_balance', 'credit', 3000000),
+('simplified', 'max_operation', 'any', 3000000),
+('simplified', 'monthly_turnover', 'any', 20000000);
@@ -0,0 +1,14 @@
+-- wallets in other currencies have no phone, so migration fails while they exist
+DELETE FROM limits WHERE currency <> 'TJS';
+ALTER TABLE limits DROP COLUMN currency;
+
+ALTER TABLE transactions DROP COLUMN currency;
+ALTER TABLE holds DROP COLUMN currency;
+ALTER TABLE postings DROP COLUMN currency;
+
+DROP INDEX accounts_owner_currency_idx;
+ALTER TABLE accounts ALTER COLUMN phone SET NOT NULL;
+ALTER TABLE accounts DROP COLUMN currency;
+ALTER TABLE accounts DROP COLUMN owner_id;
+
+DROP TABLE currencies;
@@ -0,0 +1,60 @@
+-- table of currencies, amounts are stored in minor units (10^minor_units of currency unit)
+CREATE TABLE currencies (
+    code TEXT PRIMARY KEY,
+    name TEXT NOT NULL,
+    minor_units INTEGER NOT NULL
+);
+
+INSERT INTO currencies (code, name, minor_units) VALUES 
+('TJS', 'Tajikistani somoni', 2),
+('USD', 'US dollar', 2),
+('EUR', 'Euro', 2),
+('RUB', 'Russian ruble', 2);
+
+-- every account is wallet in one currency, existing accounts are main wallets in TJS.
+-- Wallets in other currencies of the same user have owner_id of user's main account and no phone
+ALTER TABLE accounts ADD COLUMN owner_id BIGINT REFERENCES accounts;
+ALTER TABLE accounts ADD COLUMN currency TEXT NOT NULL DEFAULT 'TJS' REFERENCES currencies;
+ALTER TABLE accounts ALTER COLUMN phone DROP NOT NULL;
+
+CREATE UNIQUE INDEX accounts_owner_currency_idx ON accounts (COALESCE(owner_id, id), currency);
+
+ALTER TABLE postings ADD COLUMN currency TEXT NOT NULL DEFAULT 'TJS' REFERENCES currencies;
+ALTER TABLE holds ADD COLUMN currency TEXT NOT NULL DEFAULT 'TJS' REFERENCES currencies;
+ALTER TABLE transactions ADD COLUMN currency TEXT NOT NULL DEFAULT 'TJS' REFERENCES currencies;
+
+-- limit rules apply to wallets in currency, existing ones to TJS
+ALTER TABLE limits ADD COLUMN currency TEXT NOT NULL DEFAULT 'TJS' REFERENCES currencies;
+
+-- default limits in minor units
+INSERT INTO limits (tier, currency, kind, direction, amount) VALUES 
+('anonymous', 'USD', 'max_balance', 'credit', 100000),
+('anonymous', 'USD', 'max_operation', 'any', 100000),
+('anonymous', 'USD', 'monthly_turnover', 'any', 500000),
+('simplified', 'USD', 'max_balance', 'credit', 300000),
+('simplified', 'USD', 'max_operation', 'any', 300000),
+('simplified', 'USD', 'monthly_turnover', 'any', 2000000),
+('full', 'USD', 'max_balance', 'credit', 1000000),
+('full', 'USD', 'max_operation', 'any', 1000000),
+('full', 'USD', 'daily_turnover', 'any', 1500000),
+('full', 'USD', 'monthly_turnover', 'any', 10000000),
+('anonymous', 'EUR', 'max_balance', 'credit', 100000),
+('anonymous', 'EUR', 'max_operation', 'any', 100000),
+('anonymous', 'EUR', 'monthly_turnover', 'any', 500000),
+('simplified', 'EUR', 'max_balance', 'credit', 300000),
+('simplified', 'EUR', 'max_operation', 'any', 300000),
+('simplified', 'EUR', 'monthly_turnover', 'any', 2000000),
+('full', 'EUR', 'max_balance', 'credit', 1000000),
+('full', 'EUR', 'max_operation', 'any', 1000000),
+('full', 'EUR', 'daily_turnover', 'any', 1500000),
+('full', 'EUR', 'monthly_turnover', 'any', 10000000),
+('anonymous', 'RUB', 'max_balance', 'credit', 6000000),
+('anonymous', 'RUB', 'max_operation', 'any', 6000000),
+('anonymous', 'RUB', 'monthly_turnover', 'any', 30000000),
+('simplified', 'RUB', 'max_balance', 'credit', 18000000),
+('simplified', 'RUB', 'max_operation', 'any', 18000000),
+('simplified', 'RUB', 'monthly_turnover', 'any', 120000000),
+('full', 'RUB', 'max_balance', 'credit', 60000000),
+('full', 'RUB', 'max_operation', 'any', 60000000),
+('full', 'RUB', 'daily_turnover', 'any', 90000000),
+('full', 'RUB', 'monthly_turnover', 'any', 600000000);
@@ -0,0 +1,2 @@
+ALTER TABLE transactions DROP COLUMN rate;
+DROP TABLE exchange_rates;
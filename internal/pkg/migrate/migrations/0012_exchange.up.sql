@@ -0,0 +1,19 @@
+--table of exchange rates: one unit of base currency costs rate units of quote currency in [valid_from, valid_to).
+--spread is fraction of converted amount kept by wallet, conversion in opposite direction uses inverse rate
+CREATE TABLE exchange_rates
+(
+    id BIGSERIAL PRIMARY KEY,
+    base TEXT NOT NULL REFERENCES currencies,
+    quote TEXT NOT NULL REFERENCES currencies,
+    rate NUMERIC(24, 12) NOT NULL CHECK (rate > 0),
+    spread NUMERIC(8, 6) NOT NULL DEFAULT 0 CHECK (spread >= 0 AND spread < 1),
+    valid_from TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    valid_to TIMESTAMP,
+    created TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CHECK (base <> quote)
+);
+
+CREATE INDEX exchange_rates_pair_idx ON exchange_rates (base, quote, valid_from);
+
+--rate is applied exchange rate of exchange transactions
+ALTER TABLE transactions ADD COLUMN rate TEXT;
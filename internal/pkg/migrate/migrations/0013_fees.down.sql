@@ -0,0 +1,2 @@
+ALTER TABLE transactions DROP COLUMN fee;
+DROP TABLE fees;
@@ -0,0 +1,26 @@
+--table of fee schedules of top_up, withdrawal and transfer operations in currency, for one tier or all tiers (NULL).
+--rule applies to amounts in [min_amount, max_amount), fee is fixed + percent of amount, limited by min_fee and max_fee
+CREATE TABLE fees
+(
+    id BIGSERIAL PRIMARY KEY,
+    operation TEXT NOT NULL,
+    tier TEXT,
+    currency TEXT NOT NULL DEFAULT 'TJS' REFERENCES currencies,
+    min_amount BIGINT NOT NULL DEFAULT 0,
+    max_amount BIGINT,
+    fixed BIGINT NOT NULL DEFAULT 0 CHECK (fixed >= 0),
+    percent NUMERIC(8, 4) NOT NULL DEFAULT 0 CHECK (percent >= 0),
+    min_fee BIGINT NOT NULL DEFAULT 0 CHECK (min_fee >= 0),
+    max_fee BIGINT,
+    active BOOLEAN NOT NULL DEFAULT TRUE
+);
+
+-- default fees in minor units: top-ups are free, withdrawals cost 1% (1-50 TJS),
+-- transfers of not fully identified wallets cost 0.5% over 1000 TJS
+INSERT INTO fees (operation, tier, currency, min_amount, max_amount, fixed, percent, min_fee, max_fee) VALUES 
+('withdrawal', NULL, 'TJS', 0, NULL, 0, 1, 100, 5000),
+('transfer', NULL, 'TJS', 100000, NULL, 0, 0.5, 0, 10000),
+('transfer', 'full', 'TJS', 0, NULL, 0, 0, 0, NULL);
+
+--fee is charged from wallet in addition to amount
+ALTER TABLE transactions ADD COLUMN fee BIGINT NOT NULL DEFAULT 0;
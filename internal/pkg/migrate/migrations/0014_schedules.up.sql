@@ -0,0 +1,37 @@
+--table of recurring payments: kind is transaction (top-up or withdrawal by sign of amount) or transfer to phone,
+--period is daily, weekly, monthly or duration like '36h'. Anchor is the first occurrence, monthly schedule keeps its day
+--of month. Failed run is retried at retry_at
+CREATE TABLE schedules
+(
+    id BIGSERIAL PRIMARY KEY,
+    acc_id BIGINT NOT NULL REFERENCES accounts,
+    kind TEXT NOT NULL,
+    phone TEXT NOT NULL DEFAULT '',
+    currency TEXT NOT NULL DEFAULT '',
+    amount BIGINT NOT NULL,
+    period TEXT NOT NULL,
+    next_run TIMESTAMP NOT NULL,
+    anchor TIMESTAMP NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    retry_at TIMESTAMP,
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    created TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX schedules_acc_id_idx ON schedules (acc_id);
+CREATE INDEX schedules_next_run_idx ON schedules (next_run) WHERE active;
+
+--table of outcomes of schedule runs, due is planned time of occurrence
+CREATE TABLE schedule_runs
+(
+    id BIGSERIAL PRIMARY KEY,
+    schedule_id BIGINT NOT NULL REFERENCES schedules ON DELETE CASCADE,
+    due TIMESTAMP NOT NULL,
+    attempt INTEGER NOT NULL,
+    status TEXT NOT NULL,
+    error TEXT NOT NULL DEFAULT '',
+    transaction_id BIGINT REFERENCES transactions,
+    created TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX schedule_runs_schedule_id_idx ON schedule_runs (schedule_id);
@@ -0,0 +1,88 @@
+package partner
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/SYSTEMTerror/GoWallet/internal/pkg/types"
+)
+
+// MemoryRepository stores partners in memory, it is meant for tests and local runs
+type MemoryRepository struct {
+	mu       sync.RWMutex
+	partners map[string]memoryPartner
+	keys     []types.PartnerKey
+}
+
+type memoryPartner struct {
+	endpoints []string
+	active    bool
+}
+
+func NewMemoryRepository() *MemoryRepository {
+	return &MemoryRepository{partners: map[string]memoryPartner{}}
+}
+
+// AddPartner adds or replaces partner allowed to call endpoints
+func (r *MemoryRepository) AddPartner(id string, active bool, endpoints ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.partners[id] = memoryPartner{endpoints: append([]string(nil), endpoints...), active: active}
+}
+
+// AddKey adds key as is, zero ValidFrom makes key valid from now
+func (r *MemoryRepository) AddKey(key *types.PartnerKey) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if key.ValidFrom.IsZero() {
+		key.ValidFrom = time.Now()
+	}
+	r.keys = append(r.keys, *key)
+}
+
+func (r *MemoryRepository) Key(ctx context.Context, partnerID string, keyID string) (*types.PartnerKey, []string, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	p, ok := r.partners[partnerID]
+	if !ok || !p.active {
+		return nil, nil, ErrNotFound
+	}
+	now := time.Now()
+	for _, key := range r.keys {
+		if key.PartnerID != partnerID || key.KeyID != keyID {
+			continue
+		}
+		if !key.Active || key.ValidFrom.After(now) || (key.ValidTo != nil && !key.ValidTo.After(now)) {
+			return nil, nil, ErrNotFound
+		}
+		return &key, append([]string(nil), p.endpoints...), nil
+	}
+	return nil, nil, ErrNotFound
+}
+
+func (r *MemoryRepository) RotateKey(ctx context.Context, key *types.PartnerKey, overlap time.Duration) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.partners[key.PartnerID]; !ok {
+		return ErrNotFound
+	}
+	for _, k := range r.keys {
+		if k.PartnerID == key.PartnerID && k.KeyID == key.KeyID {
+			return ErrExist
+		}
+	}
+
+	now := time.Now()
+	validTo := now.Add(overlap)
+	for i, k := range r.keys {
+		if k.PartnerID == key.PartnerID && k.Active && (k.ValidTo == nil || k.ValidTo.After(validTo)) {
+			r.keys[i].ValidTo = &validTo
+		}
+	}
+	key.ValidFrom = now
+	r.keys = append(r.keys, *key)
+	return nil
+}
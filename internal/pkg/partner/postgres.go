@@ -0,0 +1,76 @@
+package partner
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/SYSTEMTerror/GoWallet/internal/pkg/types"
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// Postgres error codes of constraint violations
+const (
+	foreignKeyViolation = "23503"
+	uniqueViolation     = "23505"
+)
+
+// PostgresRepository stores partners in Postgres database with schema of migrate package
+type PostgresRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewPostgresRepository(pool *pgxpool.Pool) *PostgresRepository {
+	return &PostgresRepository{pool: pool}
+}
+
+func (r *PostgresRepository) Key(ctx context.Context, partnerID string, keyID string) (*types.PartnerKey, []string, error) {
+	key := &types.PartnerKey{PartnerID: partnerID, KeyID: keyID}
+	var endpoints []string
+	err := r.pool.QueryRow(ctx, `
+		SELECT k.secret, k.active, k.valid_from, k.valid_to, p.endpoints FROM partner_keys k JOIN partners p ON p.id = k.partner_id
+		WHERE k.partner_id = $1 AND k.key_id = $2 AND p.active AND k.active
+		AND k.valid_from <= CURRENT_TIMESTAMP AND (k.valid_to IS NULL OR k.valid_to > CURRENT_TIMESTAMP)
+	`, partnerID, keyID).Scan(&key.Secret, &key.Active, &key.ValidFrom, &key.ValidTo, &endpoints)
+	if err == pgx.ErrNoRows {
+		return nil, nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return key, endpoints, nil
+}
+
+func (r *PostgresRepository) RotateKey(ctx context.Context, key *types.PartnerKey, overlap time.Duration) error {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, `
+		UPDATE partner_keys SET valid_to = CURRENT_TIMESTAMP + $2::interval
+		WHERE partner_id = $1 AND active AND (valid_to IS NULL OR valid_to > CURRENT_TIMESTAMP + $2::interval)
+	`, key.PartnerID, overlap)
+	if err != nil {
+		return err
+	}
+
+	err = tx.QueryRow(ctx, `
+		INSERT INTO partner_keys (partner_id, key_id, secret, active) VALUES ($1, $2, $3, $4) RETURNING valid_from
+	`, key.PartnerID, key.KeyID, key.Secret, key.Active).Scan(&key.ValidFrom)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == foreignKeyViolation {
+		return ErrNotFound
+	}
+	if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation {
+		return ErrExist
+	}
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
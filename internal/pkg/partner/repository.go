@@ -0,0 +1,17 @@
+package partner
+
+import (
+	"context"
+	"time"
+
+	"github.com/SYSTEMTerror/GoWallet/internal/pkg/types"
+)
+
+// Repository is storage of Service. Missing rows are reported with ErrNotFound, other errors are internal
+type Repository interface {
+	// Key returns key of active partner with endpoints of partner, if key is active and valid now
+	Key(ctx context.Context, partnerID string, keyID string) (*types.PartnerKey, []string, error)
+	// RotateKey limits validity of active keys of partner to overlap from now and inserts key valid from now,
+	// filling its ValidFrom. Partner must exist (ErrNotFound), key id is unique within partner (ErrExist)
+	RotateKey(ctx context.Context, key *types.PartnerKey, overlap time.Duration) error
+}
@@ -0,0 +1,103 @@
+package partner
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/SYSTEMTerror/GoWallet/internal/pkg/types"
+)
+
+// Permissions in partner endpoints besides route templates
+const (
+	// AllEndpoints allows every endpoint except operator ones
+	AllEndpoints = "*"
+	// OperatorEndpoints allows every endpoint of OperatorPrefix, operator routes are never allowed by AllEndpoints
+	OperatorEndpoints = "operator"
+)
+
+// OperatorPrefix is prefix of route templates of operator endpoints
+const OperatorPrefix = "/api/operator/"
+
+const secretLen = 32
+
+var (
+	ErrNotFound        = errors.New("partner key not found")
+	ErrPartnerNotFound = errors.New("partner not found")
+	ErrExist           = errors.New("partner key already exists")
+	ErrInvalidKey      = errors.New("invalid partner key")
+	ErrForbidden       = errors.New("endpoint not allowed for partner")
+	ErrInternal        = errors.New("internal error")
+)
+
+type Service struct {
+	repo Repository
+}
+
+func NewService(repo Repository) *Service {
+	return &Service{repo: repo}
+}
+
+// Secret returns secret of active partner key valid now. If partner is not allowed to call endpoint, secret is returned
+// with ErrForbidden, so caller verifies signature before it rejects request as forbidden.
+// Several keys of partner may be valid at the same time while key is rotated
+func (s *Service) Secret(ctx context.Context, partnerID string, keyID string, endpoint string) (string, error) {
+	key, endpoints, err := s.repo.Key(ctx, partnerID, keyID)
+	if err == ErrNotFound {
+		return "", ErrNotFound
+	}
+	if err != nil {
+		log.Println("Secret s.repo.Key error:", err)
+		return "", ErrInternal
+	}
+
+	if !allowed(endpoints, endpoint) {
+		return key.Secret, ErrForbidden
+	}
+	return key.Secret, nil
+}
+
+// allowed reports if partner with endpoints may call endpoint
+func allowed(endpoints []string, endpoint string) bool {
+	operator := strings.HasPrefix(endpoint, OperatorPrefix)
+	for _, e := range endpoints {
+		if e == endpoint || (e == AllEndpoints && !operator) || (e == OperatorEndpoints && operator) {
+			return true
+		}
+	}
+	return false
+}
+
+// RotateKey creates new key of partner valid from now and limits validity of its current keys
+// to overlap, so clients can switch to new key without downtime
+func (s *Service) RotateKey(ctx context.Context, partnerID string, keyID string, overlap time.Duration) (*types.PartnerKey, int, error) {
+	if keyID == "" || overlap < 0 {
+		return nil, http.StatusBadRequest, ErrInvalidKey
+	}
+
+	buf := make([]byte, secretLen)
+	_, err := rand.Read(buf)
+	if err != nil {
+		log.Println("RotateKey rand.Read error:", err)
+		return nil, http.StatusInternalServerError, ErrInternal
+	}
+	key := &types.PartnerKey{PartnerID: partnerID, KeyID: keyID, Secret: hex.EncodeToString(buf), Active: true}
+
+	err = s.repo.RotateKey(ctx, key, overlap)
+	if err == ErrNotFound {
+		return nil, http.StatusNotFound, ErrPartnerNotFound
+	}
+	if err == ErrExist {
+		return nil, http.StatusConflict, ErrExist
+	}
+	if err != nil {
+		log.Println("RotateKey s.repo.RotateKey error:", err)
+		return nil, http.StatusInternalServerError, ErrInternal
+	}
+	return key, http.StatusOK, nil
+}
@@ -0,0 +1,159 @@
+package partner
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/SYSTEMTerror/GoWallet/internal/pkg/types"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+func TestSecret(t *testing.T) {
+	repo := NewMemoryRepository()
+	repo.AddPartner("shop", true, "/api/wallet/holds", "/api/wallet/holds/{id}/capture")
+	repo.AddPartner("all", true, AllEndpoints)
+	repo.AddPartner("support", true, OperatorEndpoints)
+	repo.AddPartner("refunds", true, AllEndpoints, "/api/operator/transactions/{id}/reverse")
+	repo.AddPartner("closed", false, AllEndpoints)
+	past := time.Now().Add(-time.Hour)
+	future := time.Now().Add(time.Hour)
+	repo.AddKey(&types.PartnerKey{PartnerID: "shop", KeyID: "k1", Secret: "shop-secret", Active: true})
+	repo.AddKey(&types.PartnerKey{PartnerID: "shop", KeyID: "revoked", Secret: "revoked-secret", Active: false})
+	repo.AddKey(&types.PartnerKey{PartnerID: "shop", KeyID: "expired", Secret: "expired-secret", Active: true, ValidFrom: past.Add(-time.Hour), ValidTo: &past})
+	repo.AddKey(&types.PartnerKey{PartnerID: "shop", KeyID: "next", Secret: "next-secret", Active: true, ValidFrom: future})
+	repo.AddKey(&types.PartnerKey{PartnerID: "all", KeyID: "k1", Secret: "all-secret", Active: true})
+	repo.AddKey(&types.PartnerKey{PartnerID: "support", KeyID: "k1", Secret: "support-secret", Active: true})
+	repo.AddKey(&types.PartnerKey{PartnerID: "refunds", KeyID: "k1", Secret: "refunds-secret", Active: true})
+	repo.AddKey(&types.PartnerKey{PartnerID: "closed", KeyID: "k1", Secret: "closed-secret", Active: true})
+	s := NewService(repo)
+
+	tests := []struct {
+		name      string
+		partnerID string
+		keyID     string
+		endpoint  string
+		secret    string
+		err       error
+	}{
+		{"allowed endpoint", "shop", "k1", "/api/wallet/holds", "shop-secret", nil},
+		{"forbidden endpoint", "shop", "k1", "/api/wallet/transfer", "shop-secret", ErrForbidden},
+		{"all endpoints", "all", "k1", "/api/wallet/transfer", "all-secret", nil},
+		{"all endpoints exclude operator ones", "all", "k1", "/api/operator/transactions/{id}/reverse", "all-secret", ErrForbidden},
+		{"operator endpoint", "support", "k1", "/api/operator/identifications/{id}/approve", "support-secret", nil},
+		{"operator permission excludes wallet endpoints", "support", "k1", "/api/wallet/transfer", "support-secret", ErrForbidden},
+		{"explicit operator endpoint", "refunds", "k1", "/api/operator/transactions/{id}/reverse", "refunds-secret", nil},
+		{"other operator endpoint", "refunds", "k1", "/api/operator/rates", "refunds-secret", ErrForbidden},
+		{"inactive key", "shop", "revoked", "/api/wallet/holds", "", ErrNotFound},
+		{"expired key", "shop", "expired", "/api/wallet/holds", "", ErrNotFound},
+		{"key not valid yet", "shop", "next", "/api/wallet/holds", "", ErrNotFound},
+		{"inactive partner", "closed", "k1", "/api/wallet/holds", "", ErrNotFound},
+		{"unknown key", "shop", "k2", "/api/wallet/holds", "", ErrNotFound},
+		{"unknown partner", "nobody", "k1", "/api/wallet/holds", "", ErrNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			secret, err := s.Secret(context.Background(), tt.partnerID, tt.keyID, tt.endpoint)
+			if secret != tt.secret || err != tt.err {
+				t.Errorf("Secret = %q, %v, want %q, %v", secret, err, tt.secret, tt.err)
+			}
+		})
+	}
+}
+
+func TestRotateKey(t *testing.T) {
+	repo := NewMemoryRepository()
+	repo.AddPartner("shop", true, AllEndpoints)
+	repo.AddKey(&types.PartnerKey{PartnerID: "shop", KeyID: "k1", Secret: "old-secret", Active: true})
+	testRotateKey(t, NewService(repo), "shop")
+}
+
+func TestRotateKey_Postgres(t *testing.T) {
+	dsn := os.Getenv("GOWALLET_TEST_DSN")
+	if dsn == "" {
+		t.Skip("GOWALLET_TEST_DSN is not set")
+	}
+	ctx := context.Background()
+	pool, err := pgxpool.Connect(ctx, dsn)
+	if err != nil {
+		t.Fatalf("pgxpool.Connect error: %v", err)
+	}
+	t.Cleanup(pool.Close)
+
+	partnerID := fmt.Sprintf("test-%d", time.Now().UnixNano())
+	_, err = pool.Exec(ctx, `INSERT INTO partners (id, name, endpoints) VALUES ($1, $1, '{*}')`, partnerID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = pool.Exec(ctx, `INSERT INTO partner_keys (partner_id, key_id, secret) VALUES ($1, 'k1', 'old-secret')`, partnerID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testRotateKey(t, NewService(NewPostgresRepository(pool)), partnerID)
+}
+
+// testRotateKey rotates key k1 with secret old-secret of partner allowed to call all endpoints to k2
+func testRotateKey(t *testing.T, s *Service, partnerID string) {
+	ctx := context.Background()
+	overlap := 500 * time.Millisecond
+	key, code, err := s.RotateKey(ctx, partnerID, "k2", overlap)
+	if err != nil || code != http.StatusOK {
+		t.Fatalf("RotateKey = %d, %v, want 200", code, err)
+	}
+	if len(key.Secret) != 2*secretLen || key.Secret == "old-secret" || !key.Active {
+		t.Errorf("new key = %+v, want active key with new secret", key)
+	}
+
+	// both keys verify during overlap
+	for keyID, want := range map[string]string{"k1": "old-secret", "k2": key.Secret} {
+		secret, err := s.Secret(ctx, partnerID, keyID, "/api/wallet/holds")
+		if secret != want || err != nil {
+			t.Errorf("Secret of %s during overlap = %q, %v, want %q", keyID, secret, err, want)
+		}
+	}
+
+	time.Sleep(2 * overlap)
+	if _, err := s.Secret(ctx, partnerID, "k1", "/api/wallet/holds"); err != ErrNotFound {
+		t.Errorf("Secret of old key after overlap error = %v, want %v", err, ErrNotFound)
+	}
+	if secret, err := s.Secret(ctx, partnerID, "k2", "/api/wallet/holds"); secret != key.Secret || err != nil {
+		t.Errorf("Secret of new key after overlap = %q, %v, want new secret", secret, err)
+	}
+}
+
+func TestRotateKey_Errors(t *testing.T) {
+	repo := NewMemoryRepository()
+	repo.AddPartner("shop", true, AllEndpoints)
+	repo.AddKey(&types.PartnerKey{PartnerID: "shop", KeyID: "k1", Secret: "old-secret", Active: true})
+	s := NewService(repo)
+
+	tests := []struct {
+		name      string
+		partnerID string
+		keyID     string
+		overlap   time.Duration
+		code      int
+		err       error
+	}{
+		{"unknown partner", "nobody", "k2", time.Hour, http.StatusNotFound, ErrPartnerNotFound},
+		{"existing key id", "shop", "k1", time.Hour, http.StatusConflict, ErrExist},
+		{"empty key id", "shop", "", time.Hour, http.StatusBadRequest, ErrInvalidKey},
+		{"negative overlap", "shop", "k2", -time.Hour, http.StatusBadRequest, ErrInvalidKey},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, code, err := s.RotateKey(context.Background(), tt.partnerID, tt.keyID, tt.overlap)
+			if key != nil || code != tt.code || err != tt.err {
+				t.Errorf("RotateKey = %v, %d, %v, want %d, %v", key, code, err, tt.code, tt.err)
+			}
+		})
+	}
+
+	// failed rotation keeps old key valid without end
+	if secret, err := s.Secret(context.Background(), "shop", "k1", "/api/wallet/holds"); secret != "old-secret" || err != nil {
+		t.Errorf("Secret of old key = %q, %v, want old secret", secret, err)
+	}
+}
@@ -0,0 +1,140 @@
+package scheduler
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/SYSTEMTerror/GoWallet/internal/pkg/types"
+)
+
+// MemoryRepository stores schedules in memory, it is meant for tests and local runs
+type MemoryRepository struct {
+	mu        sync.Mutex
+	seq       int64
+	runSeq    int64
+	schedules map[int64]types.Schedule
+	runs      []types.ScheduleRun
+}
+
+func NewMemoryRepository() *MemoryRepository {
+	return &MemoryRepository{schedules: map[int64]types.Schedule{}}
+}
+
+func (r *MemoryRepository) Create(ctx context.Context, item *types.Schedule) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.seq++
+	item.ID, item.Attempts, item.RetryAt, item.Active, item.Created = r.seq, 0, nil, true, time.Now()
+	r.schedules[item.ID] = *item
+	return nil
+}
+
+func (r *MemoryRepository) Schedules(ctx context.Context, accIDs []int64) ([]*types.Schedule, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	schedules := []*types.Schedule{}
+	for _, item := range r.schedules {
+		for _, accID := range accIDs {
+			if item.AccID == accID {
+				item := item
+				schedules = append(schedules, &item)
+				break
+			}
+		}
+	}
+	sort.Slice(schedules, func(i, j int) bool {
+		return schedules[i].ID < schedules[j].ID
+	})
+	return schedules, nil
+}
+
+func (r *MemoryRepository) Schedule(ctx context.Context, id int64) (*types.Schedule, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	item, ok := r.schedules[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &item, nil
+}
+
+func (r *MemoryRepository) Update(ctx context.Context, item *types.Schedule) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	current, ok := r.schedules[item.ID]
+	if !ok {
+		return ErrNotFound
+	}
+	current.Phone, current.Currency, current.Amount, current.Period = item.Phone, item.Currency, item.Amount, item.Period
+	current.NextRun, current.Anchor, current.Active, current.Attempts, current.RetryAt = item.NextRun, item.Anchor, item.Active, 0, nil
+	r.schedules[item.ID] = current
+	*item = current
+	return nil
+}
+
+func (r *MemoryRepository) Delete(ctx context.Context, id int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.schedules[id]; !ok {
+		return ErrNotFound
+	}
+	delete(r.schedules, id)
+	runs := r.runs[:0]
+	for _, run := range r.runs {
+		if run.ScheduleID != id {
+			runs = append(runs, run)
+		}
+	}
+	r.runs = runs
+	return nil
+}
+
+func (r *MemoryRepository) Runs(ctx context.Context, id int64) ([]*types.ScheduleRun, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	runs := []*types.ScheduleRun{}
+	for i := len(r.runs) - 1; i >= 0; i-- {
+		if r.runs[i].ScheduleID == id {
+			run := r.runs[i]
+			runs = append(runs, &run)
+		}
+	}
+	return runs, nil
+}
+
+// Next holds lock of repository while schedule is executed, so schedules are executed one at a time
+func (r *MemoryRepository) Next(ctx context.Context, now time.Time, execute func(item *types.Schedule) *types.ScheduleRun) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var due *types.Schedule
+	for _, item := range r.schedules {
+		item := item
+		if item.Active && !dueAt(&item).After(now) && (due == nil || dueAt(&item).Before(dueAt(due))) {
+			due = &item
+		}
+	}
+	if due == nil {
+		return false, nil
+	}
+
+	run := execute(due)
+	r.runSeq++
+	run.ID, run.Created = r.runSeq, time.Now()
+	r.runs = append(r.runs, *run)
+
+	current := r.schedules[due.ID]
+	current.Attempts, current.RetryAt, current.NextRun = due.Attempts, due.RetryAt, due.NextRun
+	r.schedules[due.ID] = current
+	return true, nil
+}
+
+// dueAt returns time of the next attempt of schedule
+func dueAt(item *types.Schedule) time.Time {
+	if item.RetryAt != nil {
+		return *item.RetryAt
+	}
+	return item.NextRun
+}
@@ -0,0 +1,153 @@
+package scheduler
+
+import (
+	"context"
+	"time"
+
+	"github.com/SYSTEMTerror/GoWallet/internal/pkg/types"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// PostgresRepository stores schedules in Postgres database with schema of migrate package
+type PostgresRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewPostgresRepository(pool *pgxpool.Pool) *PostgresRepository {
+	return &PostgresRepository{pool: pool}
+}
+
+// scheduleColumns are columns of schedules table scanned by scanSchedule
+const scheduleColumns = `id, acc_id, kind, phone, currency, amount, period, next_run, anchor, attempts, retry_at, active, created`
+
+// scanSchedule scans row of scheduleColumns to schedule
+func scanSchedule(row pgx.Row, item *types.Schedule) error {
+	return row.Scan(&item.ID, &item.AccID, &item.Kind, &item.Phone, &item.Currency, &item.Amount, &item.Period, &item.NextRun, &item.Anchor, &item.Attempts, &item.RetryAt, &item.Active, &item.Created)
+}
+
+// runColumns are columns of schedule_runs table scanned by scanRun
+const runColumns = `id, schedule_id, due, attempt, status, error, transaction_id, created`
+
+// scanRun scans row of runColumns to run
+func scanRun(row pgx.Row, item *types.ScheduleRun) error {
+	return row.Scan(&item.ID, &item.ScheduleID, &item.Due, &item.Attempt, &item.Status, &item.Error, &item.TransactionID, &item.Created)
+}
+
+func (r *PostgresRepository) Create(ctx context.Context, item *types.Schedule) error {
+	return scanSchedule(r.pool.QueryRow(ctx, `
+		INSERT INTO schedules (acc_id, kind, phone, currency, amount, period, next_run, anchor)
+		VALUES ($1, $2, $3, $4, $5, $6, $7::timestamptz, $8::timestamptz) RETURNING `+scheduleColumns,
+		item.AccID, item.Kind, item.Phone, item.Currency, item.Amount, item.Period, item.NextRun, item.Anchor), item)
+}
+
+func (r *PostgresRepository) Schedules(ctx context.Context, accIDs []int64) ([]*types.Schedule, error) {
+	schedules := []*types.Schedule{}
+	rows, err := r.pool.Query(ctx, `SELECT `+scheduleColumns+` FROM schedules WHERE acc_id = ANY($1) ORDER BY id`, accIDs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		item := &types.Schedule{}
+		err = scanSchedule(rows, item)
+		if err != nil {
+			return nil, err
+		}
+		schedules = append(schedules, item)
+	}
+	return schedules, rows.Err()
+}
+
+func (r *PostgresRepository) Schedule(ctx context.Context, id int64) (*types.Schedule, error) {
+	item := &types.Schedule{}
+	err := scanSchedule(r.pool.QueryRow(ctx, `SELECT `+scheduleColumns+` FROM schedules WHERE id = $1`, id), item)
+	if err == pgx.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+func (r *PostgresRepository) Update(ctx context.Context, item *types.Schedule) error {
+	err := scanSchedule(r.pool.QueryRow(ctx, `
+		UPDATE schedules SET phone = $2, currency = $3, amount = $4, period = $5, next_run = $6::timestamptz, anchor = $7::timestamptz,
+		active = $8, attempts = 0, retry_at = NULL
+		WHERE id = $1 RETURNING `+scheduleColumns,
+		item.ID, item.Phone, item.Currency, item.Amount, item.Period, item.NextRun, item.Anchor, item.Active), item)
+	if err == pgx.ErrNoRows {
+		return ErrNotFound
+	}
+	return err
+}
+
+func (r *PostgresRepository) Delete(ctx context.Context, id int64) error {
+	tag, err := r.pool.Exec(ctx, `DELETE FROM schedules WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *PostgresRepository) Runs(ctx context.Context, id int64) ([]*types.ScheduleRun, error) {
+	runs := []*types.ScheduleRun{}
+	rows, err := r.pool.Query(ctx, `SELECT `+runColumns+` FROM schedule_runs WHERE schedule_id = $1 ORDER BY id DESC`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		run := &types.ScheduleRun{}
+		err = scanRun(rows, run)
+		if err != nil {
+			return nil, err
+		}
+		runs = append(runs, run)
+	}
+	return runs, rows.Err()
+}
+
+func (r *PostgresRepository) Next(ctx context.Context, now time.Time, execute func(item *types.Schedule) *types.ScheduleRun) (bool, error) {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback(ctx)
+
+	item := &types.Schedule{}
+	err = scanSchedule(tx.QueryRow(ctx, `
+		SELECT `+scheduleColumns+` FROM schedules
+		WHERE active AND COALESCE(retry_at, next_run) <= $1::timestamptz
+		ORDER BY COALESCE(retry_at, next_run) LIMIT 1 FOR UPDATE SKIP LOCKED
+	`, now), item)
+	if err == pgx.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	run := execute(item)
+	err = scanRun(tx.QueryRow(ctx, `
+		INSERT INTO schedule_runs (schedule_id, due, attempt, status, error, transaction_id)
+		VALUES ($1, $2::timestamptz, $3, $4, $5, $6) RETURNING `+runColumns,
+		run.ScheduleID, run.Due, run.Attempt, run.Status, run.Error, run.TransactionID), run)
+	if err != nil {
+		return false, err
+	}
+
+	_, err = tx.Exec(ctx, `UPDATE schedules SET attempts = $2, retry_at = $3::timestamptz, next_run = $4::timestamptz WHERE id = $1`,
+		item.ID, item.Attempts, item.RetryAt, item.NextRun)
+	if err != nil {
+		return false, err
+	}
+
+	return true, tx.Commit(ctx)
+}
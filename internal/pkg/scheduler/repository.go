@@ -0,0 +1,28 @@
+package scheduler
+
+import (
+	"context"
+	"time"
+
+	"github.com/SYSTEMTerror/GoWallet/internal/pkg/types"
+)
+
+// Repository is storage of Service. Missing schedules are reported with ErrNotFound, other errors are internal
+type Repository interface {
+	// Create inserts schedule, filling its ID, Active and Created
+	Create(ctx context.Context, item *types.Schedule) error
+	// Schedules returns schedules of wallets ordered by id
+	Schedules(ctx context.Context, accIDs []int64) ([]*types.Schedule, error)
+	// Schedule returns schedule by id
+	Schedule(ctx context.Context, id int64) (*types.Schedule, error)
+	// Update replaces target, amount, period, next run, anchor and active flag of schedule and resets its retries
+	Update(ctx context.Context, item *types.Schedule) error
+	// Delete removes schedule with its runs
+	Delete(ctx context.Context, id int64) error
+	// Runs returns runs of schedule, the latest first
+	Runs(ctx context.Context, id int64) ([]*types.ScheduleRun, error)
+	// Next locks one active schedule due at now, so several server processes don't execute it twice, and calls execute.
+	// Run returned by execute is inserted and attempts, retry and next run of schedule are stored in one transaction.
+	// Next returns false when no schedule is due
+	Next(ctx context.Context, now time.Time, execute func(item *types.Schedule) *types.ScheduleRun) (bool, error)
+}
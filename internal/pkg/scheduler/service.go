@@ -0,0 +1,343 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/SYSTEMTerror/GoWallet/internal/pkg/types"
+	"github.com/SYSTEMTerror/GoWallet/internal/pkg/wallet"
+)
+
+// Calendar periods of schedules, other periods are durations like "36h"
+const (
+	PeriodDaily   = "daily"
+	PeriodWeekly  = "weekly"
+	PeriodMonthly = "monthly"
+)
+
+// Statuses of schedule runs
+const (
+	RunSucceeded = "succeeded"
+	RunFailed    = "failed"
+)
+
+// minPeriod is the shortest duration period of schedule
+const minPeriod = time.Minute
+
+// dueBatch is number of due schedules executed by one RunDue call at most
+const dueBatch = 100
+
+var (
+	ErrNotFound        = errors.New("schedule not found")
+	ErrInvalidSchedule = errors.New("invalid schedule")
+	ErrInternal        = errors.New("internal error")
+)
+
+// Config is configuration of scheduler. Failed run is retried after RetryDelay
+// until MaxAttempts attempts fail, then schedule moves to its next run
+type Config struct {
+	Interval    time.Duration
+	RetryDelay  time.Duration
+	MaxAttempts int
+}
+
+type Service struct {
+	repo      Repository
+	walletSvc *wallet.Service
+	config    *Config
+	location  *time.Location
+}
+
+// NewService creates scheduler, location is business timezone of calendar periods
+func NewService(repo Repository, walletSvc *wallet.Service, config *Config, location *time.Location) *Service {
+	return &Service{repo: repo, walletSvc: walletSvc, config: config, location: location}
+}
+
+// Create stores recurring payment of wallet. Zero next_run runs schedule as soon as possible
+func (s *Service) Create(ctx context.Context, item *types.Schedule) (*types.Schedule, int, error) {
+	statusCode, err := s.validate(item)
+	if err != nil {
+		return nil, statusCode, err
+	}
+	if item.NextRun.IsZero() {
+		item.NextRun = time.Now()
+	}
+	item.Anchor = item.NextRun
+
+	err = s.repo.Create(ctx, item)
+	if err != nil {
+		log.Println("Create s.repo.Create error:", err)
+		return nil, http.StatusInternalServerError, ErrInternal
+	}
+	return item, http.StatusOK, nil
+}
+
+// GetSchedules returns schedules of wallets of user
+func (s *Service) GetSchedules(ctx context.Context, userID int64) ([]*types.Schedule, int, error) {
+	wallets, statusCode, err := s.walletSvc.GetWallets(ctx, userID)
+	if err != nil {
+		return nil, statusCode, err
+	}
+	accIDs := make([]int64, 0, len(wallets))
+	for _, acc := range wallets {
+		accIDs = append(accIDs, acc.ID)
+	}
+
+	schedules, err := s.repo.Schedules(ctx, accIDs)
+	if err != nil {
+		log.Println("GetSchedules s.repo.Schedules error:", err)
+		return nil, http.StatusInternalServerError, ErrInternal
+	}
+	return schedules, http.StatusOK, nil
+}
+
+// GetSchedule returns schedule of wallet of user, schedule of wallet of other user is not found
+func (s *Service) GetSchedule(ctx context.Context, userID int64, id int64) (*types.Schedule, int, error) {
+	item, err := s.repo.Schedule(ctx, id)
+	if err == ErrNotFound {
+		log.Println("GetSchedule s.repo.Schedule not found:", id)
+		return nil, http.StatusNotFound, ErrNotFound
+	}
+	if err != nil {
+		log.Println("GetSchedule s.repo.Schedule error:", err)
+		return nil, http.StatusInternalServerError, ErrInternal
+	}
+
+	owner, statusCode, err := s.walletSvc.IsOwner(ctx, userID, item.AccID)
+	if err != nil {
+		return nil, statusCode, err
+	}
+	if !owner {
+		log.Println("GetSchedule not owner:", id)
+		return nil, http.StatusNotFound, ErrNotFound
+	}
+	return item, http.StatusOK, nil
+}
+
+// Update replaces target, amount, period and next run of schedule of user, active flag only if it is set.
+// Wallet and kind of schedule can't be changed, zero next_run keeps current one and its anchor
+func (s *Service) Update(ctx context.Context, userID int64, id int64, update *types.ScheduleUpdate) (*types.Schedule, int, error) {
+	current, statusCode, err := s.GetSchedule(ctx, userID, id)
+	if err != nil {
+		return nil, statusCode, err
+	}
+	item := &types.Schedule{ID: current.ID, AccID: current.AccID, Kind: current.Kind, Phone: update.Phone, Currency: update.Currency,
+		Amount: update.Amount, Period: update.Period, NextRun: update.NextRun, Active: current.Active}
+	if update.Active != nil {
+		item.Active = *update.Active
+	}
+	statusCode, err = s.validate(item)
+	if err != nil {
+		return nil, statusCode, err
+	}
+	if item.NextRun.IsZero() {
+		item.NextRun, item.Anchor = current.NextRun, current.Anchor
+	} else {
+		item.Anchor = item.NextRun
+	}
+
+	err = s.repo.Update(ctx, item)
+	if err == ErrNotFound {
+		log.Println("Update s.repo.Update not found:", id)
+		return nil, http.StatusNotFound, ErrNotFound
+	}
+	if err != nil {
+		log.Println("Update s.repo.Update error:", err)
+		return nil, http.StatusInternalServerError, ErrInternal
+	}
+	return item, http.StatusOK, nil
+}
+
+// Delete removes schedule of user with its runs
+func (s *Service) Delete(ctx context.Context, userID int64, id int64) (int, error) {
+	_, statusCode, err := s.GetSchedule(ctx, userID, id)
+	if err != nil {
+		return statusCode, err
+	}
+
+	err = s.repo.Delete(ctx, id)
+	if err == ErrNotFound {
+		log.Println("Delete s.repo.Delete not found:", id)
+		return http.StatusNotFound, ErrNotFound
+	}
+	if err != nil {
+		log.Println("Delete s.repo.Delete error:", err)
+		return http.StatusInternalServerError, ErrInternal
+	}
+	return http.StatusNoContent, nil
+}
+
+// GetRuns returns outcomes of runs of schedule of user, the latest first
+func (s *Service) GetRuns(ctx context.Context, userID int64, id int64) ([]*types.ScheduleRun, int, error) {
+	_, statusCode, err := s.GetSchedule(ctx, userID, id)
+	if err != nil {
+		return nil, statusCode, err
+	}
+
+	runs, err := s.repo.Runs(ctx, id)
+	if err != nil {
+		log.Println("GetRuns s.repo.Runs error:", err)
+		return nil, http.StatusInternalServerError, ErrInternal
+	}
+	return runs, http.StatusOK, nil
+}
+
+// Run executes due schedules every Interval until ctx is done
+func (s *Service) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.config.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			count, err := s.RunDue(ctx)
+			if err == nil && count > 0 {
+				log.Println("Run executed schedules:", count)
+			}
+		}
+	}
+}
+
+// RunDue executes due schedules one by one and returns number of executed runs.
+// It stops between runs when ctx is done, run in progress is completed, so payment and its outcome are recorded together
+func (s *Service) RunDue(ctx context.Context) (int, error) {
+	count := 0
+	for count < dueBatch && ctx.Err() == nil {
+		executed, err := s.runNext(context.Background())
+		if err != nil {
+			return count, err
+		}
+		if !executed {
+			break
+		}
+		count++
+	}
+	return count, nil
+}
+
+// runNext executes one due schedule, repository locks it, so several server processes don't execute it twice.
+// Payment uses idempotency key of schedule occurrence: if process stops after payment is committed,
+// the next attempt replays stored result instead of paying again
+func (s *Service) runNext(ctx context.Context) (bool, error) {
+	executed, err := s.repo.Next(ctx, time.Now(), func(item *types.Schedule) *types.ScheduleRun {
+		run := &types.ScheduleRun{ScheduleID: item.ID, Due: item.NextRun, Attempt: item.Attempts + 1, Status: RunSucceeded}
+		var err error
+		run.TransactionID, err = s.pay(ctx, item)
+		if err != nil {
+			run.Status, run.Error = RunFailed, err.Error()
+		}
+
+		now := time.Now()
+		if run.Status == RunFailed && run.Attempt < s.config.MaxAttempts {
+			retryAt := now.Add(s.config.RetryDelay)
+			item.Attempts, item.RetryAt = run.Attempt, &retryAt
+		} else {
+			item.Attempts, item.RetryAt, item.NextRun = 0, nil, s.nextRun(item, now)
+		}
+		return run
+	})
+	if err != nil {
+		log.Println("runNext s.repo.Next error:", err)
+		return false, ErrInternal
+	}
+	return executed, nil
+}
+
+// pay executes payment of schedule occurrence through wallet service and returns id of debited or credited transaction
+func (s *Service) pay(ctx context.Context, item *types.Schedule) (*int64, error) {
+	key := fmt.Sprintf("schedule-%d-%d", item.ID, item.NextRun.Unix())
+	switch item.Kind {
+	case wallet.EndpointTransaction:
+		transaction, _, err := s.walletSvc.IdempotentTransaction(ctx, key, &types.Transaction{AccID: item.AccID, Currency: item.Currency, Amount: item.Amount})
+		if err != nil {
+			return nil, err
+		}
+		return &transaction.ID, nil
+	case wallet.EndpointTransfer:
+		transfer, _, err := s.walletSvc.IdempotentTransfer(ctx, key, &types.Transfer{AccID: item.AccID, Phone: item.Phone, Currency: item.Currency, Amount: item.Amount})
+		if err != nil {
+			return nil, err
+		}
+		return &transfer.Debit.ID, nil
+	}
+	return nil, ErrInvalidSchedule
+}
+
+// nextRun returns the first occurrence of schedule after now. Missed occurrences are skipped.
+// Calendar periods keep time of day of anchor in business timezone, monthly period keeps its day of month
+// and falls on the last day of shorter months
+func (s *Service) nextRun(item *types.Schedule, now time.Time) time.Time {
+	anchor := item.Anchor.In(s.location)
+	if item.Anchor.IsZero() {
+		anchor = item.NextRun.In(s.location)
+	}
+	next := item.NextRun.In(s.location)
+	for !next.After(now) {
+		switch item.Period {
+		case PeriodDaily:
+			next = s.occurrence(anchor, next.Year(), next.Month(), next.Day()+1)
+		case PeriodWeekly:
+			next = s.occurrence(anchor, next.Year(), next.Month(), next.Day()+7)
+		case PeriodMonthly:
+			month := time.Date(next.Year(), next.Month()+1, 1, 0, 0, 0, 0, s.location)
+			day := anchor.Day()
+			if last := daysIn(month.Year(), month.Month()); day > last {
+				day = last
+			}
+			next = s.occurrence(anchor, month.Year(), month.Month(), day)
+		default:
+			period, _ := time.ParseDuration(item.Period)
+			if period < minPeriod {
+				period = minPeriod
+			}
+			next = next.Add(period * time.Duration(now.Sub(next)/period+1))
+		}
+	}
+	return next
+}
+
+// occurrence returns date in business timezone at time of day of anchor
+func (s *Service) occurrence(anchor time.Time, year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, anchor.Hour(), anchor.Minute(), anchor.Second(), 0, s.location)
+}
+
+// daysIn returns number of days in month
+func daysIn(year int, month time.Month) int {
+	return time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC).Day()
+}
+
+// validate checks kind, target, amount and period of schedule
+func (s *Service) validate(item *types.Schedule) (int, error) {
+	switch item.Kind {
+	case wallet.EndpointTransaction:
+		item.Phone = ""
+		if item.Amount == 0 {
+			log.Println("validate amount error:", ErrInvalidSchedule)
+			return http.StatusBadRequest, ErrInvalidSchedule
+		}
+	case wallet.EndpointTransfer:
+		if item.Phone == "" || item.Amount <= 0 {
+			log.Println("validate transfer error:", ErrInvalidSchedule)
+			return http.StatusBadRequest, ErrInvalidSchedule
+		}
+	default:
+		log.Println("validate kind error:", item.Kind)
+		return http.StatusBadRequest, ErrInvalidSchedule
+	}
+
+	switch item.Period {
+	case PeriodDaily, PeriodWeekly, PeriodMonthly:
+	default:
+		period, err := time.ParseDuration(item.Period)
+		if err != nil || period < minPeriod {
+			log.Println("validate period error:", item.Period)
+			return http.StatusBadRequest, ErrInvalidSchedule
+		}
+	}
+	return http.StatusOK, nil
+}
@@ -0,0 +1,267 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/SYSTEMTerror/GoWallet/internal/pkg/types"
+	"github.com/SYSTEMTerror/GoWallet/internal/pkg/wallet"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+func TestMain(m *testing.M) {
+	log.SetOutput(io.Discard)
+	os.Exit(m.Run())
+}
+
+func TestNextRun(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip("timezone data is not available:", err)
+	}
+	dushanbe, err := time.LoadLocation("Asia/Dushanbe")
+	if err != nil {
+		t.Skip("timezone data is not available:", err)
+	}
+	utc := func(month time.Month, day int, year int, hour int, min int) time.Time {
+		return time.Date(year, month, day, hour, min, 0, 0, time.UTC)
+	}
+	in := func(location *time.Location, month time.Month, day int, year int, hour int, min int) time.Time {
+		return time.Date(year, month, day, hour, min, 0, 0, location)
+	}
+
+	tests := []struct {
+		name     string
+		location *time.Location
+		period   string
+		anchor   time.Time
+		next     time.Time
+		now      time.Time
+		want     time.Time
+	}{
+		{"monthly from 31st to end of February", time.UTC, PeriodMonthly, utc(1, 31, 2021, 10, 0), utc(1, 31, 2021, 10, 0), utc(1, 31, 2021, 10, 0), utc(2, 28, 2021, 10, 0)},
+		{"monthly to end of February of leap year", time.UTC, PeriodMonthly, utc(1, 31, 2024, 10, 0), utc(1, 31, 2024, 10, 0), utc(1, 31, 2024, 10, 0), utc(2, 29, 2024, 10, 0)},
+		{"monthly back to anchor day after February", time.UTC, PeriodMonthly, utc(1, 31, 2021, 10, 0), utc(2, 28, 2021, 10, 0), utc(2, 28, 2021, 10, 0), utc(3, 31, 2021, 10, 0)},
+		{"monthly 30th after February", time.UTC, PeriodMonthly, utc(1, 30, 2024, 10, 0), utc(2, 29, 2024, 10, 0), utc(2, 29, 2024, 10, 0), utc(3, 30, 2024, 10, 0)},
+		{"monthly to 30 day month", time.UTC, PeriodMonthly, utc(3, 31, 2021, 10, 0), utc(3, 31, 2021, 10, 0), utc(3, 31, 2021, 10, 0), utc(4, 30, 2021, 10, 0)},
+		{"monthly to next year", time.UTC, PeriodMonthly, utc(12, 31, 2021, 10, 0), utc(12, 31, 2021, 10, 0), utc(12, 31, 2021, 10, 0), utc(1, 31, 2022, 10, 0)},
+		{"monthly catch-up skips missed months", time.UTC, PeriodMonthly, utc(1, 31, 2021, 10, 0), utc(1, 31, 2021, 10, 0), utc(5, 15, 2021, 0, 0), utc(5, 31, 2021, 10, 0)},
+		{"monthly catch-up after occurrence of this month", time.UTC, PeriodMonthly, utc(1, 31, 2021, 10, 0), utc(1, 31, 2021, 10, 0), utc(6, 30, 2021, 12, 0), utc(7, 31, 2021, 10, 0)},
+		{"monthly without anchor keeps day of next run", time.UTC, PeriodMonthly, time.Time{}, utc(1, 15, 2021, 10, 0), utc(1, 15, 2021, 10, 0), utc(2, 15, 2021, 10, 0)},
+		{"monthly in business timezone", dushanbe, PeriodMonthly, in(dushanbe, 1, 31, 2021, 0, 30), in(dushanbe, 1, 31, 2021, 0, 30), in(dushanbe, 1, 31, 2021, 0, 30), in(dushanbe, 2, 28, 2021, 0, 30)},
+		{"daily catch-up", time.UTC, PeriodDaily, utc(3, 1, 2021, 10, 0), utc(3, 1, 2021, 10, 0), utc(3, 5, 2021, 12, 0), utc(3, 6, 2021, 10, 0)},
+		{"daily catch-up before time of day", time.UTC, PeriodDaily, utc(3, 1, 2021, 10, 0), utc(3, 1, 2021, 10, 0), utc(3, 5, 2021, 9, 0), utc(3, 5, 2021, 10, 0)},
+		{"weekly catch-up", time.UTC, PeriodWeekly, utc(3, 1, 2021, 10, 0), utc(3, 1, 2021, 10, 0), utc(3, 16, 2021, 0, 0), utc(3, 22, 2021, 10, 0)},
+		{"duration catch-up", time.UTC, "36h", utc(3, 1, 2021, 0, 0), utc(3, 1, 2021, 0, 0), utc(3, 4, 2021, 1, 0), utc(3, 5, 2021, 12, 0)},
+		{"not due yet", time.UTC, PeriodDaily, utc(3, 1, 2021, 10, 0), utc(3, 2, 2021, 10, 0), utc(3, 1, 2021, 12, 0), utc(3, 2, 2021, 10, 0)},
+		{"daily over start of DST", newYork, PeriodDaily, in(newYork, 3, 13, 2021, 9, 0), in(newYork, 3, 13, 2021, 9, 0), in(newYork, 3, 13, 2021, 9, 0), in(newYork, 3, 14, 2021, 9, 0)},
+		{"daily over end of DST", newYork, PeriodDaily, in(newYork, 11, 6, 2021, 9, 0), in(newYork, 11, 6, 2021, 9, 0), in(newYork, 11, 6, 2021, 9, 0), in(newYork, 11, 7, 2021, 9, 0)},
+		// 02:30 doesn't exist on March 14, the occurrence moves to 03:30 and the next one is at 02:30 again
+		{"daily after skipped time of day", newYork, PeriodDaily, in(newYork, 3, 13, 2021, 2, 30), in(newYork, 3, 14, 2021, 3, 30), in(newYork, 3, 14, 2021, 4, 0), in(newYork, 3, 15, 2021, 2, 30)},
+		{"weekly over start of DST", newYork, PeriodWeekly, in(newYork, 3, 8, 2021, 9, 0), in(newYork, 3, 8, 2021, 9, 0), in(newYork, 3, 8, 2021, 9, 0), in(newYork, 3, 15, 2021, 9, 0)},
+		{"monthly over start of DST", newYork, PeriodMonthly, in(newYork, 2, 28, 2021, 9, 0), in(newYork, 2, 28, 2021, 9, 0), in(newYork, 2, 28, 2021, 9, 0), in(newYork, 3, 28, 2021, 9, 0)},
+		{"duration over start of DST is exact", newYork, "24h", in(newYork, 3, 13, 2021, 9, 0), in(newYork, 3, 13, 2021, 9, 0), in(newYork, 3, 13, 2021, 9, 0), in(newYork, 3, 14, 2021, 10, 0)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Service{location: tt.location}
+			got := s.nextRun(&types.Schedule{Period: tt.period, Anchor: tt.anchor, NextRun: tt.next}, tt.now)
+			if !got.Equal(tt.want) {
+				t.Errorf("nextRun = %v, want %v", got, tt.want.In(tt.location))
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		item types.Schedule
+		ok   bool
+	}{
+		{"top-up", types.Schedule{Kind: wallet.EndpointTransaction, Amount: 100, Period: PeriodDaily}, true},
+		{"withdrawal", types.Schedule{Kind: wallet.EndpointTransaction, Amount: -100, Period: PeriodWeekly}, true},
+		{"transfer", types.Schedule{Kind: wallet.EndpointTransfer, Phone: "992000000001", Amount: 100, Period: PeriodMonthly}, true},
+		{"duration period", types.Schedule{Kind: wallet.EndpointTransaction, Amount: 100, Period: "36h"}, true},
+		{"minimal duration period", types.Schedule{Kind: wallet.EndpointTransaction, Amount: 100, Period: "1m"}, true},
+		{"zero amount", types.Schedule{Kind: wallet.EndpointTransaction, Period: PeriodDaily}, false},
+		{"transfer without phone", types.Schedule{Kind: wallet.EndpointTransfer, Amount: 100, Period: PeriodDaily}, false},
+		{"transfer of negative amount", types.Schedule{Kind: wallet.EndpointTransfer, Phone: "992000000001", Amount: -100, Period: PeriodDaily}, false},
+		{"unknown kind", types.Schedule{Kind: "exchange", Amount: 100, Period: PeriodDaily}, false},
+		{"unknown period", types.Schedule{Kind: wallet.EndpointTransaction, Amount: 100, Period: "yearly"}, false},
+		{"too short period", types.Schedule{Kind: wallet.EndpointTransaction, Amount: 100, Period: "30s"}, false},
+		{"negative period", types.Schedule{Kind: wallet.EndpointTransaction, Amount: 100, Period: "-1h"}, false},
+	}
+	s := &Service{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, err := s.validate(&tt.item)
+			if tt.ok && (err != nil || code != http.StatusOK) {
+				t.Errorf("validate = %d, %v, want 200", code, err)
+			}
+			if !tt.ok && (err != ErrInvalidSchedule || code != http.StatusBadRequest) {
+				t.Errorf("validate = %d, %v, want 400 and %v", code, err, ErrInvalidSchedule)
+			}
+		})
+	}
+
+	item := &types.Schedule{Kind: wallet.EndpointTransaction, Phone: "992000000001", Amount: 100, Period: PeriodDaily}
+	if _, err := s.validate(item); err != nil || item.Phone != "" {
+		t.Errorf("validate of transaction = %v, phone %q, want phone cleared", err, item.Phone)
+	}
+}
+
+func TestRunNext(t *testing.T) {
+	repo := wallet.NewMemoryRepository()
+	repo.AddCurrency(&types.Currency{Code: wallet.DefaultCurrency, Name: "Somoni", MinorUnits: 2})
+	walletSvc := wallet.NewService(repo, &wallet.TokenConfig{Secret: "test", TTL: time.Minute}, &wallet.HoldConfig{TTL: time.Hour, ExpiryInterval: time.Minute}, time.UTC)
+	testRunNext(t, NewMemoryRepository(), walletSvc)
+}
+
+func TestRunNext_Postgres(t *testing.T) {
+	dsn := os.Getenv("GOWALLET_TEST_DSN")
+	if dsn == "" {
+		t.Skip("GOWALLET_TEST_DSN is not set")
+	}
+	ctx := context.Background()
+	pool, err := pgxpool.Connect(ctx, dsn)
+	if err != nil {
+		t.Fatalf("pgxpool.Connect error: %v", err)
+	}
+	t.Cleanup(pool.Close)
+
+	walletSvc := wallet.NewService(wallet.NewPostgresRepository(pool), &wallet.TokenConfig{Secret: "test", TTL: time.Minute}, &wallet.HoldConfig{TTL: time.Hour, ExpiryInterval: time.Minute}, time.UTC)
+	testRunNext(t, NewPostgresRepository(pool), walletSvc)
+}
+
+// testRunNext runs withdrawal schedule of empty wallet until MaxAttempts failed attempts move it to its next day,
+// then top-up schedule of the wallet. Other schedules of repo must not be due
+func testRunNext(t *testing.T, repo Repository, walletSvc *wallet.Service) {
+	ctx := context.Background()
+	s := NewService(repo, walletSvc, &Config{Interval: time.Minute, MaxAttempts: 3}, time.UTC)
+	phone := fmt.Sprintf("99%010d", time.Now().UnixNano()%1e10)
+	acc, _, err := walletSvc.Register(ctx, &types.RegInfo{Username: phone, Phone: phone, Password: "12345678"})
+	if err != nil {
+		t.Fatalf("Register error: %v", err)
+	}
+
+	due := time.Now().Add(-time.Hour).Truncate(time.Second)
+	withdrawal, _, err := s.Create(ctx, &types.Schedule{AccID: acc.ID, Kind: wallet.EndpointTransaction, Amount: -100, Period: PeriodDaily, NextRun: due})
+	if err != nil {
+		t.Fatalf("Create error: %v", err)
+	}
+
+	// zero RetryDelay makes failed attempt due again at once
+	for attempt := 1; attempt <= 3; attempt++ {
+		executed, err := s.runNext(ctx)
+		if !executed || err != nil {
+			t.Fatalf("runNext of attempt %d = %v, %v, want true", attempt, executed, err)
+		}
+		item, _, err := s.GetSchedule(ctx, acc.ID, withdrawal.ID)
+		if err != nil {
+			t.Fatalf("GetSchedule error: %v", err)
+		}
+		if attempt < 3 && (item.Attempts != attempt || item.RetryAt == nil || !item.NextRun.Equal(due)) {
+			t.Errorf("schedule after failed attempt %d = %+v, want retry of the same occurrence", attempt, item)
+		}
+		if attempt == 3 && (item.Attempts != 0 || item.RetryAt != nil || !item.NextRun.Equal(due.AddDate(0, 0, 1))) {
+			t.Errorf("schedule after the last attempt = %+v, want the next occurrence %v", item, due.AddDate(0, 0, 1))
+		}
+	}
+	if executed, err := s.runNext(ctx); executed || err != nil {
+		t.Errorf("runNext with no due schedule = %v, %v, want false", executed, err)
+	}
+
+	runs, _, err := s.GetRuns(ctx, acc.ID, withdrawal.ID)
+	if err != nil || len(runs) != 3 {
+		t.Fatalf("GetRuns = %d runs, %v, want 3", len(runs), err)
+	}
+	for i, run := range runs {
+		if run.Attempt != 3-i || run.Status != RunFailed || run.Error == "" || run.TransactionID != nil || !run.Due.Equal(due) {
+			t.Errorf("run %d = %+v, want failed attempt %d of occurrence %v", i, run, 3-i, due)
+		}
+	}
+
+	topUp, _, err := s.Create(ctx, &types.Schedule{AccID: acc.ID, Kind: wallet.EndpointTransaction, Amount: 100, Period: "36h", NextRun: due})
+	if err != nil {
+		t.Fatalf("Create error: %v", err)
+	}
+	if executed, err := s.runNext(ctx); !executed || err != nil {
+		t.Fatalf("runNext of top-up = %v, %v, want true", executed, err)
+	}
+	runs, _, err = s.GetRuns(ctx, acc.ID, topUp.ID)
+	if err != nil || len(runs) != 1 || runs[0].Status != RunSucceeded || runs[0].TransactionID == nil {
+		t.Fatalf("GetRuns of top-up = %+v, %v, want one succeeded run with transaction", runs, err)
+	}
+	account, _, err := walletSvc.GetAccountByID(ctx, acc.ID)
+	if err != nil || account.Balance != 100 {
+		t.Errorf("balance after top-up = %+v, %v, want 100", account, err)
+	}
+	item, _, err := s.GetSchedule(ctx, acc.ID, topUp.ID)
+	if err != nil || item.Attempts != 0 || !item.NextRun.Equal(due.Add(36*time.Hour)) {
+		t.Errorf("top-up schedule = %+v, %v, want next run in 36h", item, err)
+	}
+}
+
+func TestSchedules_Ownership(t *testing.T) {
+	repo := wallet.NewMemoryRepository()
+	repo.AddCurrency(&types.Currency{Code: wallet.DefaultCurrency, Name: "Somoni", MinorUnits: 2})
+	walletSvc := wallet.NewService(repo, &wallet.TokenConfig{Secret: "test", TTL: time.Minute}, &wallet.HoldConfig{TTL: time.Hour, ExpiryInterval: time.Minute}, time.UTC)
+	s := NewService(NewMemoryRepository(), walletSvc, &Config{Interval: time.Minute, MaxAttempts: 3}, time.UTC)
+	ctx := context.Background()
+
+	var accounts []*types.Account
+	for _, phone := range []string{"992000000001", "992000000002"} {
+		acc, _, err := walletSvc.Register(ctx, &types.RegInfo{Username: phone, Phone: phone, Password: "12345678"})
+		if err != nil {
+			t.Fatalf("Register error: %v", err)
+		}
+		accounts = append(accounts, acc)
+	}
+	item, _, err := s.Create(ctx, &types.Schedule{AccID: accounts[0].ID, Kind: wallet.EndpointTransfer, Phone: accounts[1].Phone, Amount: 100, Period: PeriodWeekly})
+	if err != nil {
+		t.Fatalf("Create error: %v", err)
+	}
+	if !item.Active || item.Anchor.IsZero() || !item.Anchor.Equal(item.NextRun) {
+		t.Errorf("created schedule = %+v, want active schedule anchored at its next run", item)
+	}
+
+	if schedules, _, err := s.GetSchedules(ctx, accounts[1].ID); err != nil || len(schedules) != 0 {
+		t.Errorf("GetSchedules of other user = %d, %v, want none", len(schedules), err)
+	}
+	if _, code, err := s.GetSchedule(ctx, accounts[1].ID, item.ID); code != http.StatusNotFound || err != ErrNotFound {
+		t.Errorf("GetSchedule of other user = %d, %v, want 404", code, err)
+	}
+	if _, code, err := s.Update(ctx, accounts[1].ID, item.ID, &types.ScheduleUpdate{Phone: accounts[0].Phone, Amount: 1, Period: PeriodDaily}); code != http.StatusNotFound || err != ErrNotFound {
+		t.Errorf("Update of other user = %d, %v, want 404", code, err)
+	}
+	if code, err := s.Delete(ctx, accounts[1].ID, item.ID); code != http.StatusNotFound || err != ErrNotFound {
+		t.Errorf("Delete of other user = %d, %v, want 404", code, err)
+	}
+
+	updated, _, err := s.Update(ctx, accounts[0].ID, item.ID, &types.ScheduleUpdate{Phone: accounts[1].Phone, Amount: 200, Period: PeriodMonthly})
+	if err != nil || updated.Amount != 200 || updated.Kind != wallet.EndpointTransfer || !updated.Active || !updated.NextRun.Equal(item.NextRun) || !updated.Anchor.Equal(item.Anchor) {
+		t.Errorf("Update = %+v, %v, want active schedule with amount 200 and the same next run and anchor", updated, err)
+	}
+	inactive := false
+	updated, _, err = s.Update(ctx, accounts[0].ID, item.ID, &types.ScheduleUpdate{Phone: accounts[1].Phone, Amount: 200, Period: PeriodMonthly, Active: &inactive})
+	if err != nil || updated.Active {
+		t.Errorf("Update of active flag = %+v, %v, want inactive schedule", updated, err)
+	}
+	updated, _, err = s.Update(ctx, accounts[0].ID, item.ID, &types.ScheduleUpdate{Phone: accounts[1].Phone, Amount: 300, Period: PeriodMonthly})
+	if err != nil || updated.Amount != 300 || updated.Active {
+		t.Errorf("Update of amount = %+v, %v, want inactive schedule with amount 300", updated, err)
+	}
+	if code, err := s.Delete(ctx, accounts[0].ID, item.ID); code != http.StatusNoContent || err != nil {
+		t.Errorf("Delete = %d, %v, want 204", code, err)
+	}
+	if schedules, _, err := s.GetSchedules(ctx, accounts[0].ID); err != nil || len(schedules) != 0 {
+		t.Errorf("GetSchedules after Delete = %d, %v, want none", len(schedules), err)
+	}
+}
@@ -0,0 +1,86 @@
+package statement
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/SYSTEMTerror/GoWallet/internal/pkg/types"
+)
+
+// Bank-standard formats of statement export
+const (
+	FormatCAMT053 = "camt053"
+	FormatMT940   = "mt940"
+)
+
+// Kinds of statement entries, derived from references of transaction
+const (
+	EntryTopUp      = "TOPUP"
+	EntryWithdrawal = "WITHDRAWAL"
+	EntryTransfer   = "TRANSFER"
+	EntryExchange   = "EXCHANGE"
+	EntryReversal   = "REVERSAL"
+	EntryCapture    = "CAPTURE"
+)
+
+// Document is whole statement of wallet account for bank-standard formats, which need closing balance
+// before entries or count of entries in header
+type Document struct {
+	Statement *types.Statement
+	Lines     []*types.StatementLine
+	// Created is creation time of message
+	Created time.Time
+}
+
+// Buffered reports if format is written from Document instead of streamed by writer of NewWriter
+func Buffered(format string) bool {
+	return format == FormatCAMT053 || format == FormatMT940
+}
+
+// Export writes document in buffered format to w
+func Export(format string, w io.Writer, doc *Document) error {
+	switch format {
+	case FormatCAMT053:
+		return WriteCAMT053(w, doc)
+	case FormatMT940:
+		return WriteMT940(w, doc)
+	}
+	return ErrUnknownFormat
+}
+
+// reference returns identification of statement of account for period
+func reference(statement *types.Statement) string {
+	return fmt.Sprintf("%d-%s", statement.AccID, statement.From.Format("20060102"))
+}
+
+// entryKind returns kind of statement entry of transaction
+func entryKind(line *types.StatementLine) string {
+	switch {
+	case line.Rate != "":
+		return EntryExchange
+	case line.RefID != nil:
+		return EntryTransfer
+	case line.ReversalOf != nil:
+		return EntryReversal
+	case line.HoldID != nil:
+		return EntryCapture
+	case line.Amount > 0:
+		return EntryTopUp
+	}
+	return EntryWithdrawal
+}
+
+// booked returns absolute balance effect of transaction and whether it is credit
+func booked(line *types.StatementLine) (int64, bool) {
+	amount := line.Amount - line.Fee
+	if amount < 0 {
+		return -amount, false
+	}
+	return amount, true
+}
+
+// lastDay returns date of the last day of [from, to) period
+func lastDay(statement *types.Statement) time.Time {
+	return statement.To.Add(-time.Nanosecond)
+}
@@ -0,0 +1,170 @@
+package statement
+
+import (
+	"encoding/xml"
+	"io"
+	"strconv"
+)
+
+// camt053Namespace is namespace of ISO 20022 bank to customer statement, version 2
+const camt053Namespace = "urn:iso:std:iso:20022:tech:xsd:camt.053.001.02"
+
+// ISO 20022 codes used in statement
+const (
+	balanceOpening = "OPBD"
+	balanceClosing = "CLBD"
+	credit         = "CRDT"
+	debit          = "DBIT"
+	statusBooked   = "BOOK"
+	dateTimeLayout = "2006-01-02T15:04:05"
+	dateLayout     = "2006-01-02"
+)
+
+type camtDocument struct {
+	XMLName xml.Name             `xml:"Document"`
+	Xmlns   string               `xml:"xmlns,attr"`
+	Stmt    camtStatementMessage `xml:"BkToCstmrStmt"`
+}
+
+type camtStatementMessage struct {
+	MsgID   string        `xml:"GrpHdr>MsgId"`
+	CreDtTm string        `xml:"GrpHdr>CreDtTm"`
+	Stmt    camtStatement `xml:"Stmt"`
+}
+
+type camtStatement struct {
+	ID      string        `xml:"Id"`
+	CreDtTm string        `xml:"CreDtTm"`
+	FrDtTm  string        `xml:"FrToDt>FrDtTm"`
+	ToDtTm  string        `xml:"FrToDt>ToDtTm"`
+	AcctID  string        `xml:"Acct>Id>Othr>Id"`
+	Ccy     string        `xml:"Acct>Ccy"`
+	Bal     []camtBalance `xml:"Bal"`
+	Summary camtSummary   `xml:"TxsSummry"`
+	Ntry    []camtEntry   `xml:"Ntry"`
+}
+
+type camtAmount struct {
+	Ccy   string `xml:"Ccy,attr"`
+	Value string `xml:",chardata"`
+}
+
+type camtBalance struct {
+	Code      string     `xml:"Tp>CdOrPrtry>Cd"`
+	Amt       camtAmount `xml:"Amt"`
+	CdtDbtInd string     `xml:"CdtDbtInd"`
+	Dt        string     `xml:"Dt>Dt"`
+}
+
+type camtTotal struct {
+	NbOfNtries string `xml:"NbOfNtries"`
+	Sum        string `xml:"Sum"`
+}
+
+type camtSummary struct {
+	Cdt camtTotal `xml:"TtlCdtNtries"`
+	Dbt camtTotal `xml:"TtlDbtNtries"`
+}
+
+type camtEntry struct {
+	NtryRef      string      `xml:"NtryRef"`
+	Amt          camtAmount  `xml:"Amt"`
+	CdtDbtInd    string      `xml:"CdtDbtInd"`
+	Sts          string      `xml:"Sts"`
+	BookgDt      string      `xml:"BookgDt>DtTm"`
+	ValDt        string      `xml:"ValDt>DtTm"`
+	AcctSvcrRef  string      `xml:"AcctSvcrRef"`
+	BkTxCd       string      `xml:"BkTxCd>Prtry>Cd"`
+	Chrgs        *camtAmount `xml:"Chrgs>Amt,omitempty"`
+	TxID         string      `xml:"NtryDtls>TxDtls>Refs>TxId"`
+	AddtlNtryInf string      `xml:"AddtlNtryInf,omitempty"`
+}
+
+// WriteCAMT053 writes document as ISO 20022 camt.053.001.02 bank to customer statement.
+// Entry amount is balance effect of transaction including fee, which is also reported as charges
+func WriteCAMT053(w io.Writer, doc *Document) error {
+	st := doc.Statement
+	amount := func(value int64) camtAmount {
+		return camtAmount{Ccy: st.Currency, Value: FormatAmount(value, st.MinorUnits)}
+	}
+	balance := func(code string, value int64, date string) camtBalance {
+		indicator := credit
+		if value < 0 {
+			indicator, value = debit, -value
+		}
+		return camtBalance{Code: code, Amt: amount(value), CdtDbtInd: indicator, Dt: date}
+	}
+
+	stmt := camtStatement{
+		ID:      reference(st),
+		CreDtTm: doc.Created.Format(dateTimeLayout),
+		FrDtTm:  st.From.Format(dateTimeLayout),
+		ToDtTm:  st.To.Format(dateTimeLayout),
+		AcctID:  strconv.FormatInt(st.AccID, 10),
+		Ccy:     st.Currency,
+		Bal: []camtBalance{
+			balance(balanceOpening, st.Opening, st.From.Format(dateLayout)),
+			balance(balanceClosing, st.Closing, lastDay(st).Format(dateLayout)),
+		},
+	}
+
+	var credits, debits int
+	var creditSum, debitSum int64
+	for _, line := range doc.Lines {
+		value, isCredit := booked(line)
+		indicator := debit
+		if isCredit {
+			indicator = credit
+			credits++
+			creditSum += value
+		} else {
+			debits++
+			debitSum += value
+		}
+
+		id := strconv.FormatInt(line.ID, 10)
+		entry := camtEntry{
+			NtryRef:     id,
+			Amt:         amount(value),
+			CdtDbtInd:   indicator,
+			Sts:         statusBooked,
+			BookgDt:     line.Created.Format(dateTimeLayout),
+			ValDt:       line.Created.Format(dateTimeLayout),
+			AcctSvcrRef: id,
+			BkTxCd:      entryKind(line),
+			TxID:        id,
+		}
+		if line.Fee != 0 {
+			fee := amount(line.Fee)
+			entry.Chrgs = &fee
+		}
+		if line.Rate != "" {
+			entry.AddtlNtryInf = "RATE " + line.Rate
+		}
+		stmt.Ntry = append(stmt.Ntry, entry)
+	}
+	stmt.Summary = camtSummary{
+		Cdt: camtTotal{NbOfNtries: strconv.Itoa(credits), Sum: FormatAmount(creditSum, st.MinorUnits)},
+		Dbt: camtTotal{NbOfNtries: strconv.Itoa(debits), Sum: FormatAmount(debitSum, st.MinorUnits)},
+	}
+
+	_, err := io.WriteString(w, xml.Header)
+	if err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	err = encoder.Encode(camtDocument{
+		Xmlns: camt053Namespace,
+		Stmt: camtStatementMessage{
+			MsgID:   "STMT-" + reference(st),
+			CreDtTm: doc.Created.Format(dateTimeLayout),
+			Stmt:    stmt,
+		},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, "\n")
+	return err
+}
@@ -0,0 +1,76 @@
+package statement
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/SYSTEMTerror/GoWallet/internal/pkg/types"
+)
+
+// Types of statement rows
+const (
+	RowOpening     = "opening"
+	RowTransaction = "transaction"
+	RowClosing     = "closing"
+)
+
+// csvHeader is header row of CSV statement
+var csvHeader = []string{"type", "id", "created", "amount", "fee", "balance", "currency", "ref_id", "reversal_of", "hold_id", "rate"}
+
+// CSVWriter writes statement as CSV with opening row, transaction rows and closing row.
+// Amounts are decimal numbers of currency units, closing row has net amount and total fees of period
+type CSVWriter struct {
+	w          *csv.Writer
+	minorUnits int
+}
+
+func NewCSVWriter(w io.Writer) *CSVWriter {
+	return &CSVWriter{w: csv.NewWriter(w)}
+}
+
+func (c *CSVWriter) Begin(statement *types.Statement) error {
+	c.minorUnits = statement.MinorUnits
+	err := c.w.Write(csvHeader)
+	if err != nil {
+		return err
+	}
+	return c.w.Write([]string{RowOpening, "", statement.From.Format(time.RFC3339), "", "", c.amount(statement.Opening), statement.Currency, "", "", "", ""})
+}
+
+func (c *CSVWriter) Line(line *types.StatementLine) error {
+	return c.w.Write([]string{
+		RowTransaction,
+		strconv.FormatInt(line.ID, 10),
+		line.Created.Format(time.RFC3339),
+		c.amount(line.Amount),
+		c.amount(line.Fee),
+		c.amount(line.Balance),
+		line.Currency,
+		optionalID(line.RefID),
+		optionalID(line.ReversalOf),
+		optionalID(line.HoldID),
+		line.Rate,
+	})
+}
+
+func (c *CSVWriter) End(statement *types.Statement) error {
+	err := c.w.Write([]string{RowClosing, "", statement.To.Format(time.RFC3339), c.amount(statement.Credit - statement.Debit), c.amount(statement.Fees), c.amount(statement.Closing), statement.Currency, "", "", "", ""})
+	if err != nil {
+		return err
+	}
+	c.w.Flush()
+	return c.w.Error()
+}
+
+func (c *CSVWriter) amount(amount int64) string {
+	return FormatAmount(amount, c.minorUnits)
+}
+
+func optionalID(id *int64) string {
+	if id == nil {
+		return ""
+	}
+	return strconv.FormatInt(*id, 10)
+}
@@ -0,0 +1,122 @@
+package statement
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/SYSTEMTerror/GoWallet/internal/pkg/types"
+)
+
+var update = flag.Bool("update", false, "update golden files in testdata")
+
+func testDocument() *Document {
+	location := time.FixedZone("TJT", 5*60*60)
+	refID, reversalOf, holdID := int64(7), int64(3), int64(11)
+	at := func(day int, hour int) time.Time {
+		return time.Date(2022, 4, day, hour, 30, 0, 0, location)
+	}
+
+	lines := []*types.StatementLine{
+		{Transaction: types.Transaction{ID: 3, AccID: 2, Currency: "TJS", Amount: 100000, Fee: 1000, Created: at(1, 9)}},
+		{Transaction: types.Transaction{ID: 5, AccID: 2, Currency: "TJS", Amount: -20000, Fee: 200, Created: at(2, 10)}},
+		{Transaction: types.Transaction{ID: 8, AccID: 2, Currency: "TJS", Amount: -15000, RefID: &refID, Fee: 150, Created: at(3, 11)}},
+		{Transaction: types.Transaction{ID: 9, AccID: 2, Currency: "TJS", Amount: 20000, ReversalOf: &reversalOf, Created: at(4, 12)}},
+		{Transaction: types.Transaction{ID: 12, AccID: 2, Currency: "TJS", Amount: -5000, HoldID: &holdID, Created: at(5, 13)}},
+		{Transaction: types.Transaction{ID: 14, AccID: 2, Currency: "TJS", Amount: 54321, Rate: "10.864200000000", Created: at(6, 14)}},
+	}
+	statement := &types.Statement{
+		AccID:      2,
+		Currency:   "TJS",
+		MinorUnits: 2,
+		From:       time.Date(2022, 4, 1, 0, 0, 0, 0, location),
+		To:         time.Date(2022, 5, 1, 0, 0, 0, 0, location),
+		Opening:    -500,
+	}
+	balance := statement.Opening
+	for _, line := range lines {
+		balance += line.Amount - line.Fee
+		line.Balance = balance
+		if line.Amount > 0 {
+			statement.Credit += line.Amount
+		} else {
+			statement.Debit -= line.Amount
+		}
+		statement.Fees += line.Fee
+		statement.Count++
+	}
+	statement.Closing = balance
+
+	return &Document{Statement: statement, Lines: lines, Created: time.Date(2022, 5, 1, 8, 0, 0, 0, location)}
+}
+
+func assertGolden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	path := filepath.Join("testdata", name)
+	if *update {
+		err := os.WriteFile(path, got, 0644)
+		if err != nil {
+			t.Fatalf("os.WriteFile error: %v", err)
+		}
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("os.ReadFile error: %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s mismatch, run go test with -update to regenerate\ngot:\n%s\nwant:\n%s", name, got, want)
+	}
+}
+
+func TestExport(t *testing.T) {
+	tests := []struct {
+		format string
+		golden string
+	}{
+		{FormatCAMT053, "statement.camt053.xml"},
+		{FormatMT940, "statement.mt940.txt"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			if !Buffered(tt.format) {
+				t.Fatalf("Buffered(%q) = false", tt.format)
+			}
+			var buf bytes.Buffer
+			err := Export(tt.format, &buf, testDocument())
+			if err != nil {
+				t.Fatalf("Export error: %v", err)
+			}
+			assertGolden(t, tt.golden, buf.Bytes())
+		})
+	}
+}
+
+func TestExportUnknownFormat(t *testing.T) {
+	var buf bytes.Buffer
+	err := Export(FormatCSV, &buf, testDocument())
+	if err != ErrUnknownFormat {
+		t.Errorf("Export error = %v, want %v", err, ErrUnknownFormat)
+	}
+}
+
+func TestNegotiateBankFormats(t *testing.T) {
+	tests := []struct {
+		format string
+		accept string
+		want   string
+	}{
+		{FormatCAMT053, "", FormatCAMT053},
+		{FormatMT940, "", FormatMT940},
+		{"", "application/xml", FormatCAMT053},
+	}
+	for _, tt := range tests {
+		got, _, err := Negotiate(tt.format, tt.accept)
+		if err != nil || got != tt.want {
+			t.Errorf("Negotiate(%q, %q) = %q, %v, want %q", tt.format, tt.accept, got, err, tt.want)
+		}
+	}
+}
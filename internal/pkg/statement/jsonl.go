@@ -0,0 +1,45 @@
+package statement
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+
+	"github.com/SYSTEMTerror/GoWallet/internal/pkg/types"
+)
+
+// JSONLinesWriter writes statement as JSON object per line: opening line with opening balance,
+// transaction lines and closing line with totals. Amounts are in minor units like in other responses
+type JSONLinesWriter struct {
+	encoder *json.Encoder
+}
+
+func NewJSONLinesWriter(w io.Writer) *JSONLinesWriter {
+	return &JSONLinesWriter{encoder: json.NewEncoder(w)}
+}
+
+func (j *JSONLinesWriter) Begin(statement *types.Statement) error {
+	return j.encoder.Encode(struct {
+		Type       string    `json:"type"`
+		AccID      int64     `json:"acc_id"`
+		Currency   string    `json:"currency"`
+		MinorUnits int       `json:"minor_units"`
+		From       time.Time `json:"from"`
+		To         time.Time `json:"to"`
+		Balance    int64     `json:"balance"`
+	}{RowOpening, statement.AccID, statement.Currency, statement.MinorUnits, statement.From, statement.To, statement.Opening})
+}
+
+func (j *JSONLinesWriter) Line(line *types.StatementLine) error {
+	return j.encoder.Encode(struct {
+		Type string `json:"type"`
+		*types.StatementLine
+	}{RowTransaction, line})
+}
+
+func (j *JSONLinesWriter) End(statement *types.Statement) error {
+	return j.encoder.Encode(struct {
+		Type string `json:"type"`
+		*types.Statement
+	}{RowClosing, statement})
+}
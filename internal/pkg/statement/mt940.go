@@ -0,0 +1,89 @@
+package statement
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/SYSTEMTerror/GoWallet/internal/pkg/types"
+)
+
+// SWIFT MT940 field limits and layouts
+const (
+	mt940ReferenceLength = 16
+	mt940DateLayout      = "060102"
+	mt940EntryDateLayout = "0102"
+	mt940LineEnd         = "\r\n"
+)
+
+// WriteMT940 writes document as SWIFT MT940 customer statement message. Amounts use decimal comma,
+// entry amount is balance effect of transaction including fee, which is reported in :86: field
+func WriteMT940(w io.Writer, doc *Document) error {
+	st := doc.Statement
+	buf := bufio.NewWriter(w)
+	field := func(tag string, value string) {
+		buf.WriteString(":" + tag + ":" + value + mt940LineEnd)
+	}
+	balance := func(tag string, value int64, date time.Time) {
+		mark := "C"
+		if value < 0 {
+			mark, value = "D", -value
+		}
+		field(tag, mark+date.Format(mt940DateLayout)+st.Currency+mt940Amount(value, st.MinorUnits))
+	}
+
+	ref := reference(st)
+	if len(ref) > mt940ReferenceLength {
+		ref = ref[:mt940ReferenceLength]
+	}
+	field("20", ref)
+	field("25", fmt.Sprintf("%d", st.AccID))
+	field("28C", "1/1")
+	balance("60F", st.Opening, st.From)
+
+	for _, line := range doc.Lines {
+		value, isCredit := booked(line)
+		mark := "D"
+		if isCredit {
+			mark = "C"
+		}
+		field("61", fmt.Sprintf("%s%s%s%s%s%s//%d",
+			line.Created.Format(mt940DateLayout), line.Created.Format(mt940EntryDateLayout),
+			mark, mt940Amount(value, st.MinorUnits), mt940TypeCode(line), "NONREF", line.ID))
+
+		info := []string{entryKind(line)}
+		if line.Fee != 0 {
+			info = append(info, "FEE "+mt940Amount(line.Fee, st.MinorUnits))
+		}
+		if line.Rate != "" {
+			info = append(info, "RATE "+line.Rate)
+		}
+		field("86", strings.Join(info, " "))
+	}
+
+	balance("62F", st.Closing, lastDay(st))
+	buf.WriteString("-" + mt940LineEnd)
+	return buf.Flush()
+}
+
+// mt940Amount formats non-negative amount in minor units with decimal comma, e.g. 12345 with 2 minor units as 123,45
+func mt940Amount(amount int64, minorUnits int) string {
+	value := strings.Replace(FormatAmount(amount, minorUnits), ".", ",", 1)
+	if minorUnits <= 0 {
+		value += ","
+	}
+	return value
+}
+
+// mt940TypeCode returns SWIFT transaction type identification code of entry
+func mt940TypeCode(line *types.StatementLine) string {
+	switch entryKind(line) {
+	case EntryTransfer:
+		return "NTRF"
+	case EntryExchange:
+		return "NFEX"
+	}
+	return "NMSC"
+}
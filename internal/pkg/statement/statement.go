@@ -0,0 +1,105 @@
+package statement
+
+import (
+	"errors"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/SYSTEMTerror/GoWallet/internal/pkg/wallet"
+)
+
+// Formats of statement export
+const (
+	FormatCSV       = "csv"
+	FormatJSONLines = "jsonl"
+)
+
+// DefaultFormat is format of statement when request doesn't choose one
+const DefaultFormat = FormatJSONLines
+
+var (
+	ErrUnknownFormat = errors.New("unknown statement format")
+	ErrNotAcceptable = errors.New("no acceptable statement format")
+)
+
+// contentTypes are content types of formats
+var contentTypes = map[string]string{
+	FormatCSV:       "text/csv; charset=utf-8",
+	FormatJSONLines: "application/x-ndjson",
+	FormatCAMT053:   "application/xml; charset=utf-8",
+	FormatMT940:     "text/plain; charset=utf-8",
+}
+
+// mediaTypes are media types of Accept header which select format
+var mediaTypes = map[string]string{
+	"text/csv":                FormatCSV,
+	"application/csv":         FormatCSV,
+	"application/x-ndjson":    FormatJSONLines,
+	"application/jsonl":       FormatJSONLines,
+	"application/x-jsonlines": FormatJSONLines,
+	"application/xml":         FormatCAMT053,
+	"*/*":                     DefaultFormat,
+}
+
+// Negotiate returns format from format query parameter or, if it is empty, the first known media type of Accept header
+func Negotiate(format string, accept string) (string, int, error) {
+	if format != "" {
+		if _, ok := contentTypes[format]; !ok {
+			log.Println("Negotiate format error:", format)
+			return "", http.StatusBadRequest, ErrUnknownFormat
+		}
+		return format, http.StatusOK, nil
+	}
+	if strings.TrimSpace(accept) == "" {
+		return DefaultFormat, http.StatusOK, nil
+	}
+
+	for _, value := range strings.Split(accept, ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(value))
+		if err != nil {
+			continue
+		}
+		if format, ok := mediaTypes[mediaType]; ok {
+			return format, http.StatusOK, nil
+		}
+	}
+	log.Println("Negotiate accept error:", accept)
+	return "", http.StatusNotAcceptable, ErrNotAcceptable
+}
+
+// ContentType returns content type of format
+func ContentType(format string) string {
+	return contentTypes[format]
+}
+
+// NewWriter returns writer of statement in format to w. Buffered formats have no writer, they are written by Export
+func NewWriter(format string, w io.Writer) (wallet.StatementWriter, error) {
+	switch format {
+	case FormatCSV:
+		return NewCSVWriter(w), nil
+	case FormatJSONLines:
+		return NewJSONLinesWriter(w), nil
+	}
+	return nil, ErrUnknownFormat
+}
+
+// FormatAmount formats amount in minor units as decimal number of currency units, e.g. -12345 with 2 minor units as -123.45
+func FormatAmount(amount int64, minorUnits int) string {
+	sign := ""
+	value := strconv.FormatUint(uint64(amount), 10)
+	if amount < 0 {
+		sign = "-"
+		value = strconv.FormatUint(uint64(-amount), 10)
+	}
+	if minorUnits <= 0 {
+		return sign + value
+	}
+	if len(value) <= minorUnits {
+		value = strings.Repeat("0", minorUnits-len(value)+1) + value
+	}
+	return sign + value[:len(value)-minorUnits] + "." + value[len(value)-minorUnits:]
+}
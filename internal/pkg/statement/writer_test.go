@@ -0,0 +1,107 @@
+package statement
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/SYSTEMTerror/GoWallet/internal/pkg/types"
+	"github.com/SYSTEMTerror/GoWallet/internal/pkg/wallet"
+)
+
+// writeDocument streams statement and lines of document to writer like wallet.Service.Statement
+func writeDocument(w wallet.StatementWriter, document *Document) error {
+	err := w.Begin(document.Statement)
+	if err != nil {
+		return err
+	}
+	for _, line := range document.Lines {
+		err = w.Line(line)
+		if err != nil {
+			return err
+		}
+	}
+	return w.End(document.Statement)
+}
+
+func TestWriters(t *testing.T) {
+	tests := []struct {
+		format string
+		golden string
+	}{
+		{FormatCSV, "statement.csv"},
+		{FormatJSONLines, "statement.jsonl"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			if Buffered(tt.format) {
+				t.Fatalf("Buffered(%q) = true", tt.format)
+			}
+			var buf bytes.Buffer
+			w, err := NewWriter(tt.format, &buf)
+			if err != nil {
+				t.Fatalf("NewWriter error: %v", err)
+			}
+			err = writeDocument(w, testDocument())
+			if err != nil {
+				t.Fatalf("write error: %v", err)
+			}
+			assertGolden(t, tt.golden, buf.Bytes())
+		})
+	}
+}
+
+func TestCSVWriter_PeriodAfterEarlierActivity(t *testing.T) {
+	repo := wallet.NewMemoryRepository()
+	repo.AddCurrency(&types.Currency{Code: "USD", Name: "US Dollar", MinorUnits: 2})
+	repo.AddFee(&types.FeeRule{Operation: wallet.EntryWithdrawal, Currency: "USD", Fixed: 50, Percent: "0"})
+	s := wallet.NewService(repo, &wallet.TokenConfig{Secret: "test", TTL: time.Minute}, &wallet.HoldConfig{TTL: time.Hour, ExpiryInterval: time.Minute}, time.UTC)
+	ctx := context.Background()
+
+	acc, _, err := s.Register(ctx, &types.RegInfo{Username: "992000000001", Phone: "992000000001", Password: "12345678", Currency: "USD"})
+	if err != nil {
+		t.Fatalf("Register error: %v", err)
+	}
+	transaction := func(amount int64) *types.Transaction {
+		t.Helper()
+		item, _, err := s.Transaction(ctx, &types.Transaction{AccID: acc.ID, Amount: amount})
+		if err != nil {
+			t.Fatalf("Transaction error: %v", err)
+		}
+		return item
+	}
+
+	// activity before period makes opening balance 1000.00 - 200.00 - 0.50
+	transaction(100000)
+	transaction(-20000)
+	time.Sleep(time.Millisecond)
+	from := time.Now()
+	topUp := transaction(12345)
+	withdrawal := transaction(-30000)
+	to := time.Now().Add(time.Second)
+
+	var buf bytes.Buffer
+	_, err = s.Statement(ctx, acc.ID, from, to, NewCSVWriter(&buf))
+	if err != nil {
+		t.Fatalf("Statement error: %v", err)
+	}
+
+	rows, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("csv.ReadAll error: %v", err)
+	}
+	want := [][]string{
+		csvHeader,
+		{RowOpening, "", from.Format(time.RFC3339), "", "", "799.50", "USD", "", "", "", ""},
+		{RowTransaction, strconv.FormatInt(topUp.ID, 10), topUp.Created.Format(time.RFC3339), "123.45", "0.00", "922.95", "USD", "", "", "", ""},
+		{RowTransaction, strconv.FormatInt(withdrawal.ID, 10), withdrawal.Created.Format(time.RFC3339), "-300.00", "0.50", "622.45", "USD", "", "", "", ""},
+		{RowClosing, "", to.Format(time.RFC3339), "-176.55", "0.50", "622.45", "USD", "", "", "", ""},
+	}
+	if !reflect.DeepEqual(rows, want) {
+		t.Errorf("CSV rows = %q, want %q", rows, want)
+	}
+}
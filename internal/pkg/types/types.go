@@ -17,32 +17,332 @@ type RegInfo struct {
 	Username string
 	Phone    string
 	Password string
+	Currency string
+}
+
+// Type LoginInfo is structure with credentials for login
+type LoginInfo struct {
+	Phone    string `json:"phone"`
+	Password string `json:"password"`
+}
+
+// Type Token is signed access token with its expiration time
+type Token struct {
+	Token   string    `json:"token"`
+	Expires time.Time `json:"expires"`
 }
 
 type Account struct {
 	ID       	int64    	`json:"id"`
+	OwnerID		*int64		`json:"owner_id,omitempty"`
+	Currency	string		`json:"currency"`
 	Balance  	int64	  	`json:"balance"`
 	Identified	bool      	`json:"indentified"`
+	Tier		string		`json:"tier"`
 
 	Username 	string   	`json:"username"`
 	Phone		string    	`json:"phone"`
-	Password 	string    	`json:"password"`
+	Password 	string    	`json:"-"`
 
 	Active   	bool      	`json:"active"`
 	Created  	time.Time	`json:"created"`
 }
 
+// Type AccountView is public representation of account for its owner, without password hash
+type AccountView struct {
+	ID			int64		`json:"id"`
+	OwnerID		*int64		`json:"owner_id,omitempty"`
+	Currency	string		`json:"currency"`
+	Balance		int64		`json:"balance"`
+	Identified	bool		`json:"identified"`
+	Tier		string		`json:"tier"`
+	Username	string		`json:"username"`
+	Phone		string		`json:"phone,omitempty"`
+	Active		bool		`json:"active"`
+	Created		time.Time	`json:"created"`
+}
+
+// NewAccountView returns public representation of account
+func NewAccountView(acc *Account) *AccountView {
+	return &AccountView{
+		ID:         acc.ID,
+		OwnerID:    acc.OwnerID,
+		Currency:   acc.Currency,
+		Balance:    acc.Balance,
+		Identified: acc.Identified,
+		Tier:       acc.Tier,
+		Username:   acc.Username,
+		Phone:      acc.Phone,
+		Active:     acc.Active,
+		Created:    acc.Created,
+	}
+}
+
+// Type ExistView is answer to unauthenticated caller whether account with phone exists
+type ExistView struct {
+	Exist		bool		`json:"exist"`
+}
+
+// Type Transaction is change of wallet balance by amount in minor units of currency.
+// Fee is charged from wallet in addition to amount
 type Transaction struct {
 	ID			int64		`json:"id"`
 	AccID		int64		`json:"acc_id"`
+	Currency	string		`json:"currency"`
+	Amount		int64		`json:"amount"`
+	RefID		*int64		`json:"ref_id,omitempty"`
+	ReversalOf	*int64		`json:"reversal_of,omitempty"`
+	HoldID		*int64		`json:"hold_id,omitempty"`
+	Rate		string		`json:"rate,omitempty"`
+	Fee			int64		`json:"fee"`
+
+	Created		time.Time	`json:"created"`
+}
+
+// Type Reversal is structure with amount to refund from transaction, zero amount refunds the rest of it
+type Reversal struct {
+	Amount		int64		`json:"amount"`
+}
+
+// Type Identification is request to raise identification tier of account with personal data and
+// references to uploaded documents, reviewed by operator
+type Identification struct {
+	ID				int64		`json:"id"`
+	AccID			int64		`json:"acc_id"`
+	Tier			string		`json:"tier"`
+	Status			string		`json:"status"`
+	FullName		string		`json:"full_name"`
+	BirthDate		string		`json:"birth_date"`
+	DocumentType	string		`json:"document_type"`
+	DocumentNumber	string		`json:"document_number"`
+	Documents		[]string	`json:"documents"`
+	Reason			string		`json:"reason,omitempty"`
+	ReviewedBy		string		`json:"reviewed_by,omitempty"`
+	Created			time.Time	`json:"created"`
+	Reviewed		*time.Time	`json:"reviewed,omitempty"`
+}
+
+// Type IdentificationState is current tier of account and state of its latest identification request
+type IdentificationState struct {
+	Tier			string			`json:"tier"`
+	Status			string			`json:"status"`
+	Reason			string			`json:"reason,omitempty"`
+	Latest			*Identification	`json:"latest,omitempty"`
+}
+
+// Type Rejection is structure with reason of rejected identification
+type Rejection struct {
+	Reason			string			`json:"reason"`
+}
+
+// Type Hold is reservation of account funds by partner, which is captured to transaction or voided
+type Hold struct {
+	ID			int64		`json:"id"`
+	AccID		int64		`json:"acc_id"`
+	PartnerID	string		`json:"partner_id"`
+	Currency	string		`json:"currency"`
+	Amount		int64		`json:"amount"`
+	Captured	int64		`json:"captured"`
+	Status		string		`json:"status"`
+	Expires		time.Time	`json:"expires"`
+	Created		time.Time	`json:"created"`
+}
+
+// Type Capture is structure with amount to capture from hold, zero amount captures the whole hold
+type Capture struct {
+	Amount		int64		`json:"amount"`
+}
+
+// Type Balance is ledger balance of account, amount held by active holds and balance available for spending
+type Balance struct {
+	Balance		int64		`json:"balance"`
+	Held		int64		`json:"held"`
+	Available	int64		`json:"available"`
+}
+
+// Type LimitRule is limit of identification tier for wallets in currency checked on every money movement.
+// Amount is maximum of balance, of one operation or of daily/monthly turnover in direction
+type LimitRule struct {
+	ID			int64		`json:"id"`
+	Tier		string		`json:"tier"`
+	Currency	string		`json:"currency"`
+	Kind		string		`json:"kind"`
+	Direction	string		`json:"direction"`
+	Amount		int64		`json:"amount"`
+}
+
+// Type FeeRule is fee schedule of operation in currency for wallets of tier, empty tier matches all tiers.
+// Rule applies to amounts in [MinAmount, MaxAmount), fee is Fixed + Percent of amount limited by MinFee and MaxFee
+type FeeRule struct {
+	ID			int64		`json:"id"`
+	Operation	string		`json:"operation"`
+	Tier		string		`json:"tier,omitempty"`
+	Currency	string		`json:"currency"`
+	MinAmount	int64		`json:"min_amount"`
+	MaxAmount	*int64		`json:"max_amount,omitempty"`
+	Fixed		int64		`json:"fixed"`
+	Percent		string		`json:"percent"`
+	MinFee		int64		`json:"min_fee"`
+	MaxFee		*int64		`json:"max_fee,omitempty"`
+}
+
+// Type Posting is one side of double-entry journal entry. Exactly one of AccID (wallet) and System is set.
+// Positive amount increases balance of ledger account, postings of one entry sum to zero
+type Posting struct {
+	ID			int64		`json:"id"`
+	EntryID		int64		`json:"entry_id"`
+	AccID		*int64		`json:"acc_id,omitempty"`
+	System		string		`json:"system,omitempty"`
+	Currency	string		`json:"currency"`
+	Amount		int64		`json:"amount"`
+}
+
+// Type Transfer is structure with required fields for transfer to wallet in the same currency
+// of another user by phone
+type Transfer struct {
+	AccID		int64		`json:"acc_id"`
+	Phone		string		`json:"phone"`
+	Currency	string		`json:"currency"`
 	Amount		int64		`json:"amount"`
+}
+
+// Type Currency is currency of wallets, amounts are in minor units: 10^MinorUnits of currency unit
+type Currency struct {
+	Code		string		`json:"code"`
+	Name		string		`json:"name"`
+	MinorUnits	int			`json:"minor_units"`
+}
 
+// Type WalletInfo is structure with currency of wallet to open
+type WalletInfo struct {
+	Currency	string		`json:"currency"`
+}
+
+// Type ExchangeRate is price of one unit of base currency in units of quote currency valid in [valid_from, valid_to).
+// Spread is fraction of converted amount kept by wallet. Rate and spread are decimal strings
+type ExchangeRate struct {
+	ID			int64		`json:"id"`
+	Base		string		`json:"base"`
+	Quote		string		`json:"quote"`
+	Rate		string		`json:"rate"`
+	Spread		string		`json:"spread"`
+	ValidFrom	time.Time	`json:"valid_from"`
+	ValidTo		*time.Time	`json:"valid_to,omitempty"`
 	Created		time.Time	`json:"created"`
 }
 
-// Type TransactionInfo is structure with statistics of transactions per current month
+// Type Exchange is structure with required fields for conversion between wallets of one user
+type Exchange struct {
+	FromAccID	int64		`json:"from_acc_id"`
+	ToAccID		int64		`json:"to_acc_id"`
+	Amount		int64		`json:"amount"`
+}
+
+// Type ExchangeResult is linked pair of transactions created by exchange and rate applied to them
+type ExchangeResult struct {
+	Debit		*Transaction	`json:"debit"`
+	Credit		*Transaction	`json:"credit"`
+	Rate		string			`json:"rate"`
+}
+
+// Type TransferResult is linked pair of transactions created by transfer
+type TransferResult struct {
+	Debit		*Transaction	`json:"debit"`
+	Credit		*Transaction	`json:"credit"`
+}
+
+// Type TransactionInfo is structure with statistics of transactions per current month or requested [from, to) period
 type TransactionsPerMonth struct {
+	From			time.Time		`json:"from"`
+	To				time.Time		`json:"to"`
 	Sum				int64			`json:"sum"`
 	Count			int64			`json:"count"`
 	Transactions	[]*Transaction	`json:"transactions"`
+	NextCursor		string			`json:"next_cursor,omitempty"`
+}
+
+// Type Statement is summary of account statement for [from, to): balances before and after period
+// and totals of credited and debited amounts and fees, amounts are in minor units
+type Statement struct {
+	AccID		int64		`json:"acc_id"`
+	Currency	string		`json:"currency"`
+	MinorUnits	int			`json:"minor_units"`
+	From		time.Time	`json:"from"`
+	To			time.Time	`json:"to"`
+	Opening		int64		`json:"opening"`
+	Closing		int64		`json:"closing"`
+	Credit		int64		`json:"credit"`
+	Debit		int64		`json:"debit"`
+	Fees		int64		`json:"fees"`
+	Count		int64		`json:"count"`
+}
+
+// Type StatementLine is transaction of statement with balance of account after it
+type StatementLine struct {
+	Transaction
+	Balance		int64		`json:"balance"`
+}
+
+// Type TransactionFilter is structure with filters and page of transaction history
+type TransactionFilter struct {
+	From		time.Time
+	To			time.Time
+	Direction	string
+	MinAmount	*int64
+	MaxAmount	*int64
+	Cursor		string
+	Limit		int
+}
+
+// Type Schedule is recurring payment of wallet: transaction (top-up or withdrawal by sign of amount)
+// or transfer to phone, repeated daily, weekly, monthly or every duration like "36h" from next_run.
+// Anchor is the first occurrence, calendar periods keep its time of day and monthly one its day of month.
+// Failed run is retried at RetryAt
+type Schedule struct {
+	ID			int64		`json:"id"`
+	AccID		int64		`json:"acc_id"`
+	Kind		string		`json:"kind"`
+	Phone		string		`json:"phone,omitempty"`
+	Currency	string		`json:"currency,omitempty"`
+	Amount		int64		`json:"amount"`
+	Period		string		`json:"period"`
+	NextRun		time.Time	`json:"next_run"`
+	Anchor		time.Time	`json:"anchor"`
+	Attempts	int			`json:"attempts"`
+	RetryAt		*time.Time	`json:"retry_at,omitempty"`
+	Active		bool		`json:"active"`
+	Created		time.Time	`json:"created"`
+}
+
+// Type ScheduleUpdate is new target, amount, period and next run of schedule.
+// Active flag of schedule is changed only if Active is set
+type ScheduleUpdate struct {
+	Phone		string		`json:"phone,omitempty"`
+	Currency	string		`json:"currency,omitempty"`
+	Amount		int64		`json:"amount"`
+	Period		string		`json:"period"`
+	NextRun		time.Time	`json:"next_run"`
+	Active		*bool		`json:"active,omitempty"`
+}
+
+// Type ScheduleRun is outcome of attempt to execute occurrence of schedule due at Due
+type ScheduleRun struct {
+	ID				int64		`json:"id"`
+	ScheduleID		int64		`json:"schedule_id"`
+	Due				time.Time	`json:"due"`
+	Attempt			int			`json:"attempt"`
+	Status			string		`json:"status"`
+	Error			string		`json:"error,omitempty"`
+	TransactionID	*int64		`json:"transaction_id,omitempty"`
+	Created			time.Time	`json:"created"`
+}
+
+// Type PartnerKey is API credential of partner integration, used to sign requests
+type PartnerKey struct {
+	PartnerID	string		`json:"partner_id"`
+	KeyID		string		`json:"key_id"`
+	Secret		string		`json:"secret"`
+	Active		bool		`json:"active"`
+	ValidFrom	time.Time	`json:"valid_from"`
+	ValidTo		*time.Time	`json:"valid_to,omitempty"`
 }
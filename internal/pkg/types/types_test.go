@@ -0,0 +1,72 @@
+package types
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testHash = "$2a$10$W1uTjnpz.h/hbfWuRhO04ekfs6FffeMsIbtFpxLiFhE6eMgW7oMUi"
+
+func testAccount() *Account {
+	return &Account{
+		ID:         2,
+		Currency:   "TJS",
+		Balance:    1000000,
+		Identified: true,
+		Tier:       "full",
+		Username:   "2",
+		Phone:      "992900000002",
+		Password:   testHash,
+		Active:     true,
+		Created:    time.Date(2022, 4, 1, 0, 0, 0, 0, time.UTC),
+	}
+}
+
+func assertNoSensitiveFields(t *testing.T, v interface{}) map[string]interface{} {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("json.Marshal error: %v", err)
+	}
+	if strings.Contains(string(data), testHash) {
+		t.Errorf("password hash leaked: %s", data)
+	}
+
+	fields := map[string]interface{}{}
+	err = json.Unmarshal(data, &fields)
+	if err != nil {
+		t.Fatalf("json.Unmarshal error: %v", err)
+	}
+	if _, ok := fields["password"]; ok {
+		t.Errorf("password field leaked: %s", data)
+	}
+	return fields
+}
+
+func TestAccount_PasswordNotSerialized(t *testing.T) {
+	assertNoSensitiveFields(t, testAccount())
+}
+
+func TestNewAccountView(t *testing.T) {
+	acc := testAccount()
+	fields := assertNoSensitiveFields(t, NewAccountView(acc))
+
+	for _, name := range []string{"id", "currency", "balance", "identified", "tier", "username", "phone", "active", "created"} {
+		if _, ok := fields[name]; !ok {
+			t.Errorf("field %q missing", name)
+		}
+	}
+	if len(fields) != 9 {
+		t.Errorf("unexpected fields: %v", fields)
+	}
+}
+
+func TestExistView_OnlyExistField(t *testing.T) {
+	fields := assertNoSensitiveFields(t, ExistView{Exist: true})
+
+	if len(fields) != 1 || fields["exist"] != true {
+		t.Errorf("fields = %v, want only exist=true", fields)
+	}
+}
@@ -0,0 +1,91 @@
+package wallet
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/SYSTEMTerror/GoWallet/internal/pkg/types"
+)
+
+// DefaultCurrency is currency of main wallet when registration doesn't specify it
+const DefaultCurrency = "TJS"
+
+var (
+	ErrUnknownCurrency  = errors.New("unknown currency")
+	ErrCurrencyMismatch = errors.New("currency does not match wallet")
+)
+
+// GetCurrencies returns currencies wallets can be opened in
+func (s *Service) GetCurrencies(ctx context.Context) ([]*types.Currency, int, error) {
+	currencies, err := s.repo.Currencies(ctx)
+	if err != nil {
+		log.Println("GetCurrencies s.repo.Currencies error:", err)
+		return nil, http.StatusInternalServerError, ErrInternal
+	}
+
+	return currencies, http.StatusOK, nil
+}
+
+// OpenWallet opens wallet in currency for owner of main account. User has at most one wallet per currency.
+// Wallet inherits tier of owner, which is locked, so concurrent approval of identification can't be missed
+func (s *Service) OpenWallet(ctx context.Context, ownerID int64, currency string) (*types.Account, int, error) {
+	acc := &types.Account{OwnerID: &ownerID, Currency: currency}
+	statusCode, err := s.inTx(ctx, "OpenWallet", func(tx Store) (int, error) {
+		owner, err := tx.LockAccount(ctx, ownerID)
+		if err == ErrNotFound || (err == nil && owner.OwnerID != nil) {
+			log.Println("OpenWallet tx.LockAccount owner error:", ErrNotFound)
+			return http.StatusNotFound, ErrNotFound
+		}
+		if err != nil {
+			log.Println("OpenWallet tx.LockAccount error:", err)
+			return http.StatusInternalServerError, ErrInternal
+		}
+
+		acc.Identified, acc.Tier, acc.Username = owner.Identified, owner.Tier, owner.Username
+		err = tx.CreateAccount(ctx, acc)
+		if err == ErrUnknownCurrency {
+			log.Println("OpenWallet tx.CreateAccount unknown currency:", err)
+			return http.StatusBadRequest, ErrUnknownCurrency
+		}
+		if err == ErrExist {
+			log.Println("OpenWallet wallet already exists:", err)
+			return http.StatusConflict, ErrExist
+		}
+		if err != nil {
+			log.Println("OpenWallet tx.CreateAccount error:", err)
+			return http.StatusInternalServerError, ErrInternal
+		}
+		return http.StatusOK, nil
+	})
+	if err != nil {
+		return nil, statusCode, err
+	}
+
+	return acc, http.StatusOK, nil
+}
+
+// GetWallets returns main wallet of user and wallets in other currencies
+func (s *Service) GetWallets(ctx context.Context, ownerID int64) ([]*types.Account, int, error) {
+	wallets, err := s.repo.Wallets(ctx, ownerID)
+	if err != nil {
+		log.Println("GetWallets s.repo.Wallets error:", err)
+		return nil, http.StatusInternalServerError, ErrInternal
+	}
+
+	return wallets, http.StatusOK, nil
+}
+
+// IsOwner checks if wallet is main account of user or its wallet in other currency
+func (s *Service) IsOwner(ctx context.Context, userID int64, walletID int64) (bool, int, error) {
+	acc, err := s.repo.AccountByID(ctx, walletID)
+	if err == ErrNotFound {
+		return false, http.StatusOK, nil
+	}
+	if err != nil {
+		log.Println("IsOwner s.repo.AccountByID error:", err)
+		return false, http.StatusInternalServerError, ErrInternal
+	}
+	return acc.ID == userID || (acc.OwnerID != nil && *acc.OwnerID == userID), http.StatusOK, nil
+}
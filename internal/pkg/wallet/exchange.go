@@ -0,0 +1,324 @@
+package wallet
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/SYSTEMTerror/GoWallet/internal/pkg/types"
+)
+
+// rateScale is number of decimal places of applied exchange rate
+const rateScale = 12
+
+var (
+	ErrInvalidRate  = errors.New("invalid exchange rate")
+	ErrRateNotFound = errors.New("exchange rate not found")
+	ErrSameCurrency = errors.New("wallets are in the same currency")
+)
+
+// LoadRates adds exchange rates in one database transaction. Rate without valid_from is valid from now,
+// newer rate of pair takes precedence over older ones
+func (s *Service) LoadRates(ctx context.Context, rates []*types.ExchangeRate) ([]*types.ExchangeRate, int, error) {
+	return s.loadRates(ctx, rates, false)
+}
+
+// loadRates validates and adds rates, rate of pair with already stored valid_from is skipped if skipExisting is set
+func (s *Service) loadRates(ctx context.Context, rates []*types.ExchangeRate, skipExisting bool) ([]*types.ExchangeRate, int, error) {
+	for _, rate := range rates {
+		rate.Base, rate.Quote = strings.ToUpper(rate.Base), strings.ToUpper(rate.Quote)
+		if rate.Spread == "" {
+			rate.Spread = "0"
+		}
+		value, ok := new(big.Rat).SetString(rate.Rate)
+		if !ok || value.Sign() <= 0 {
+			log.Println("LoadRates rate error:", rate.Rate)
+			return nil, http.StatusBadRequest, ErrInvalidRate
+		}
+		spread, ok := new(big.Rat).SetString(rate.Spread)
+		if !ok || spread.Sign() < 0 || spread.Cmp(big.NewRat(1, 1)) >= 0 {
+			log.Println("LoadRates spread error:", rate.Spread)
+			return nil, http.StatusBadRequest, ErrInvalidRate
+		}
+		if rate.Base == rate.Quote || (rate.ValidTo != nil && !rate.ValidFrom.Before(*rate.ValidTo)) {
+			log.Println("LoadRates rate error:", ErrInvalidRate)
+			return nil, http.StatusBadRequest, ErrInvalidRate
+		}
+		if rate.ValidFrom.IsZero() {
+			rate.ValidFrom = time.Now()
+		}
+	}
+
+	statusCode, err := s.inTx(ctx, "LoadRates", func(tx Store) (int, error) {
+		for _, rate := range rates {
+			if skipExisting {
+				exists, err := tx.HasRate(ctx, rate.Base, rate.Quote, rate.ValidFrom)
+				if err != nil {
+					log.Println("LoadRates tx.HasRate error:", err)
+					return http.StatusInternalServerError, ErrInternal
+				}
+				if exists {
+					continue
+				}
+			}
+			err := tx.CreateRate(ctx, rate)
+			if err == ErrUnknownCurrency {
+				log.Println("LoadRates tx.CreateRate unknown currency:", err)
+				return http.StatusBadRequest, ErrUnknownCurrency
+			}
+			if err != nil {
+				log.Println("LoadRates tx.CreateRate error:", err)
+				return http.StatusInternalServerError, ErrInternal
+			}
+		}
+		return http.StatusOK, nil
+	})
+	if err != nil {
+		return nil, statusCode, err
+	}
+	return rates, statusCode, nil
+}
+
+// LoadRatesFile adds exchange rates from JSON file with array of rates. Every rate of file must have valid_from,
+// rates already loaded with the same pair and valid_from are skipped, so the file may be loaded on every start
+func (s *Service) LoadRatesFile(ctx context.Context, path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		log.Println("LoadRatesFile os.ReadFile error:", err)
+		return http.StatusInternalServerError, ErrInternal
+	}
+
+	var rates []*types.ExchangeRate
+	err = json.Unmarshal(data, &rates)
+	if err != nil {
+		log.Println("LoadRatesFile json.Unmarshal error:", err)
+		return http.StatusBadRequest, ErrInvalidRate
+	}
+	for _, rate := range rates {
+		if rate.ValidFrom.IsZero() {
+			log.Println("LoadRatesFile valid_from error:", ErrInvalidRate)
+			return http.StatusBadRequest, ErrInvalidRate
+		}
+	}
+
+	_, statusCode, err := s.loadRates(ctx, rates, true)
+	return statusCode, err
+}
+
+// GetRates returns exchange rates valid now, the newest one of every pair
+func (s *Service) GetRates(ctx context.Context) ([]*types.ExchangeRate, int, error) {
+	rates, err := s.repo.Rates(ctx)
+	if err != nil {
+		log.Println("GetRates s.repo.Rates error:", err)
+		return nil, http.StatusInternalServerError, ErrInternal
+	}
+
+	return rates, http.StatusOK, nil
+}
+
+// Exchange converts amount from one wallet of user to wallet of the same user in other currency by current rate minus spread.
+// Both wallets are locked in id order, debit and credit transactions record applied rate
+func (s *Service) Exchange(ctx context.Context, item *types.Exchange) (*types.ExchangeResult, int, error) {
+	if item.Amount <= 0 {
+		log.Println("Exchange amount error:", ErrInvalidAmount)
+		return nil, http.StatusBadRequest, ErrInvalidAmount
+	}
+
+	var result *types.ExchangeResult
+	statusCode, err := s.inTx(ctx, "Exchange", func(tx Store) (int, error) {
+		var statusCode int
+		var err error
+		result, statusCode, err = s.exchange(ctx, tx, item)
+		return statusCode, err
+	})
+	if err != nil {
+		return nil, statusCode, err
+	}
+	return result, statusCode, nil
+}
+
+// IdempotentExchange works like Exchange, but replay with the same key returns original result
+func (s *Service) IdempotentExchange(ctx context.Context, key string, item *types.Exchange) (*types.ExchangeResult, int, error) {
+	if item.Amount <= 0 {
+		log.Println("IdempotentExchange amount error:", ErrInvalidAmount)
+		return nil, http.StatusBadRequest, ErrInvalidAmount
+	}
+
+	result := &types.ExchangeResult{}
+	statusCode, err := s.idempotent(ctx, item.FromAccID, EndpointExchange, key, item, result, func(tx Store) (interface{}, int, error) {
+		return s.exchange(ctx, tx, item)
+	})
+	if err != nil {
+		return nil, statusCode, err
+	}
+	return result, statusCode, nil
+}
+
+// exchange converts money between wallets of one user inside tx
+func (s *Service) exchange(ctx context.Context, tx Store, item *types.Exchange) (*types.ExchangeResult, int, error) {
+	accounts, err := tx.LockAccounts(ctx, item.FromAccID, item.ToAccID)
+	if err != nil {
+		log.Println("Exchange tx.LockAccounts error:", err)
+		return nil, http.StatusInternalServerError, ErrInternal
+	}
+	var from, to *types.Account
+	for _, acc := range accounts {
+		if acc.ID == item.FromAccID {
+			from = acc
+		}
+		if acc.ID == item.ToAccID {
+			to = acc
+		}
+	}
+	if from == nil || to == nil || ownerOf(from) != ownerOf(to) {
+		log.Println("Exchange accounts lookup error:", ErrNotFound)
+		return nil, http.StatusNotFound, ErrNotFound
+	}
+	if from.ID == to.ID {
+		log.Println("Exchange accounts lookup error:", ErrSameAccount)
+		return nil, http.StatusBadRequest, ErrSameAccount
+	}
+	if from.Currency == to.Currency {
+		log.Println("Exchange accounts lookup error:", ErrSameCurrency)
+		return nil, http.StatusBadRequest, ErrSameCurrency
+	}
+
+	held, err := tx.HeldAmount(ctx, from.ID, nil)
+	if err != nil {
+		log.Println("Exchange tx.HeldAmount error:", err)
+		return nil, http.StatusInternalServerError, ErrInternal
+	}
+	if from.Balance-held-item.Amount < 0 {
+		log.Println("Exchange available funds error:", ErrNotEnoughFunds)
+		return nil, http.StatusBadRequest, ErrNotEnoughFunds
+	}
+
+	rate, scale, statusCode, err := s.quote(ctx, tx, from.Currency, to.Currency)
+	if err != nil {
+		return nil, statusCode, err
+	}
+	converted := new(big.Rat).Mul(new(big.Rat).SetInt64(item.Amount), rate)
+	converted.Mul(converted, scale)
+	amount := new(big.Int).Quo(converted.Num(), converted.Denom())
+	if !amount.IsInt64() || amount.Int64() <= 0 {
+		log.Println("Exchange converted amount error:", amount)
+		return nil, http.StatusBadRequest, ErrInvalidAmount
+	}
+	credited := amount.Int64()
+	applied := trimDecimal(rate.FloatString(rateScale))
+
+	statusCode, err = s.checkLimits(ctx, tx, from.ID, from.Tier, from.Currency, from.Balance, -item.Amount)
+	if err != nil {
+		return nil, statusCode, err
+	}
+	statusCode, err = s.checkLimits(ctx, tx, to.ID, to.Tier, to.Currency, to.Balance, credited)
+	if err != nil {
+		return nil, statusCode, err
+	}
+
+	entryID, err := post(ctx, tx, EntryExchange, []*types.Posting{
+		{AccID: &from.ID, Currency: from.Currency, Amount: -item.Amount},
+		{System: SystemExchange, Currency: from.Currency, Amount: item.Amount},
+		{AccID: &to.ID, Currency: to.Currency, Amount: credited},
+		{System: SystemExchange, Currency: to.Currency, Amount: -credited},
+	})
+	if err != nil {
+		log.Println("Exchange post error:", err)
+		return nil, http.StatusInternalServerError, ErrInternal
+	}
+
+	debit := &types.Transaction{AccID: from.ID, Currency: from.Currency, Amount: -item.Amount, Rate: applied}
+	err = tx.CreateTransaction(ctx, debit, entryID)
+	if err != nil {
+		log.Println("Exchange tx.CreateTransaction error:", err)
+		return nil, http.StatusInternalServerError, ErrInternal
+	}
+
+	credit := &types.Transaction{AccID: to.ID, Currency: to.Currency, Amount: credited, RefID: &debit.ID, Rate: applied}
+	err = tx.CreateTransaction(ctx, credit, entryID)
+	if err != nil {
+		log.Println("Exchange tx.CreateTransaction error:", err)
+		return nil, http.StatusInternalServerError, ErrInternal
+	}
+	debit.RefID = &credit.ID
+
+	err = tx.SetTransactionRef(ctx, debit.ID, credit.ID)
+	if err != nil {
+		log.Println("Exchange tx.SetTransactionRef error:", err)
+		return nil, http.StatusInternalServerError, ErrInternal
+	}
+	return &types.ExchangeResult{Debit: debit, Credit: credit, Rate: applied}, http.StatusOK, nil
+}
+
+// quote returns rate of conversion from one currency to other after spread, in major units,
+// and factor which converts minor units of from to minor units of to. Direct rate of pair is preferred to inverse one
+func (s *Service) quote(ctx context.Context, tx Store, from string, to string) (*big.Rat, *big.Rat, int, error) {
+	found, err := tx.Rate(ctx, from, to)
+	if err == ErrRateNotFound {
+		log.Println("quote tx.Rate not found:", from, to)
+		return nil, nil, http.StatusNotFound, ErrRateNotFound
+	}
+	if err != nil {
+		log.Println("quote tx.Rate error:", err)
+		return nil, nil, http.StatusInternalServerError, ErrInternal
+	}
+	base, err := tx.Currency(ctx, found.Base)
+	if err != nil {
+		log.Println("quote tx.Currency error:", err)
+		return nil, nil, http.StatusInternalServerError, ErrInternal
+	}
+	quote, err := tx.Currency(ctx, found.Quote)
+	if err != nil {
+		log.Println("quote tx.Currency error:", err)
+		return nil, nil, http.StatusInternalServerError, ErrInternal
+	}
+	rateText, spreadText := found.Rate, found.Spread
+	baseUnits, quoteUnits := base.MinorUnits, quote.MinorUnits
+
+	rate, ok := new(big.Rat).SetString(rateText)
+	if !ok {
+		log.Println("quote rate error:", rateText)
+		return nil, nil, http.StatusInternalServerError, ErrInternal
+	}
+	spread, ok := new(big.Rat).SetString(spreadText)
+	if !ok {
+		log.Println("quote spread error:", spreadText)
+		return nil, nil, http.StatusInternalServerError, ErrInternal
+	}
+
+	fromUnits, toUnits := baseUnits, quoteUnits
+	if found.Base != from {
+		rate.Inv(rate)
+		fromUnits, toUnits = quoteUnits, baseUnits
+	}
+	rate.Mul(rate, new(big.Rat).Sub(big.NewRat(1, 1), spread))
+
+	scale := new(big.Rat).SetFrac(pow10(toUnits), pow10(fromUnits))
+	return rate, scale, http.StatusOK, nil
+}
+
+// ownerOf returns id of user owning account: owner of wallet or account itself
+func ownerOf(acc *types.Account) int64 {
+	if acc.OwnerID != nil {
+		return *acc.OwnerID
+	}
+	return acc.ID
+}
+
+func pow10(n int) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
+
+// trimDecimal removes trailing zeros of fractional part of decimal string
+func trimDecimal(value string) string {
+	if !strings.Contains(value, ".") {
+		return value
+	}
+	return strings.TrimSuffix(strings.TrimRight(value, "0"), ".")
+}
@@ -0,0 +1,212 @@
+package wallet
+
+import (
+	"context"
+	"math/big"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/SYSTEMTerror/GoWallet/internal/pkg/types"
+)
+
+// newExchangeService returns memory service with currencies of different minor units and rates between them
+func newExchangeService(t *testing.T, rates ...*types.ExchangeRate) (*Service, *MemoryRepository) {
+	t.Helper()
+	s, repo := newMemoryService(t)
+	repo.AddCurrency(&types.Currency{Code: "TJS", Name: "Somoni", MinorUnits: 2})
+	repo.AddCurrency(&types.Currency{Code: "JPY", Name: "Yen", MinorUnits: 0})
+	repo.AddCurrency(&types.Currency{Code: "KWD", Name: "Kuwaiti Dinar", MinorUnits: 3})
+	if _, _, err := s.LoadRates(context.Background(), rates); err != nil {
+		t.Fatalf("LoadRates error: %v", err)
+	}
+	return s, repo
+}
+
+func TestQuote(t *testing.T) {
+	s, repo := newExchangeService(t,
+		&types.ExchangeRate{Base: "USD", Quote: "TJS", Rate: "10.95", Spread: "0.01"},
+		&types.ExchangeRate{Base: "USD", Quote: "JPY", Rate: "110"},
+		&types.ExchangeRate{Base: "KWD", Quote: "USD", Rate: "3.3", Spread: "0.002"},
+	)
+
+	tests := []struct {
+		name  string
+		from  string
+		to    string
+		rate  *big.Rat
+		scale *big.Rat
+	}{
+		{"direct rate with spread", "USD", "TJS", big.NewRat(108405, 10000), big.NewRat(1, 1)},
+		{"inverse rate with spread", "TJS", "USD", new(big.Rat).Mul(big.NewRat(100, 1095), big.NewRat(99, 100)), big.NewRat(1, 1)},
+		{"to currency without minor units", "USD", "JPY", big.NewRat(110, 1), big.NewRat(1, 100)},
+		{"from currency without minor units", "JPY", "USD", big.NewRat(1, 110), big.NewRat(100, 1)},
+		{"from currency of three minor units", "KWD", "USD", new(big.Rat).Mul(big.NewRat(33, 10), big.NewRat(998, 1000)), big.NewRat(1, 10)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rate, scale, code, err := s.quote(context.Background(), repo, tt.from, tt.to)
+			if err != nil || code != http.StatusOK {
+				t.Fatalf("quote = %d, %v, want 200", code, err)
+			}
+			if rate.Cmp(tt.rate) != 0 || scale.Cmp(tt.scale) != 0 {
+				t.Errorf("quote = %s, %s, want %s, %s", rate.RatString(), scale.RatString(), tt.rate.RatString(), tt.scale.RatString())
+			}
+		})
+	}
+
+	if _, _, code, err := s.quote(context.Background(), repo, "TJS", "JPY"); code != http.StatusNotFound || err != ErrRateNotFound {
+		t.Errorf("quote of pair without rate = %d, %v, want 404 and %v", code, err, ErrRateNotFound)
+	}
+}
+
+func TestQuote_DirectRatePreferred(t *testing.T) {
+	s, repo := newExchangeService(t,
+		&types.ExchangeRate{Base: "USD", Quote: "TJS", Rate: "10"},
+		&types.ExchangeRate{Base: "TJS", Quote: "USD", Rate: "0.09"},
+	)
+	for from, want := range map[string]*big.Rat{"USD": big.NewRat(10, 1), "TJS": big.NewRat(9, 100)} {
+		to := "TJS"
+		if from == "TJS" {
+			to = "USD"
+		}
+		rate, _, _, err := s.quote(context.Background(), repo, from, to)
+		if err != nil || rate.Cmp(want) != 0 {
+			t.Errorf("quote %s/%s = %v, %v, want direct rate %s", from, to, rate, err, want.RatString())
+		}
+	}
+}
+
+func TestExchange_Amounts(t *testing.T) {
+	tests := []struct {
+		name     string
+		from     string
+		to       string
+		amount   int64
+		credited int64
+		rate     string
+	}{
+		{"direct rate", "USD", "TJS", 10000, 108405, "10.8405"},
+		{"truncated toward zero", "USD", "TJS", 1, 10, "10.8405"},
+		{"inverse rate", "TJS", "USD", 10000, 904, "0.090410958904"},
+		{"to currency without minor units", "USD", "JPY", 12345, 13579, "110"},
+		{"from currency of three minor units", "KWD", "USD", 1234, 406, "3.2934"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _ := newExchangeService(t,
+				&types.ExchangeRate{Base: "USD", Quote: "TJS", Rate: "10.95", Spread: "0.01"},
+				&types.ExchangeRate{Base: "USD", Quote: "JPY", Rate: "110"},
+				&types.ExchangeRate{Base: "KWD", Quote: "USD", Rate: "3.3", Spread: "0.002"},
+			)
+			ctx := context.Background()
+			owner := newMemoryAccount(t, s, "992000000001", 0)
+			wallets := map[string]int64{"USD": owner.ID}
+			for _, currency := range []string{tt.from, tt.to} {
+				if currency == "USD" {
+					continue
+				}
+				wallet, _, err := s.OpenWallet(ctx, owner.ID, currency)
+				if err != nil {
+					t.Fatalf("OpenWallet error: %v", err)
+				}
+				wallets[currency] = wallet.ID
+			}
+			if _, _, err := s.Transaction(ctx, &types.Transaction{AccID: wallets[tt.from], Amount: tt.amount}); err != nil {
+				t.Fatalf("Transaction top-up error: %v", err)
+			}
+
+			result, code, err := s.Exchange(ctx, &types.Exchange{FromAccID: wallets[tt.from], ToAccID: wallets[tt.to], Amount: tt.amount})
+			if err != nil || code != http.StatusOK {
+				t.Fatalf("Exchange = %d, %v, want 200", code, err)
+			}
+			if result.Debit.Amount != -tt.amount || result.Credit.Amount != tt.credited || result.Rate != tt.rate {
+				t.Errorf("Exchange = debit %d, credit %d at %s, want debit %d, credit %d at %s",
+					result.Debit.Amount, result.Credit.Amount, result.Rate, -tt.amount, tt.credited, tt.rate)
+			}
+			if got := balanceOf(t, s, wallets[tt.to]); got != tt.credited {
+				t.Errorf("balance of %s wallet = %d, want %d", tt.to, got, tt.credited)
+			}
+			if _, err := s.CheckLedger(ctx); err != nil {
+				t.Errorf("CheckLedger error: %v", err)
+			}
+		})
+	}
+}
+
+func TestExchange_ConvertedToZero(t *testing.T) {
+	s, _ := newExchangeService(t, &types.ExchangeRate{Base: "USD", Quote: "JPY", Rate: "110"})
+	ctx := context.Background()
+	owner := newMemoryAccount(t, s, "992000000001", 0)
+	yen, _, err := s.OpenWallet(ctx, owner.ID, "JPY")
+	if err != nil {
+		t.Fatalf("OpenWallet error: %v", err)
+	}
+	if _, _, err := s.Transaction(ctx, &types.Transaction{AccID: yen.ID, Amount: 1}); err != nil {
+		t.Fatalf("Transaction top-up error: %v", err)
+	}
+
+	// 1 yen is 0.909 cent
+	_, code, err := s.Exchange(ctx, &types.Exchange{FromAccID: yen.ID, ToAccID: owner.ID, Amount: 1})
+	if code != http.StatusBadRequest || err != ErrInvalidAmount {
+		t.Errorf("Exchange to zero amount = %d, %v, want 400 and %v", code, err, ErrInvalidAmount)
+	}
+	if got := balanceOf(t, s, yen.ID); got != 1 {
+		t.Errorf("balance of yen wallet = %d, want 1", got)
+	}
+}
+
+func TestTransaction_RateOfRequestIgnored(t *testing.T) {
+	s, repo := newMemoryService(t)
+	acc := newMemoryAccount(t, s, "992000000001", 1000)
+	ctx := context.Background()
+
+	if _, _, err := s.Transaction(ctx, &types.Transaction{AccID: acc.ID, Amount: -100, Rate: "10.95"}); err != nil {
+		t.Fatalf("Transaction error: %v", err)
+	}
+	if _, _, err := s.IdempotentTransaction(ctx, "key", &types.Transaction{AccID: acc.ID, Amount: 100, Rate: "10.95"}); err != nil {
+		t.Fatalf("IdempotentTransaction error: %v", err)
+	}
+
+	repo.read(func(d *memoryData) error {
+		for _, transaction := range d.transactions {
+			if transaction.AccID == acc.ID && transaction.Rate != "" {
+				t.Errorf("transaction %d stored with rate %q, want no rate", transaction.ID, transaction.Rate)
+			}
+		}
+		return nil
+	})
+}
+
+func TestLoadRatesFile_SkipsLoadedRates(t *testing.T) {
+	s, repo := newExchangeService(t)
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "rates.json")
+	rates := `[
+		{"base": "USD", "quote": "TJS", "rate": "10.95", "valid_from": "2021-01-01T00:00:00Z"},
+		{"base": "usd", "quote": "jpy", "rate": "110", "valid_from": "2021-01-01T00:00:00+05:00"}
+	]`
+	if err := os.WriteFile(path, []byte(rates), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := s.LoadRatesFile(ctx, path); err != nil {
+			t.Fatalf("LoadRatesFile error: %v", err)
+		}
+	}
+	repo.read(func(d *memoryData) error {
+		if len(d.rates) != 2 {
+			t.Errorf("loaded %d rates twice, want 2 stored rates", len(d.rates))
+		}
+		return nil
+	})
+
+	if err := os.WriteFile(path, []byte(`[{"base": "USD", "quote": "KWD", "rate": "0.3"}]`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if code, err := s.LoadRatesFile(ctx, path); code != http.StatusBadRequest || err != ErrInvalidRate {
+		t.Errorf("LoadRatesFile without valid_from = %d, %v, want 400 and %v", code, err, ErrInvalidRate)
+	}
+}
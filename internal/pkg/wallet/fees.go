@@ -0,0 +1,67 @@
+package wallet
+
+import (
+	"context"
+	"log"
+	"math/big"
+	"net/http"
+
+	"github.com/SYSTEMTerror/GoWallet/internal/pkg/types"
+)
+
+// fee returns fee of operation (EntryTopUp, EntryWithdrawal or EntryTransfer) of amount by wallet of tier.
+// Rule of tier takes precedence over rule of all tiers, rule of higher amount band over lower one.
+// Operation without matching rule is free
+func (s *Service) fee(ctx context.Context, tx Store, operation string, tier string, currency string, amount int64) (int64, int, error) {
+	rule, err := tx.FeeRule(ctx, operation, tier, currency, amount)
+	if err != nil {
+		log.Println("fee tx.FeeRule error:", err)
+		return 0, http.StatusInternalServerError, ErrInternal
+	}
+	if rule == nil {
+		return 0, http.StatusOK, nil
+	}
+
+	fee, ok := feeAmount(rule, abs(amount))
+	if !ok {
+		log.Println("fee feeAmount error of rule:", rule.ID)
+		return 0, http.StatusInternalServerError, ErrInternal
+	}
+	return fee, http.StatusOK, nil
+}
+
+// feeAmount calculates fee of amount by rule: fixed part plus percent of amount rounded half up,
+// limited by minimum and maximum fee
+func feeAmount(rule *types.FeeRule, amount int64) (int64, bool) {
+	percent, ok := new(big.Rat).SetString(rule.Percent)
+	if !ok {
+		return 0, false
+	}
+	part := new(big.Rat).Mul(new(big.Rat).SetInt64(amount), percent)
+	part.Quo(part, big.NewRat(100, 1))
+	part.Add(part, big.NewRat(1, 2))
+	rounded := new(big.Int).Quo(part.Num(), part.Denom())
+	if !rounded.IsInt64() {
+		return 0, false
+	}
+
+	fee := rule.Fixed + rounded.Int64()
+	if fee < rule.MinFee {
+		fee = rule.MinFee
+	}
+	if rule.MaxFee != nil && fee > *rule.MaxFee {
+		fee = *rule.MaxFee
+	}
+	return fee, true
+}
+
+// feePostings returns postings which charge fee from wallet to fee revenue account
+func feePostings(accID int64, currency string, fee int64) []*types.Posting {
+	if fee == 0 {
+		return nil
+	}
+	return []*types.Posting{
+		{AccID: &accID, Currency: currency, Amount: -fee},
+		{System: SystemFee, Currency: currency, Amount: fee},
+	}
+}
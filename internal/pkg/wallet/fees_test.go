@@ -0,0 +1,91 @@
+package wallet
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/SYSTEMTerror/GoWallet/internal/pkg/types"
+)
+
+func int64p(v int64) *int64 {
+	return &v
+}
+
+func TestFeeAmount(t *testing.T) {
+	tests := []struct {
+		name   string
+		rule   types.FeeRule
+		amount int64
+		fee    int64
+	}{
+		{"percent", types.FeeRule{Percent: "1"}, 10000, 100},
+		{"half rounded up", types.FeeRule{Percent: "1"}, 150, 2},
+		{"below half rounded down", types.FeeRule{Percent: "1"}, 149, 1},
+		{"fractional percent half up", types.FeeRule{Percent: "0.5"}, 300, 2},
+		{"four decimal places of percent", types.FeeRule{Percent: "0.0125"}, 20000, 3},
+		{"fixed and percent", types.FeeRule{Fixed: 25, Percent: "2"}, 1000, 45},
+		{"fixed only", types.FeeRule{Fixed: 25, Percent: "0"}, 1000000, 25},
+		{"clamped to min", types.FeeRule{Percent: "1", MinFee: 100}, 500, 100},
+		{"clamped to max", types.FeeRule{Percent: "1", MinFee: 100, MaxFee: int64p(5000)}, 1000000, 5000},
+		{"between min and max", types.FeeRule{Percent: "1", MinFee: 100, MaxFee: int64p(5000)}, 250000, 2500},
+		{"max without min", types.FeeRule{Fixed: 10, Percent: "10", MaxFee: int64p(50)}, 1000, 50},
+		{"zero amount gets min", types.FeeRule{Percent: "1", MinFee: 100}, 0, 100},
+		{"free", types.FeeRule{Percent: "0"}, 1000, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fee, ok := feeAmount(&tt.rule, tt.amount)
+			if !ok || fee != tt.fee {
+				t.Errorf("feeAmount(%+v, %d) = %d, %v, want %d", tt.rule, tt.amount, fee, ok, tt.fee)
+			}
+		})
+	}
+
+	if _, ok := feeAmount(&types.FeeRule{Percent: "one"}, 100); ok {
+		t.Error("feeAmount of invalid percent ok = true, want false")
+	}
+}
+
+func TestFee_RuleSelection(t *testing.T) {
+	s, repo := newMemoryService(t)
+	rules := []*types.FeeRule{
+		{Operation: EntryTransfer, Currency: "USD", MinAmount: 0, MaxAmount: int64p(100000), Fixed: 1, Percent: "0"},
+		{Operation: EntryTransfer, Currency: "USD", MinAmount: 100000, Fixed: 2, Percent: "0"},
+		{Operation: EntryTransfer, Tier: TierFull, Currency: "USD", MinAmount: 0, Fixed: 3, Percent: "0"},
+		{Operation: EntryTransfer, Tier: TierSimplified, Currency: "USD", MinAmount: 50000, Fixed: 4, Percent: "0"},
+		{Operation: EntryWithdrawal, Currency: "USD", Fixed: 5, Percent: "0"},
+		{Operation: EntryTransfer, Currency: "EUR", Fixed: 6, Percent: "0"},
+	}
+	for _, rule := range rules {
+		repo.AddFee(rule)
+	}
+
+	tests := []struct {
+		name      string
+		operation string
+		tier      string
+		currency  string
+		amount    int64
+		fee       int64
+	}{
+		{"lower band of all tiers", EntryTransfer, TierAnonymous, "USD", 99999, 1},
+		{"upper band from its min amount", EntryTransfer, TierAnonymous, "USD", 100000, 2},
+		{"amount of debit by absolute value", EntryTransfer, TierAnonymous, "USD", -100000, 2},
+		{"rule of tier over rule of all tiers", EntryTransfer, TierFull, "USD", 200000, 3},
+		{"rule of tier over higher band of all tiers", EntryTransfer, TierSimplified, "USD", 150000, 4},
+		{"rule of all tiers below band of tier", EntryTransfer, TierSimplified, "USD", 1000, 1},
+		{"other operation", EntryWithdrawal, TierFull, "USD", 1000, 5},
+		{"other currency", EntryTransfer, TierAnonymous, "EUR", 1000, 6},
+		{"no rule is free", EntryTopUp, TierAnonymous, "USD", 1000, 0},
+		{"no rule of currency is free", EntryWithdrawal, TierAnonymous, "EUR", 1000, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fee, code, err := s.fee(context.Background(), repo, tt.operation, tt.tier, tt.currency, tt.amount)
+			if err != nil || code != http.StatusOK || fee != tt.fee {
+				t.Errorf("fee = %d, %d, %v, want %d", fee, code, err, tt.fee)
+			}
+		})
+	}
+}
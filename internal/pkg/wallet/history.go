@@ -0,0 +1,140 @@
+package wallet
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/SYSTEMTerror/GoWallet/internal/pkg/types"
+)
+
+// Directions of transactions in filter
+const (
+	DirectionCredit = "credit"
+	DirectionDebit  = "debit"
+)
+
+const (
+	defaultPageLimit = 50
+	maxPageLimit     = 500
+)
+
+var (
+	ErrInvalidFilter = errors.New("invalid filter")
+	ErrInvalidCursor = errors.New("invalid cursor")
+)
+
+// ParseTransactionFilter parses query parameters from, to, direction, min_amount, max_amount, cursor and limit.
+// Period defaults to current month, amounts are compared by absolute value
+func (s *Service) ParseTransactionFilter(query url.Values) (*types.TransactionFilter, int, error) {
+	filter := &types.TransactionFilter{Limit: defaultPageLimit, Cursor: query.Get("cursor")}
+
+	filter.From, filter.To = s.CurrentMonth()
+	if query.Get("from") != "" || query.Get("to") != "" {
+		var statusCode int
+		var err error
+		filter.From, filter.To, statusCode, err = s.ParsePeriod(query.Get("from"), query.Get("to"))
+		if err != nil {
+			return nil, statusCode, err
+		}
+	}
+
+	filter.Direction = query.Get("direction")
+	if filter.Direction != "" && filter.Direction != DirectionCredit && filter.Direction != DirectionDebit {
+		log.Println("ParseTransactionFilter direction error:", filter.Direction)
+		return nil, http.StatusBadRequest, ErrInvalidFilter
+	}
+
+	for name, dst := range map[string]**int64{"min_amount": &filter.MinAmount, "max_amount": &filter.MaxAmount} {
+		value := query.Get(name)
+		if value == "" {
+			continue
+		}
+		amount, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || amount < 0 {
+			log.Println("ParseTransactionFilter", name, "error:", value)
+			return nil, http.StatusBadRequest, ErrInvalidFilter
+		}
+		*dst = &amount
+	}
+	if filter.MinAmount != nil && filter.MaxAmount != nil && *filter.MinAmount > *filter.MaxAmount {
+		log.Println("ParseTransactionFilter min_amount > max_amount")
+		return nil, http.StatusBadRequest, ErrInvalidFilter
+	}
+
+	if value := query.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit <= 0 || limit > maxPageLimit {
+			log.Println("ParseTransactionFilter limit error:", value)
+			return nil, http.StatusBadRequest, ErrInvalidFilter
+		}
+		filter.Limit = limit
+	}
+
+	return filter, http.StatusOK, nil
+}
+
+// GetTransactionsPage returns page of account transactions matching filter ordered by created and id,
+// with sum and count of all matching transactions and cursor of next page
+func (s *Service) GetTransactionsPage(ctx context.Context, accID int64, filter *types.TransactionFilter) (*types.TransactionsPerMonth, int, error) {
+	result := &types.TransactionsPerMonth{From: filter.From, To: filter.To}
+	var err error
+	result.Sum, result.Count, err = s.repo.TransactionTotals(ctx, accID, filter)
+	if err != nil {
+		log.Println("GetTransactionsPage s.repo.TransactionTotals error:", err)
+		return nil, http.StatusInternalServerError, ErrInternal
+	}
+
+	var after *Cursor
+	if filter.Cursor != "" {
+		after, err = decodeCursor(filter.Cursor)
+		if err != nil {
+			log.Println("GetTransactionsPage decodeCursor error:", err)
+			return nil, http.StatusBadRequest, ErrInvalidCursor
+		}
+	}
+
+	result.Transactions, err = s.repo.FindTransactions(ctx, accID, filter, after, filter.Limit+1)
+	if err != nil {
+		log.Println("GetTransactionsPage s.repo.FindTransactions error:", err)
+		return nil, http.StatusInternalServerError, ErrInternal
+	}
+
+	if len(result.Transactions) > filter.Limit {
+		result.Transactions = result.Transactions[:filter.Limit]
+		last := result.Transactions[filter.Limit-1]
+		result.NextCursor, err = encodeCursor(&Cursor{Created: last.Created, ID: last.ID})
+		if err != nil {
+			log.Println("GetTransactionsPage encodeCursor error:", err)
+			return nil, http.StatusInternalServerError, ErrInternal
+		}
+	}
+
+	return result, http.StatusOK, nil
+}
+
+func encodeCursor(c *Cursor) (string, error) {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+func decodeCursor(value string) (*Cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+	c := &Cursor{}
+	err = json.Unmarshal(data, c)
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
@@ -0,0 +1,171 @@
+package wallet
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/SYSTEMTerror/GoWallet/internal/pkg/types"
+)
+
+func TestParseTransactionFilter(t *testing.T) {
+	s, _ := newMemoryService(t)
+
+	filter, code, err := s.ParseTransactionFilter(url.Values{})
+	if err != nil || code != http.StatusOK {
+		t.Fatalf("ParseTransactionFilter of empty query = %d, %v, want 200", code, err)
+	}
+	from, to := s.CurrentMonth()
+	if filter.Limit != defaultPageLimit || !filter.From.Equal(from) || !filter.To.Equal(to) || filter.Direction != "" || filter.MinAmount != nil || filter.MaxAmount != nil {
+		t.Errorf("default filter = %+v, want current month and default limit", filter)
+	}
+
+	filter, _, err = s.ParseTransactionFilter(url.Values{
+		"from": {"2021-01-01"}, "to": {"2021-02-01"}, "direction": {DirectionDebit},
+		"min_amount": {"0"}, "max_amount": {"100"}, "limit": {"10"}, "cursor": {"abc"},
+	})
+	if err != nil {
+		t.Fatalf("ParseTransactionFilter error: %v", err)
+	}
+	if filter.From.Day() != 1 || filter.Direction != DirectionDebit || *filter.MinAmount != 0 || *filter.MaxAmount != 100 || filter.Limit != 10 || filter.Cursor != "abc" {
+		t.Errorf("filter = %+v, want values of query", filter)
+	}
+
+	tests := []struct {
+		name  string
+		query url.Values
+		code  int
+		err   error
+	}{
+		{"limit 1", url.Values{"limit": {"1"}}, http.StatusOK, nil},
+		{"max limit", url.Values{"limit": {"500"}}, http.StatusOK, nil},
+		{"limit 0", url.Values{"limit": {"0"}}, http.StatusBadRequest, ErrInvalidFilter},
+		{"limit over max", url.Values{"limit": {"501"}}, http.StatusBadRequest, ErrInvalidFilter},
+		{"negative limit", url.Values{"limit": {"-1"}}, http.StatusBadRequest, ErrInvalidFilter},
+		{"limit not a number", url.Values{"limit": {"ten"}}, http.StatusBadRequest, ErrInvalidFilter},
+		{"unknown direction", url.Values{"direction": {"both"}}, http.StatusBadRequest, ErrInvalidFilter},
+		{"negative amount", url.Values{"min_amount": {"-1"}}, http.StatusBadRequest, ErrInvalidFilter},
+		{"amount not a number", url.Values{"max_amount": {"1.5"}}, http.StatusBadRequest, ErrInvalidFilter},
+		{"min over max", url.Values{"min_amount": {"10"}, "max_amount": {"9"}}, http.StatusBadRequest, ErrInvalidFilter},
+		{"only from", url.Values{"from": {"2021-01-01"}}, http.StatusBadRequest, ErrInvalidPeriod},
+		{"from after to", url.Values{"from": {"2021-02-01"}, "to": {"2021-01-01"}}, http.StatusBadRequest, ErrInvalidPeriod},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, code, err := s.ParseTransactionFilter(tt.query)
+			if code != tt.code || err != tt.err {
+				t.Errorf("ParseTransactionFilter = %d, %v, want %d, %v", code, err, tt.code, tt.err)
+			}
+		})
+	}
+}
+
+// setCreated sets creation time of transactions of account to created in order of their ids
+func setCreated(repo *MemoryRepository, accID int64, created ...time.Time) {
+	repo.write(func(d *memoryData) error {
+		for id := int64(1); len(created) > 0; id++ {
+			transaction, ok := d.transactions[id]
+			if !ok || transaction.AccID != accID {
+				continue
+			}
+			transaction.Created = created[0]
+			d.transactions[id] = transaction
+			created = created[1:]
+		}
+		return nil
+	})
+}
+
+func TestGetTransactionsPage_EqualCreated(t *testing.T) {
+	s, repo := newMemoryService(t)
+	acc := newMemoryAccount(t, s, "992000000001", 0)
+	ctx := context.Background()
+	for i := 1; i <= 7; i++ {
+		_, _, err := s.Transaction(ctx, &types.Transaction{AccID: acc.ID, Amount: int64(i)})
+		if err != nil {
+			t.Fatalf("Transaction error: %v", err)
+		}
+	}
+	// later transactions of one instant go first, so order depends on id within equal created
+	same := time.Date(2021, 1, 10, 12, 0, 0, 0, time.UTC)
+	setCreated(repo, acc.ID, same, same, same, same, same, same.Add(-time.Hour), same.Add(-time.Hour))
+
+	filter := &types.TransactionFilter{From: time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC), To: time.Date(2021, 2, 1, 0, 0, 0, 0, time.UTC), Limit: 2}
+	var amounts []int64
+	for page := 0; page < 10; page++ {
+		result, code, err := s.GetTransactionsPage(ctx, acc.ID, filter)
+		if err != nil || code != http.StatusOK {
+			t.Fatalf("GetTransactionsPage = %d, %v", code, err)
+		}
+		if page == 0 && (result.Count != 7 || result.Sum != 28) {
+			t.Errorf("page totals = %d, %d, want all 7 transactions of sum 28", result.Count, result.Sum)
+		}
+		for _, transaction := range result.Transactions {
+			amounts = append(amounts, transaction.Amount)
+		}
+		if result.NextCursor == "" {
+			break
+		}
+		filter.Cursor = result.NextCursor
+
+		if page == 0 {
+			// transaction created at the same instant after paging started comes after the ones of lower id
+			_, _, err = s.Transaction(ctx, &types.Transaction{AccID: acc.ID, Amount: 100})
+			if err != nil {
+				t.Fatalf("Transaction error: %v", err)
+			}
+			repo.write(func(d *memoryData) error {
+				for id, transaction := range d.transactions {
+					if transaction.Amount == 100 {
+						transaction.Created = same
+						d.transactions[id] = transaction
+					}
+				}
+				return nil
+			})
+		}
+	}
+
+	want := []int64{6, 7, 1, 2, 3, 4, 5, 100}
+	if len(amounts) != len(want) {
+		t.Fatalf("paged amounts = %v, want %v", amounts, want)
+	}
+	for i := range want {
+		if amounts[i] != want[i] {
+			t.Fatalf("paged amounts = %v, want %v", amounts, want)
+		}
+	}
+}
+
+func TestGetTransactionsPage_InvalidCursor(t *testing.T) {
+	s, _ := newMemoryService(t)
+	acc := newMemoryAccount(t, s, "992000000001", 100)
+	from, to := s.CurrentMonth()
+
+	valid, err := encodeCursor(&Cursor{Created: time.Now(), ID: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := decodeCursor(valid)
+	if err != nil || decoded.ID != 1 {
+		t.Fatalf("decodeCursor(encodeCursor) = %+v, %v", decoded, err)
+	}
+
+	for name, cursor := range map[string]string{
+		"garbage":           "garbage!",
+		"truncated":         valid[:len(valid)/2],
+		"padded base64":     base64.URLEncoding.EncodeToString([]byte(`{"id":1}`)),
+		"not json":          base64.RawURLEncoding.EncodeToString([]byte("created=now")),
+		"wrong field types": base64.RawURLEncoding.EncodeToString([]byte(`{"created":"yesterday","id":"1"}`)),
+	} {
+		t.Run(name, func(t *testing.T) {
+			result, code, err := s.GetTransactionsPage(context.Background(), acc.ID, &types.TransactionFilter{From: from, To: to, Limit: 10, Cursor: cursor})
+			if result != nil || code != http.StatusBadRequest || err != ErrInvalidCursor {
+				t.Errorf("GetTransactionsPage = %v, %d, %v, want 400 and %v", result, code, err, ErrInvalidCursor)
+			}
+		})
+	}
+}
@@ -0,0 +1,220 @@
+package wallet
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/SYSTEMTerror/GoWallet/internal/pkg/types"
+)
+
+// Statuses of holds
+const (
+	HoldActive   = "active"
+	HoldCaptured = "captured"
+	HoldVoided   = "voided"
+	HoldExpired  = "expired"
+)
+
+var (
+	ErrHoldNotFound   = errors.New("hold not found")
+	ErrHoldNotActive  = errors.New("hold is not active")
+	ErrNotEnoughFunds = errors.New("not enough available funds")
+)
+
+// HoldConfig is configuration of holds
+type HoldConfig struct {
+	TTL            time.Duration
+	ExpiryInterval time.Duration
+}
+
+// Authorize reserves amount on account for partner without moving money.
+// Reserved amount is not available for withdrawals until hold is captured, voided or expired.
+// Amount is checked against debit limits of wallet tier like withdrawal
+func (s *Service) Authorize(ctx context.Context, partnerID string, item *types.Hold) (*types.Hold, int, error) {
+	if item.Amount <= 0 {
+		log.Println("Authorize amount error:", ErrInvalidAmount)
+		return nil, http.StatusBadRequest, ErrInvalidAmount
+	}
+
+	hold := &types.Hold{AccID: item.AccID, PartnerID: partnerID, Amount: item.Amount}
+	statusCode, err := s.inTx(ctx, "Authorize", func(tx Store) (int, error) {
+		acc, err := tx.LockAccount(ctx, hold.AccID)
+		if err == ErrNotFound {
+			log.Println("Authorize tx.LockAccount not found:", err)
+			return http.StatusNotFound, ErrNotFound
+		}
+		if err != nil {
+			log.Println("Authorize tx.LockAccount error:", err)
+			return http.StatusInternalServerError, ErrInternal
+		}
+		hold.Currency = acc.Currency
+		if item.Currency != "" && item.Currency != hold.Currency {
+			log.Println("Authorize currency error:", ErrCurrencyMismatch)
+			return http.StatusBadRequest, ErrCurrencyMismatch
+		}
+
+		held, err := tx.HeldAmount(ctx, hold.AccID, nil)
+		if err != nil {
+			log.Println("Authorize tx.HeldAmount error:", err)
+			return http.StatusInternalServerError, ErrInternal
+		}
+		if acc.Balance-held < hold.Amount {
+			log.Println("Authorize available funds error:", ErrNotEnoughFunds)
+			return http.StatusBadRequest, ErrNotEnoughFunds
+		}
+		// hold is outgoing amount, so it must fit debit limits of wallet before it is reserved
+		statusCode, err := s.checkLimits(ctx, tx, hold.AccID, acc.Tier, acc.Currency, acc.Balance, -hold.Amount)
+		if err != nil {
+			return statusCode, err
+		}
+
+		err = tx.CreateHold(ctx, hold, s.holds.TTL)
+		if err != nil {
+			log.Println("Authorize tx.CreateHold error:", err)
+			return http.StatusInternalServerError, ErrInternal
+		}
+		return http.StatusOK, nil
+	})
+	if err != nil {
+		return nil, statusCode, err
+	}
+	return hold, statusCode, nil
+}
+
+// Capture turns active hold of partner into withdrawal of amount, zero amount captures the whole hold.
+// The rest of partially captured hold is released
+func (s *Service) Capture(ctx context.Context, partnerID string, id int64, amount int64) (*types.Transaction, int, error) {
+	if amount < 0 {
+		log.Println("Capture amount error:", ErrInvalidAmount)
+		return nil, http.StatusBadRequest, ErrInvalidAmount
+	}
+
+	item := &types.Transaction{HoldID: &id}
+	statusCode, err := s.inTx(ctx, "Capture", func(tx Store) (int, error) {
+		hold, statusCode, err := lockActiveHold(ctx, tx, partnerID, id)
+		if err != nil {
+			return statusCode, err
+		}
+		if amount == 0 {
+			amount = hold.Amount
+		}
+		if amount > hold.Amount {
+			log.Println("Capture amount exceeds hold:", ErrInvalidAmount)
+			return http.StatusBadRequest, ErrInvalidAmount
+		}
+
+		item.AccID = hold.AccID
+		item.Currency = hold.Currency
+		item.Amount = -amount
+		statusCode, err = s.transaction(ctx, tx, item)
+		if err != nil {
+			return statusCode, err
+		}
+
+		err = tx.UpdateHold(ctx, id, HoldCaptured, amount)
+		if err != nil {
+			log.Println("Capture tx.UpdateHold error:", err)
+			return http.StatusInternalServerError, ErrInternal
+		}
+		return http.StatusOK, nil
+	})
+	if err != nil {
+		return nil, statusCode, err
+	}
+	return item, statusCode, nil
+}
+
+// Void releases active hold of partner
+func (s *Service) Void(ctx context.Context, partnerID string, id int64) (*types.Hold, int, error) {
+	var hold *types.Hold
+	statusCode, err := s.inTx(ctx, "Void", func(tx Store) (int, error) {
+		var statusCode int
+		var err error
+		hold, statusCode, err = lockActiveHold(ctx, tx, partnerID, id)
+		if err != nil {
+			return statusCode, err
+		}
+
+		err = tx.UpdateHold(ctx, id, HoldVoided, hold.Captured)
+		if err != nil {
+			log.Println("Void tx.UpdateHold error:", err)
+			return http.StatusInternalServerError, ErrInternal
+		}
+		hold.Status = HoldVoided
+		return http.StatusOK, nil
+	})
+	if err != nil {
+		return nil, statusCode, err
+	}
+	return hold, statusCode, nil
+}
+
+// ExpireHolds marks active holds with passed expiration time as expired
+func (s *Service) ExpireHolds(ctx context.Context) (int64, error) {
+	count, err := s.repo.ExpireHolds(ctx)
+	if err != nil {
+		log.Println("ExpireHolds s.repo.ExpireHolds error:", err)
+		return 0, ErrInternal
+	}
+	return count, nil
+}
+
+// RunHoldExpiry expires stale holds every ExpiryInterval until ctx is done
+func (s *Service) RunHoldExpiry(ctx context.Context) {
+	ticker := time.NewTicker(s.holds.ExpiryInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			count, err := s.ExpireHolds(ctx)
+			if err == nil && count > 0 {
+				log.Println("RunHoldExpiry expired holds:", count)
+			}
+		}
+	}
+}
+
+// GetBalance returns ledger balance, held amount and available balance of account
+func (s *Service) GetBalance(ctx context.Context, id int64) (*types.Balance, int, error) {
+	acc, err := s.repo.AccountByID(ctx, id)
+	if err == ErrNotFound {
+		log.Println("GetBalance s.repo.AccountByID not found:", err)
+		return nil, http.StatusNotFound, ErrNotFound
+	}
+	if err != nil {
+		log.Println("GetBalance s.repo.AccountByID error:", err)
+		return nil, http.StatusInternalServerError, ErrInternal
+	}
+	held, err := s.repo.HeldAmount(ctx, id, nil)
+	if err != nil {
+		log.Println("GetBalance s.repo.HeldAmount error:", err)
+		return nil, http.StatusInternalServerError, ErrInternal
+	}
+
+	balance := &types.Balance{Balance: acc.Balance, Held: held}
+	balance.Available = balance.Balance - balance.Held
+	return balance, http.StatusOK, nil
+}
+
+// lockActiveHold locks hold of partner which is active and not expired
+func lockActiveHold(ctx context.Context, tx Store, partnerID string, id int64) (*types.Hold, int, error) {
+	hold, live, err := tx.LockHold(ctx, partnerID, id)
+	if err == ErrHoldNotFound {
+		log.Println("lockActiveHold tx.LockHold not found:", err)
+		return nil, http.StatusNotFound, ErrHoldNotFound
+	}
+	if err != nil {
+		log.Println("lockActiveHold tx.LockHold error:", err)
+		return nil, http.StatusInternalServerError, ErrInternal
+	}
+	if hold.Status != HoldActive || !live {
+		log.Println("lockActiveHold hold status error:", ErrHoldNotActive)
+		return nil, http.StatusConflict, ErrHoldNotActive
+	}
+	return hold, http.StatusOK, nil
+}
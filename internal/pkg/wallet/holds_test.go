@@ -0,0 +1,184 @@
+package wallet
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/SYSTEMTerror/GoWallet/internal/pkg/types"
+)
+
+// holdOf returns stored hold
+func holdOf(t *testing.T, repo *MemoryRepository, id int64) types.Hold {
+	t.Helper()
+	var hold types.Hold
+	repo.read(func(d *memoryData) error {
+		hold = d.holds[id]
+		return nil
+	})
+	return hold
+}
+
+// checkBalance compares ledger balance, held amount and available balance of account
+func checkBalance(t *testing.T, s *Service, accID int64, want types.Balance) {
+	t.Helper()
+	balance, _, err := s.GetBalance(context.Background(), accID)
+	if err != nil {
+		t.Fatalf("GetBalance error: %v", err)
+	}
+	if *balance != want {
+		t.Errorf("balance = %+v, want %+v", *balance, want)
+	}
+}
+
+func TestHold_HeldAmountNotAvailable(t *testing.T) {
+	s, _ := newMemoryService(t)
+	acc := newMemoryAccount(t, s, "992000000001", 1000)
+	recipient := newMemoryAccount(t, s, "992000000002", 0)
+	ctx := context.Background()
+
+	hold, code, err := s.Authorize(ctx, "shop", &types.Hold{AccID: acc.ID, Amount: 600})
+	if err != nil || code != http.StatusOK || hold.Status != HoldActive || hold.Currency != "USD" {
+		t.Fatalf("Authorize = %+v, %d, %v, want active hold", hold, code, err)
+	}
+	checkBalance(t, s, acc.ID, types.Balance{Balance: 1000, Held: 600, Available: 400})
+
+	if _, code, err := s.Transaction(ctx, &types.Transaction{AccID: acc.ID, Amount: -401}); code != http.StatusBadRequest || err != ErrNotEnoughFunds {
+		t.Errorf("withdrawal over available = %d, %v, want 400 and %v", code, err, ErrNotEnoughFunds)
+	}
+	if _, code, err := s.Transfer(ctx, &types.Transfer{AccID: acc.ID, Phone: recipient.Phone, Amount: 401}); code != http.StatusBadRequest || err != ErrNotEnoughFunds {
+		t.Errorf("transfer over available = %d, %v, want 400 and %v", code, err, ErrNotEnoughFunds)
+	}
+	if _, code, err := s.Authorize(ctx, "shop", &types.Hold{AccID: acc.ID, Amount: 401}); code != http.StatusBadRequest || err != ErrNotEnoughFunds {
+		t.Errorf("second hold over available = %d, %v, want 400 and %v", code, err, ErrNotEnoughFunds)
+	}
+	if _, _, err := s.Transaction(ctx, &types.Transaction{AccID: acc.ID, Amount: -400}); err != nil {
+		t.Errorf("withdrawal of available error: %v", err)
+	}
+	checkBalance(t, s, acc.ID, types.Balance{Balance: 600, Held: 600, Available: 0})
+
+	// capture may spend held funds which are not available to others
+	if _, _, err := s.Capture(ctx, "shop", hold.ID, 0); err != nil {
+		t.Fatalf("Capture error: %v", err)
+	}
+	checkBalance(t, s, acc.ID, types.Balance{Balance: 0, Held: 0, Available: 0})
+}
+
+func TestAuthorize_MaxOperation(t *testing.T) {
+	s, repo := newMemoryService(t)
+	acc := newMemoryAccount(t, s, "992000000001", 1000)
+	repo.AddLimit(&types.LimitRule{Tier: TierAnonymous, Currency: "USD", Kind: LimitMaxOperation, Direction: DirectionDebit, Amount: 500})
+	ctx := context.Background()
+
+	_, code, err := s.Authorize(ctx, "shop", &types.Hold{AccID: acc.ID, Amount: 501})
+	var limitErr *LimitError
+	if code != http.StatusBadRequest || !errors.As(err, &limitErr) || limitErr.Kind != LimitMaxOperation || limitErr.Value != 501 {
+		t.Fatalf("Authorize over max operation = %d, %v, want 400 and max_operation LimitError", code, err)
+	}
+	checkBalance(t, s, acc.ID, types.Balance{Balance: 1000, Held: 0, Available: 1000})
+
+	if _, _, err := s.Authorize(ctx, "shop", &types.Hold{AccID: acc.ID, Amount: 500}); err != nil {
+		t.Errorf("Authorize within max operation error: %v", err)
+	}
+}
+
+func TestCapture_Partial(t *testing.T) {
+	s, repo := newMemoryService(t)
+	acc := newMemoryAccount(t, s, "992000000001", 1000)
+	ctx := context.Background()
+
+	hold, _, err := s.Authorize(ctx, "shop", &types.Hold{AccID: acc.ID, Amount: 600})
+	if err != nil {
+		t.Fatalf("Authorize error: %v", err)
+	}
+
+	if _, code, err := s.Capture(ctx, "shop", hold.ID, 601); code != http.StatusBadRequest || err != ErrInvalidAmount {
+		t.Errorf("Capture over hold = %d, %v, want 400 and %v", code, err, ErrInvalidAmount)
+	}
+	if _, code, err := s.Capture(ctx, "shop", hold.ID, -1); code != http.StatusBadRequest || err != ErrInvalidAmount {
+		t.Errorf("Capture of negative amount = %d, %v, want 400 and %v", code, err, ErrInvalidAmount)
+	}
+	if _, code, err := s.Capture(ctx, "other", hold.ID, 100); code != http.StatusNotFound || err != ErrHoldNotFound {
+		t.Errorf("Capture by other partner = %d, %v, want 404 and %v", code, err, ErrHoldNotFound)
+	}
+
+	transaction, code, err := s.Capture(ctx, "shop", hold.ID, 250)
+	if err != nil || code != http.StatusOK {
+		t.Fatalf("Capture = %d, %v, want 200", code, err)
+	}
+	if transaction.Amount != -250 || transaction.AccID != acc.ID || transaction.HoldID == nil || *transaction.HoldID != hold.ID {
+		t.Errorf("captured transaction = %+v, want withdrawal of 250 referencing hold", transaction)
+	}
+	if got := holdOf(t, repo, hold.ID); got.Status != HoldCaptured || got.Captured != 250 {
+		t.Errorf("hold = %+v, want captured 250", got)
+	}
+	// the rest of hold is released
+	checkBalance(t, s, acc.ID, types.Balance{Balance: 750, Held: 0, Available: 750})
+
+	if _, code, err := s.Capture(ctx, "shop", hold.ID, 100); code != http.StatusConflict || err != ErrHoldNotActive {
+		t.Errorf("second Capture = %d, %v, want 409 and %v", code, err, ErrHoldNotActive)
+	}
+	if _, code, err := s.Void(ctx, "shop", hold.ID); code != http.StatusConflict || err != ErrHoldNotActive {
+		t.Errorf("Void of captured hold = %d, %v, want 409 and %v", code, err, ErrHoldNotActive)
+	}
+	checkBalance(t, s, acc.ID, types.Balance{Balance: 750, Held: 0, Available: 750})
+}
+
+func TestCapture_Expired(t *testing.T) {
+	s, repo := newMemoryService(t)
+	acc := newMemoryAccount(t, s, "992000000001", 1000)
+	ctx := context.Background()
+
+	hold, _, err := s.Authorize(ctx, "shop", &types.Hold{AccID: acc.ID, Amount: 600})
+	if err != nil {
+		t.Fatalf("Authorize error: %v", err)
+	}
+	repo.write(func(d *memoryData) error {
+		h := d.holds[hold.ID]
+		h.Expires = time.Now().Add(-time.Second)
+		d.holds[hold.ID] = h
+		return nil
+	})
+
+	// expired hold releases funds before expiry job marks it
+	checkBalance(t, s, acc.ID, types.Balance{Balance: 1000, Held: 0, Available: 1000})
+	if _, code, err := s.Capture(ctx, "shop", hold.ID, 0); code != http.StatusConflict || err != ErrHoldNotActive {
+		t.Errorf("Capture of expired hold = %d, %v, want 409 and %v", code, err, ErrHoldNotActive)
+	}
+	if _, code, err := s.Void(ctx, "shop", hold.ID); code != http.StatusConflict || err != ErrHoldNotActive {
+		t.Errorf("Void of expired hold = %d, %v, want 409 and %v", code, err, ErrHoldNotActive)
+	}
+
+	count, err := s.ExpireHolds(ctx)
+	if err != nil || count != 1 {
+		t.Errorf("ExpireHolds = %d, %v, want 1", count, err)
+	}
+	if got := holdOf(t, repo, hold.ID); got.Status != HoldExpired || got.Captured != 0 {
+		t.Errorf("hold = %+v, want expired", got)
+	}
+	if count, _ := s.ExpireHolds(ctx); count != 0 {
+		t.Errorf("second ExpireHolds = %d, want 0", count)
+	}
+	checkBalance(t, s, acc.ID, types.Balance{Balance: 1000, Held: 0, Available: 1000})
+}
+
+func TestVoid(t *testing.T) {
+	s, _ := newMemoryService(t)
+	acc := newMemoryAccount(t, s, "992000000001", 1000)
+	ctx := context.Background()
+
+	hold, _, err := s.Authorize(ctx, "shop", &types.Hold{AccID: acc.ID, Amount: 600})
+	if err != nil {
+		t.Fatalf("Authorize error: %v", err)
+	}
+	voided, code, err := s.Void(ctx, "shop", hold.ID)
+	if err != nil || code != http.StatusOK || voided.Status != HoldVoided {
+		t.Fatalf("Void = %+v, %d, %v, want voided hold", voided, code, err)
+	}
+	checkBalance(t, s, acc.ID, types.Balance{Balance: 1000, Held: 0, Available: 1000})
+	if _, code, err := s.Capture(ctx, "shop", hold.ID, 0); code != http.StatusConflict || err != ErrHoldNotActive {
+		t.Errorf("Capture of voided hold = %d, %v, want 409 and %v", code, err, ErrHoldNotActive)
+	}
+}
@@ -0,0 +1,123 @@
+package wallet
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/SYSTEMTerror/GoWallet/internal/pkg/types"
+)
+
+// Endpoints which idempotency keys are scoped to
+const (
+	EndpointTransaction = "transaction"
+	EndpointTransfer    = "transfer"
+	EndpointExchange    = "exchange"
+)
+
+const maxIdempotencyKeyLen = 255
+
+var (
+	ErrInvalidIdempotencyKey = errors.New("invalid idempotency key")
+	ErrIdempotencyMismatch   = errors.New("idempotency key reused with different request")
+)
+
+// IdempotentTransaction works like Transaction, but replay with the same key returns original result
+func (s *Service) IdempotentTransaction(ctx context.Context, key string, item *types.Transaction) (*types.Transaction, int, error) {
+	item.RefID, item.ReversalOf, item.HoldID, item.Rate = nil, nil, nil, ""
+	result := &types.Transaction{}
+	statusCode, err := s.idempotent(ctx, item.AccID, EndpointTransaction, key, item, result, func(tx Store) (interface{}, int, error) {
+		statusCode, err := s.transaction(ctx, tx, item)
+		return item, statusCode, err
+	})
+	if err != nil {
+		return nil, statusCode, err
+	}
+	return result, statusCode, nil
+}
+
+// IdempotentTransfer works like Transfer, but replay with the same key returns original result
+func (s *Service) IdempotentTransfer(ctx context.Context, key string, item *types.Transfer) (*types.TransferResult, int, error) {
+	if item.Amount <= 0 {
+		log.Println("IdempotentTransfer amount error:", ErrInvalidAmount)
+		return nil, http.StatusBadRequest, ErrInvalidAmount
+	}
+
+	result := &types.TransferResult{}
+	statusCode, err := s.idempotent(ctx, item.AccID, EndpointTransfer, key, item, result, func(tx Store) (interface{}, int, error) {
+		return s.transfer(ctx, tx, item)
+	})
+	if err != nil {
+		return nil, statusCode, err
+	}
+	return result, statusCode, nil
+}
+
+// idempotent runs fn once per key of account and endpoint and stores its result with hash of request.
+// Key row is inserted in the same transaction as fn, so concurrent replays wait for the first request.
+// Replay unmarshals stored result into result, replay with different request returns ErrIdempotencyMismatch
+func (s *Service) idempotent(ctx context.Context, accID int64, endpoint string, key string, request interface{}, result interface{}, fn func(tx Store) (interface{}, int, error)) (int, error) {
+	if key == "" || len(key) > maxIdempotencyKeyLen {
+		log.Println("idempotent key error:", ErrInvalidIdempotencyKey)
+		return http.StatusBadRequest, ErrInvalidIdempotencyKey
+	}
+
+	data, err := json.Marshal(request)
+	if err != nil {
+		log.Println("idempotent json.Marshal error:", err)
+		return http.StatusInternalServerError, ErrInternal
+	}
+	sum := sha256.Sum256(data)
+	requestHash := hex.EncodeToString(sum[:])
+
+	return s.inTx(ctx, "idempotent", func(tx Store) (int, error) {
+		created, err := tx.CreateIdempotencyKey(ctx, accID, endpoint, key, requestHash)
+		if err != nil {
+			log.Println("idempotent tx.CreateIdempotencyKey error:", err)
+			return http.StatusInternalServerError, ErrInternal
+		}
+
+		if !created {
+			storedHash, response, err := tx.IdempotencyKey(ctx, accID, endpoint, key)
+			if err != nil {
+				log.Println("idempotent tx.IdempotencyKey error:", err)
+				return http.StatusInternalServerError, ErrInternal
+			}
+			if storedHash != requestHash {
+				log.Println("idempotent request hash error:", ErrIdempotencyMismatch)
+				return http.StatusConflict, ErrIdempotencyMismatch
+			}
+			err = json.Unmarshal(response, result)
+			if err != nil {
+				log.Println("idempotent json.Unmarshal error:", err)
+				return http.StatusInternalServerError, ErrInternal
+			}
+			return http.StatusOK, nil
+		}
+
+		v, statusCode, err := fn(tx)
+		if err != nil {
+			return statusCode, err
+		}
+		response, err := json.Marshal(v)
+		if err != nil {
+			log.Println("idempotent json.Marshal error:", err)
+			return http.StatusInternalServerError, ErrInternal
+		}
+		err = tx.SetIdempotencyResponse(ctx, accID, endpoint, key, response)
+		if err != nil {
+			log.Println("idempotent tx.SetIdempotencyResponse error:", err)
+			return http.StatusInternalServerError, ErrInternal
+		}
+		err = json.Unmarshal(response, result)
+		if err != nil {
+			log.Println("idempotent json.Unmarshal error:", err)
+			return http.StatusInternalServerError, ErrInternal
+		}
+		return statusCode, nil
+	})
+}
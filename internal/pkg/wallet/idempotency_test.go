@@ -0,0 +1,119 @@
+package wallet
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/SYSTEMTerror/GoWallet/internal/pkg/types"
+)
+
+func TestIdempotentTransaction_Replay(t *testing.T) {
+	s, _ := newMemoryService(t)
+	acc := newMemoryAccount(t, s, "992000000001", 1000)
+	ctx := context.Background()
+
+	first, code, err := s.IdempotentTransaction(ctx, "key-1", &types.Transaction{AccID: acc.ID, Amount: -300})
+	if err != nil || code != http.StatusOK {
+		t.Fatalf("IdempotentTransaction = %d, %v, want 200", code, err)
+	}
+	replay, code, err := s.IdempotentTransaction(ctx, "key-1", &types.Transaction{AccID: acc.ID, Amount: -300})
+	if err != nil || code != http.StatusOK {
+		t.Fatalf("replayed IdempotentTransaction = %d, %v, want 200", code, err)
+	}
+	if replay.ID != first.ID || replay.Amount != first.Amount || !replay.Created.Equal(first.Created) {
+		t.Errorf("replay = %+v, want stored result %+v", replay, first)
+	}
+	if got := balanceOf(t, s, acc.ID); got != 700 {
+		t.Errorf("balance after replay = %d, want 700 (withdrawn once)", got)
+	}
+
+	// keys are scoped to account and endpoint
+	other := newMemoryAccount(t, s, "992000000002", 1000)
+	_, _, err = s.IdempotentTransaction(ctx, "key-1", &types.Transaction{AccID: other.ID, Amount: -300})
+	if err != nil {
+		t.Fatalf("IdempotentTransaction of other account error: %v", err)
+	}
+	if got := balanceOf(t, s, other.ID); got != 700 {
+		t.Errorf("balance of other account = %d, want 700", got)
+	}
+}
+
+func TestIdempotentTransaction_Mismatch(t *testing.T) {
+	s, _ := newMemoryService(t)
+	acc := newMemoryAccount(t, s, "992000000001", 1000)
+	ctx := context.Background()
+
+	_, _, err := s.IdempotentTransaction(ctx, "key-1", &types.Transaction{AccID: acc.ID, Amount: -300})
+	if err != nil {
+		t.Fatalf("IdempotentTransaction error: %v", err)
+	}
+	_, code, err := s.IdempotentTransaction(ctx, "key-1", &types.Transaction{AccID: acc.ID, Amount: -400})
+	if code != http.StatusConflict || !errors.Is(err, ErrIdempotencyMismatch) {
+		t.Errorf("IdempotentTransaction with different body = %d, %v, want 409 and %v", code, err, ErrIdempotencyMismatch)
+	}
+	if got := balanceOf(t, s, acc.ID); got != 700 {
+		t.Errorf("balance = %d, want 700", got)
+	}
+}
+
+func TestIdempotentTransaction_FailedAttemptNotStored(t *testing.T) {
+	s, _ := newMemoryService(t)
+	acc := newMemoryAccount(t, s, "992000000001", 100)
+	ctx := context.Background()
+
+	_, code, err := s.IdempotentTransaction(ctx, "key-1", &types.Transaction{AccID: acc.ID, Amount: -300})
+	if code != http.StatusBadRequest || !errors.Is(err, ErrNotEnoughFunds) {
+		t.Fatalf("IdempotentTransaction without funds = %d, %v, want 400 and %v", code, err, ErrNotEnoughFunds)
+	}
+
+	// retry of scheduler with the same key succeeds once funds arrive
+	_, _, err = s.Transaction(ctx, &types.Transaction{AccID: acc.ID, Amount: 500})
+	if err != nil {
+		t.Fatalf("Transaction top-up error: %v", err)
+	}
+	transaction, code, err := s.IdempotentTransaction(ctx, "key-1", &types.Transaction{AccID: acc.ID, Amount: -300})
+	if err != nil || code != http.StatusOK || transaction.ID == 0 {
+		t.Fatalf("retried IdempotentTransaction = %+v, %d, %v, want new transaction", transaction, code, err)
+	}
+	if got := balanceOf(t, s, acc.ID); got != 300 {
+		t.Errorf("balance = %d, want 300", got)
+	}
+}
+
+func TestIdempotentTransfer_Replay(t *testing.T) {
+	s, _ := newMemoryService(t)
+	sender := newMemoryAccount(t, s, "992000000001", 1000)
+	recipient := newMemoryAccount(t, s, "992000000002", 0)
+	ctx := context.Background()
+
+	item := &types.Transfer{AccID: sender.ID, Phone: recipient.Phone, Amount: 250}
+	first, _, err := s.IdempotentTransfer(ctx, "key-1", item)
+	if err != nil {
+		t.Fatalf("IdempotentTransfer error: %v", err)
+	}
+	replay, _, err := s.IdempotentTransfer(ctx, "key-1", &types.Transfer{AccID: sender.ID, Phone: recipient.Phone, Amount: 250})
+	if err != nil {
+		t.Fatalf("replayed IdempotentTransfer error: %v", err)
+	}
+	if replay.Debit.ID != first.Debit.ID || replay.Credit.ID != first.Credit.ID {
+		t.Errorf("replay = %+v, want stored result %+v", replay, first)
+	}
+	if got := balanceOf(t, s, recipient.ID); got != 250 {
+		t.Errorf("recipient balance = %d, want 250", got)
+	}
+}
+
+func TestIdempotent_InvalidKey(t *testing.T) {
+	s, _ := newMemoryService(t)
+	acc := newMemoryAccount(t, s, "992000000001", 1000)
+
+	for _, key := range []string{"", strings.Repeat("k", maxIdempotencyKeyLen+1)} {
+		_, code, err := s.IdempotentTransaction(context.Background(), key, &types.Transaction{AccID: acc.ID, Amount: -1})
+		if code != http.StatusBadRequest || !errors.Is(err, ErrInvalidIdempotencyKey) {
+			t.Errorf("IdempotentTransaction with key of %d characters = %d, %v, want 400", len(key), code, err)
+		}
+	}
+}
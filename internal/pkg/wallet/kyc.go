@@ -0,0 +1,176 @@
+package wallet
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/SYSTEMTerror/GoWallet/internal/pkg/types"
+)
+
+// Identification tiers of accounts, limits are defined per tier
+const (
+	TierAnonymous  = "anonymous"
+	TierSimplified = "simplified"
+	TierFull       = "full"
+)
+
+// Identification states of account
+const (
+	IdentificationNone     = "none"
+	IdentificationPending  = "pending"
+	IdentificationVerified = "verified"
+	IdentificationRejected = "rejected"
+)
+
+var tierRanks = map[string]int{TierAnonymous: 0, TierSimplified: 1, TierFull: 2}
+
+var (
+	ErrInvalidIdentification  = errors.New("invalid identification data")
+	ErrIdentificationPending  = errors.New("identification already pending")
+	ErrIdentificationNotFound = errors.New("identification not found")
+	ErrIdentificationReviewed = errors.New("identification already reviewed")
+)
+
+// SubmitIdentification creates pending request to raise tier of account with personal data and document references.
+// Full tier requires document references
+func (s *Service) SubmitIdentification(ctx context.Context, accID int64, item *types.Identification) (*types.Identification, int, error) {
+	rank, ok := tierRanks[item.Tier]
+	if !ok || rank == 0 || strings.TrimSpace(item.FullName) == "" || strings.TrimSpace(item.BirthDate) == "" ||
+		strings.TrimSpace(item.DocumentType) == "" || strings.TrimSpace(item.DocumentNumber) == "" ||
+		(item.Tier == TierFull && len(item.Documents) == 0) {
+		log.Println("SubmitIdentification data error:", ErrInvalidIdentification)
+		return nil, http.StatusBadRequest, ErrInvalidIdentification
+	}
+	if item.Documents == nil {
+		item.Documents = []string{}
+	}
+
+	result := &types.Identification{
+		AccID:          accID,
+		Tier:           item.Tier,
+		FullName:       item.FullName,
+		BirthDate:      item.BirthDate,
+		DocumentType:   item.DocumentType,
+		DocumentNumber: item.DocumentNumber,
+		Documents:      item.Documents,
+	}
+	statusCode, err := s.inTx(ctx, "SubmitIdentification", func(tx Store) (int, error) {
+		acc, err := tx.LockAccount(ctx, accID)
+		if err == ErrNotFound {
+			log.Println("SubmitIdentification tx.LockAccount not found:", err)
+			return http.StatusNotFound, ErrNotFound
+		}
+		if err != nil {
+			log.Println("SubmitIdentification tx.LockAccount error:", err)
+			return http.StatusInternalServerError, ErrInternal
+		}
+		if tierRanks[acc.Tier] >= rank {
+			log.Println("SubmitIdentification tier is not higher than current:", acc.Tier)
+			return http.StatusBadRequest, ErrInvalidIdentification
+		}
+
+		pending, err := tx.HasPendingIdentification(ctx, accID)
+		if err != nil {
+			log.Println("SubmitIdentification tx.HasPendingIdentification error:", err)
+			return http.StatusInternalServerError, ErrInternal
+		}
+		if pending {
+			log.Println("SubmitIdentification error:", ErrIdentificationPending)
+			return http.StatusConflict, ErrIdentificationPending
+		}
+
+		err = tx.CreateIdentification(ctx, result)
+		if err != nil {
+			log.Println("SubmitIdentification tx.CreateIdentification error:", err)
+			return http.StatusInternalServerError, ErrInternal
+		}
+		return http.StatusOK, nil
+	})
+	if err != nil {
+		return nil, statusCode, err
+	}
+	return result, statusCode, nil
+}
+
+// GetIdentificationState returns tier of account and its latest identification request
+func (s *Service) GetIdentificationState(ctx context.Context, accID int64) (*types.IdentificationState, int, error) {
+	state := &types.IdentificationState{Status: IdentificationNone}
+	acc, err := s.repo.AccountByID(ctx, accID)
+	if err == ErrNotFound {
+		log.Println("GetIdentificationState s.repo.AccountByID not found:", err)
+		return nil, http.StatusNotFound, ErrNotFound
+	}
+	if err != nil {
+		log.Println("GetIdentificationState s.repo.AccountByID error:", err)
+		return nil, http.StatusInternalServerError, ErrInternal
+	}
+	state.Tier = acc.Tier
+
+	latest, err := s.repo.LatestIdentification(ctx, accID)
+	if err != nil {
+		log.Println("GetIdentificationState s.repo.LatestIdentification error:", err)
+		return nil, http.StatusInternalServerError, ErrInternal
+	}
+	if latest == nil {
+		return state, http.StatusOK, nil
+	}
+	state.Status = latest.Status
+	state.Reason = latest.Reason
+	state.Latest = latest
+	return state, http.StatusOK, nil
+}
+
+// ApproveIdentification verifies pending request and raises tier of account
+func (s *Service) ApproveIdentification(ctx context.Context, reviewer string, id int64) (*types.Identification, int, error) {
+	return s.reviewIdentification(ctx, reviewer, id, IdentificationVerified, "")
+}
+
+// RejectIdentification rejects pending request with reason, tier of account doesn't change
+func (s *Service) RejectIdentification(ctx context.Context, reviewer string, id int64, reason string) (*types.Identification, int, error) {
+	if strings.TrimSpace(reason) == "" {
+		log.Println("RejectIdentification reason error:", ErrInvalidIdentification)
+		return nil, http.StatusBadRequest, ErrInvalidIdentification
+	}
+	return s.reviewIdentification(ctx, reviewer, id, IdentificationRejected, reason)
+}
+
+func (s *Service) reviewIdentification(ctx context.Context, reviewer string, id int64, status string, reason string) (*types.Identification, int, error) {
+	var item *types.Identification
+	statusCode, err := s.inTx(ctx, "reviewIdentification", func(tx Store) (int, error) {
+		pending, err := tx.LockIdentification(ctx, id)
+		if err == ErrIdentificationNotFound {
+			log.Println("reviewIdentification tx.LockIdentification not found:", err)
+			return http.StatusNotFound, ErrIdentificationNotFound
+		}
+		if err != nil {
+			log.Println("reviewIdentification tx.LockIdentification error:", err)
+			return http.StatusInternalServerError, ErrInternal
+		}
+		if pending.Status != IdentificationPending {
+			log.Println("reviewIdentification error:", ErrIdentificationReviewed)
+			return http.StatusConflict, ErrIdentificationReviewed
+		}
+
+		item, err = tx.ReviewIdentification(ctx, id, status, reason, reviewer)
+		if err != nil {
+			log.Println("reviewIdentification tx.ReviewIdentification error:", err)
+			return http.StatusInternalServerError, ErrInternal
+		}
+
+		if status == IdentificationVerified {
+			err = tx.SetTier(ctx, item.AccID, item.Tier, item.Tier != TierAnonymous)
+			if err != nil {
+				log.Println("reviewIdentification tx.SetTier error:", err)
+				return http.StatusInternalServerError, ErrInternal
+			}
+		}
+		return http.StatusOK, nil
+	})
+	if err != nil {
+		return nil, statusCode, err
+	}
+	return item, statusCode, nil
+}
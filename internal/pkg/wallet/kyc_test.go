@@ -0,0 +1,109 @@
+package wallet
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/SYSTEMTerror/GoWallet/internal/pkg/types"
+)
+
+// submitIdentification submits request of account to raise its tier
+func submitIdentification(t *testing.T, s *Service, accID int64, tier string) *types.Identification {
+	t.Helper()
+	item, _, err := s.SubmitIdentification(context.Background(), accID, &types.Identification{
+		Tier: tier, FullName: "Ali Valiev", BirthDate: "1990-01-01", DocumentType: "passport", DocumentNumber: "A1234567", Documents: []string{"scan-1"},
+	})
+	if err != nil {
+		t.Fatalf("SubmitIdentification error: %v", err)
+	}
+	return item
+}
+
+func TestApproveIdentification(t *testing.T) {
+	s, _ := newMemoryService(t)
+	acc := newMemoryAccount(t, s, "992000000001", 0)
+	ctx := context.Background()
+	if acc.Tier != TierAnonymous || acc.Identified {
+		t.Fatalf("new account = %+v, want anonymous", acc)
+	}
+
+	item := submitIdentification(t, s, acc.ID, TierFull)
+	if item.Status != IdentificationPending {
+		t.Errorf("submitted status = %q, want %q", item.Status, IdentificationPending)
+	}
+	_, code, err := s.SubmitIdentification(ctx, acc.ID, item)
+	if code != http.StatusConflict || err != ErrIdentificationPending {
+		t.Errorf("second SubmitIdentification = %d, %v, want 409 and %v", code, err, ErrIdentificationPending)
+	}
+
+	approved, code, err := s.ApproveIdentification(ctx, "support", item.ID)
+	if err != nil || code != http.StatusOK {
+		t.Fatalf("ApproveIdentification = %d, %v, want 200", code, err)
+	}
+	if approved.Status != IdentificationVerified || approved.ReviewedBy != "support" || approved.Reviewed == nil {
+		t.Errorf("approved = %+v, want verified by support", approved)
+	}
+
+	got, _, err := s.GetAccountByID(ctx, acc.ID)
+	if err != nil || got.Tier != TierFull || !got.Identified {
+		t.Errorf("account after approval = %+v, %v, want identified with full tier", got, err)
+	}
+	state, _, err := s.GetIdentificationState(ctx, acc.ID)
+	if err != nil || state.Tier != TierFull || state.Status != IdentificationVerified || state.Latest.ID != item.ID {
+		t.Errorf("state = %+v, %v, want verified full tier", state, err)
+	}
+
+	if result, code, err := s.ApproveIdentification(ctx, "support", item.ID); result != nil || code != http.StatusConflict || err != ErrIdentificationReviewed {
+		t.Errorf("ApproveIdentification of verified request = %v, %d, %v, want 409 and %v", result, code, err, ErrIdentificationReviewed)
+	}
+	if result, code, err := s.RejectIdentification(ctx, "support", item.ID, "late"); result != nil || code != http.StatusConflict || err != ErrIdentificationReviewed {
+		t.Errorf("RejectIdentification of verified request = %v, %d, %v, want 409 and %v", result, code, err, ErrIdentificationReviewed)
+	}
+}
+
+func TestRejectIdentification(t *testing.T) {
+	s, _ := newMemoryService(t)
+	acc := newMemoryAccount(t, s, "992000000001", 0)
+	ctx := context.Background()
+	item := submitIdentification(t, s, acc.ID, TierSimplified)
+
+	_, code, err := s.RejectIdentification(ctx, "support", item.ID, "  ")
+	if code != http.StatusBadRequest || err != ErrInvalidIdentification {
+		t.Errorf("RejectIdentification without reason = %d, %v, want 400 and %v", code, err, ErrInvalidIdentification)
+	}
+
+	rejected, code, err := s.RejectIdentification(ctx, "support", item.ID, "blurred scan")
+	if err != nil || code != http.StatusOK {
+		t.Fatalf("RejectIdentification = %d, %v, want 200", code, err)
+	}
+	if rejected.Status != IdentificationRejected || rejected.Reason != "blurred scan" || rejected.ReviewedBy != "support" {
+		t.Errorf("rejected = %+v, want rejected by support with reason", rejected)
+	}
+
+	state, _, err := s.GetIdentificationState(ctx, acc.ID)
+	if err != nil || state.Tier != TierAnonymous || state.Status != IdentificationRejected || state.Reason != "blurred scan" {
+		t.Errorf("state = %+v, %v, want rejected and anonymous tier", state, err)
+	}
+
+	// rejected account may submit again and be approved
+	second := submitIdentification(t, s, acc.ID, TierSimplified)
+	if _, _, err := s.ApproveIdentification(ctx, "support", second.ID); err != nil {
+		t.Fatalf("ApproveIdentification error: %v", err)
+	}
+	if got, _, _ := s.GetAccountByID(ctx, acc.ID); got.Tier != TierSimplified || !got.Identified {
+		t.Errorf("account after approval = %+v, want simplified tier", got)
+	}
+}
+
+func TestReviewIdentification_NotFound(t *testing.T) {
+	s, _ := newMemoryService(t)
+	ctx := context.Background()
+
+	if _, code, err := s.ApproveIdentification(ctx, "support", 1000); code != http.StatusNotFound || err != ErrIdentificationNotFound {
+		t.Errorf("ApproveIdentification of unknown request = %d, %v, want 404", code, err)
+	}
+	if _, code, err := s.RejectIdentification(ctx, "support", 1000, "unknown"); code != http.StatusNotFound || err != ErrIdentificationNotFound {
+		t.Errorf("RejectIdentification of unknown request = %d, %v, want 404", code, err)
+	}
+}
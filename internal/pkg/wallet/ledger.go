@@ -0,0 +1,94 @@
+package wallet
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/SYSTEMTerror/GoWallet/internal/pkg/types"
+)
+
+// System ledger accounts which are counterparts of wallet postings
+const (
+	SystemCashIn  = "cash_in"
+	SystemCashOut = "cash_out"
+	SystemFee     = "fee"
+	// SystemExchange is currency position of wallet, counterpart of both sides of exchange
+	SystemExchange = "exchange"
+)
+
+// Kinds of journal entries
+const (
+	EntryTopUp      = "top_up"
+	EntryWithdrawal = "withdrawal"
+	EntryTransfer   = "transfer"
+	EntryReversal   = "reversal"
+	EntryExchange   = "exchange"
+)
+
+var ErrUnbalanced = errors.New("ledger is unbalanced")
+
+// post records journal entry with given postings and applies wallet postings to accounts balance.
+// Postings of one entry must sum to zero in every currency. Must be called inside transaction with wallet rows locked
+func post(ctx context.Context, tx Store, kind string, postings []*types.Posting) (int64, error) {
+	sums := map[string]int64{}
+	for _, p := range postings {
+		if p.Currency == "" {
+			return 0, ErrUnbalanced
+		}
+		sums[p.Currency] += p.Amount
+	}
+	for _, sum := range sums {
+		if sum != 0 {
+			return 0, ErrUnbalanced
+		}
+	}
+	if len(postings) < 2 {
+		return 0, ErrUnbalanced
+	}
+
+	return tx.Post(ctx, kind, postings)
+}
+
+// movementPostings returns postings of top-up (positive amount) or withdrawal (negative amount) of wallet in currency
+func movementPostings(accID int64, currency string, amount int64) (string, []*types.Posting) {
+	if amount >= 0 {
+		return EntryTopUp, []*types.Posting{
+			{AccID: &accID, Currency: currency, Amount: amount},
+			{System: SystemCashIn, Currency: currency, Amount: -amount},
+		}
+	}
+	return EntryWithdrawal, []*types.Posting{
+		{AccID: &accID, Currency: currency, Amount: amount},
+		{System: SystemCashOut, Currency: currency, Amount: -amount},
+	}
+}
+
+// reversalPostings returns postings which compensate top-up (negative amount) or withdrawal (positive amount) of wallet
+func reversalPostings(accID int64, currency string, amount int64) (string, []*types.Posting) {
+	system := SystemCashIn
+	if amount > 0 {
+		system = SystemCashOut
+	}
+	return EntryReversal, []*types.Posting{
+		{AccID: &accID, Currency: currency, Amount: amount},
+		{System: system, Currency: currency, Amount: -amount},
+	}
+}
+
+// CheckLedger verifies that postings of every currency sum to zero, every journal entry is balanced
+// in every currency and every wallet balance equals sum of its postings
+func (s *Service) CheckLedger(ctx context.Context) (int, error) {
+	violation, err := s.repo.LedgerViolation(ctx)
+	if err != nil {
+		log.Println("CheckLedger s.repo.LedgerViolation error:", err)
+		return http.StatusInternalServerError, ErrInternal
+	}
+	if violation != "" {
+		log.Println("CheckLedger", violation)
+		return http.StatusInternalServerError, ErrUnbalanced
+	}
+
+	return http.StatusOK, nil
+}
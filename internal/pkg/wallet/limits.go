@@ -0,0 +1,87 @@
+package wallet
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+// Kinds of limit rules
+const (
+	LimitMaxBalance      = "max_balance"
+	LimitMaxOperation    = "max_operation"
+	LimitDailyTurnover   = "daily_turnover"
+	LimitMonthlyTurnover = "monthly_turnover"
+)
+
+// DirectionAny in limit rule matches both credit and debit operations
+const DirectionAny = "any"
+
+// LimitError describes limit rule which operation hits. It wraps ErrOutOfLimit
+type LimitError struct {
+	Tier      string `json:"tier"`
+	Currency  string `json:"currency"`
+	Kind      string `json:"kind"`
+	Direction string `json:"direction"`
+	Limit     int64  `json:"limit"`
+	Value     int64  `json:"value"`
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("%s %s limit %d %s of tier %s exceeded: %d", e.Direction, e.Kind, e.Limit, e.Currency, e.Tier, e.Value)
+}
+
+func (e *LimitError) Unwrap() error {
+	return ErrOutOfLimit
+}
+
+// checkLimits evaluates active limit rules of tier and wallet currency against change of locked account balance by amount
+func (s *Service) checkLimits(ctx context.Context, tx Store, accID int64, tier string, currency string, balance int64, amount int64) (int, error) {
+	direction := DirectionCredit
+	if amount < 0 {
+		direction = DirectionDebit
+	}
+
+	rules, err := tx.LimitRules(ctx, tier, currency, direction)
+	if err != nil {
+		log.Println("checkLimits tx.LimitRules error:", err)
+		return http.StatusInternalServerError, ErrInternal
+	}
+
+	dayStart, monthStart := s.periodStarts(time.Now())
+	for _, rule := range rules {
+		var value int64
+		switch rule.Kind {
+		case LimitMaxBalance:
+			if amount <= 0 {
+				continue
+			}
+			value = balance + amount
+		case LimitMaxOperation:
+			value = abs(amount)
+		case LimitDailyTurnover, LimitMonthlyTurnover:
+			since := dayStart
+			if rule.Kind == LimitMonthlyTurnover {
+				since = monthStart
+			}
+			turnover, err := tx.Turnover(ctx, accID, since, rule.Direction)
+			if err != nil {
+				log.Println("checkLimits tx.Turnover error:", err)
+				return http.StatusInternalServerError, ErrInternal
+			}
+			value = turnover + abs(amount)
+		default:
+			continue
+		}
+
+		if value > rule.Amount {
+			limitErr := &LimitError{Tier: rule.Tier, Currency: rule.Currency, Kind: rule.Kind, Direction: rule.Direction, Limit: rule.Amount, Value: value}
+			log.Println("checkLimits error:", limitErr)
+			return http.StatusBadRequest, limitErr
+		}
+	}
+
+	return http.StatusOK, nil
+}
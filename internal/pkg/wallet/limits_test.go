@@ -0,0 +1,99 @@
+package wallet
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/SYSTEMTerror/GoWallet/internal/pkg/types"
+)
+
+func TestCheckLimits_TurnoverWindows(t *testing.T) {
+	dayStart, monthStart := (&Service{location: time.UTC}).periodStarts(time.Now())
+
+	type op struct {
+		amount  int64
+		created time.Time
+	}
+	tests := []struct {
+		name  string
+		rule  types.LimitRule
+		ops   []op
+		next  int64
+		value int64
+	}{
+		{"daily up to limit", types.LimitRule{Kind: LimitDailyTurnover, Direction: DirectionDebit, Amount: 300},
+			[]op{{-100, dayStart}, {-100, dayStart.Add(-time.Second)}}, -200, 0},
+		{"daily over limit", types.LimitRule{Kind: LimitDailyTurnover, Direction: DirectionDebit, Amount: 300},
+			[]op{{-100, dayStart}, {-100, dayStart.Add(-time.Second)}}, -201, 301},
+		{"daily counts operations of today", types.LimitRule{Kind: LimitDailyTurnover, Direction: DirectionDebit, Amount: 300},
+			[]op{{-100, dayStart}, {-100, dayStart.Add(time.Second)}, {-100, dayStart.Add(2 * time.Second)}}, -1, 301},
+		{"daily ignores other direction", types.LimitRule{Kind: LimitDailyTurnover, Direction: DirectionDebit, Amount: 300},
+			[]op{{500, dayStart}, {-100, dayStart}}, -200, 0},
+		{"daily of any direction", types.LimitRule{Kind: LimitDailyTurnover, Direction: DirectionAny, Amount: 300},
+			[]op{{150, dayStart}, {-100, dayStart}}, -51, 301},
+		{"daily of credits", types.LimitRule{Kind: LimitDailyTurnover, Direction: DirectionCredit, Amount: 300},
+			[]op{{250, dayStart}, {-100, dayStart}}, 51, 301},
+		{"monthly up to limit", types.LimitRule{Kind: LimitMonthlyTurnover, Direction: DirectionDebit, Amount: 300},
+			[]op{{-100, monthStart}, {-100, monthStart.Add(-time.Second)}, {-100, dayStart}}, -100, 0},
+		{"monthly over limit", types.LimitRule{Kind: LimitMonthlyTurnover, Direction: DirectionDebit, Amount: 300},
+			[]op{{-100, monthStart}, {-100, monthStart.Add(-time.Second)}, {-100, dayStart}}, -101, 301},
+		{"monthly ignores previous month", types.LimitRule{Kind: LimitMonthlyTurnover, Direction: DirectionDebit, Amount: 300},
+			[]op{{-300, monthStart.Add(-time.Second)}}, -300, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, repo := newMemoryService(t)
+			acc := newMemoryAccount(t, s, "992000000001", 10000)
+			ctx := context.Background()
+			// top-up is out of both windows
+			created := []time.Time{monthStart.Add(-time.Hour)}
+			for _, op := range tt.ops {
+				_, _, err := s.Transaction(ctx, &types.Transaction{AccID: acc.ID, Amount: op.amount})
+				if err != nil {
+					t.Fatalf("Transaction error: %v", err)
+				}
+				created = append(created, op.created)
+			}
+			setCreated(repo, acc.ID, created...)
+
+			rule := tt.rule
+			rule.Tier, rule.Currency = TierAnonymous, "USD"
+			repo.AddLimit(&rule)
+
+			_, code, err := s.Transaction(ctx, &types.Transaction{AccID: acc.ID, Amount: tt.next})
+			if tt.value == 0 {
+				if err != nil {
+					t.Errorf("Transaction within limit = %d, %v, want 200", code, err)
+				}
+				return
+			}
+			var limitErr *LimitError
+			if code != http.StatusBadRequest || !errors.As(err, &limitErr) || !errors.Is(err, ErrOutOfLimit) {
+				t.Fatalf("Transaction over limit = %d, %v, want 400 and LimitError", code, err)
+			}
+			if limitErr.Kind != rule.Kind || limitErr.Direction != rule.Direction || limitErr.Limit != rule.Amount || limitErr.Value != tt.value {
+				t.Errorf("LimitError = %+v, want %s %s limit %d with value %d", limitErr, rule.Direction, rule.Kind, rule.Amount, tt.value)
+			}
+		})
+	}
+}
+
+func TestPeriodStarts_BusinessTimezone(t *testing.T) {
+	dushanbe, err := time.LoadLocation("Asia/Dushanbe")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &Service{location: dushanbe}
+
+	// 20:30 UTC of March 31 is already April 1 in Dushanbe
+	dayStart, monthStart := s.periodStarts(time.Date(2021, 3, 31, 20, 30, 0, 0, time.UTC))
+	if want := time.Date(2021, 3, 31, 19, 0, 0, 0, time.UTC); !dayStart.Equal(want) {
+		t.Errorf("day start = %v, want %v", dayStart, want)
+	}
+	if !monthStart.Equal(dayStart) {
+		t.Errorf("month start = %v, want start of April 1 %v", monthStart, dayStart)
+	}
+}
@@ -0,0 +1,911 @@
+package wallet
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/SYSTEMTerror/GoWallet/internal/pkg/types"
+)
+
+// MemoryRepository stores wallets in memory. It has the same constraints as schema of migrate package
+// and is meant for tests and local runs. Transactions of InTx are serialized, so locks always succeed
+type MemoryRepository struct {
+	*memoryStore
+	mu   sync.RWMutex
+	data *memoryData
+}
+
+// memoryData is content of MemoryRepository, rows are stored by value and copied on read
+type memoryData struct {
+	seq             map[string]int64
+	currencies      map[string]types.Currency
+	accounts        map[int64]types.Account
+	postings        []types.Posting
+	transactions    map[int64]types.Transaction
+	limits          []types.LimitRule
+	fees            []types.FeeRule
+	idempotencyKeys map[idempotencyKey]idempotencyRecord
+	holds           map[int64]types.Hold
+	identifications map[int64]types.Identification
+	rates           []types.ExchangeRate
+}
+
+type idempotencyKey struct {
+	accID    int64
+	endpoint string
+	key      string
+}
+
+type idempotencyRecord struct {
+	requestHash string
+	response    []byte
+}
+
+// NewMemoryRepository returns empty repository, currencies and rules are added by AddCurrency, AddLimit and AddFee
+func NewMemoryRepository() *MemoryRepository {
+	r := &MemoryRepository{data: &memoryData{
+		seq:             map[string]int64{},
+		currencies:      map[string]types.Currency{},
+		accounts:        map[int64]types.Account{},
+		transactions:    map[int64]types.Transaction{},
+		idempotencyKeys: map[idempotencyKey]idempotencyRecord{},
+		holds:           map[int64]types.Hold{},
+		identifications: map[int64]types.Identification{},
+	}}
+	r.memoryStore = &memoryStore{repo: r}
+	return r
+}
+
+// AddCurrency adds or replaces currency
+func (r *MemoryRepository) AddCurrency(currency *types.Currency) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.data.currencies[currency.Code] = *currency
+}
+
+// AddLimit adds active limit rule and fills its ID
+func (r *MemoryRepository) AddLimit(rule *types.LimitRule) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	rule.ID = r.data.next("limits")
+	r.data.limits = append(r.data.limits, *rule)
+}
+
+// AddFee adds active fee rule and fills its ID
+func (r *MemoryRepository) AddFee(rule *types.FeeRule) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	rule.ID = r.data.next("fees")
+	r.data.fees = append(r.data.fees, *rule)
+}
+
+// InTx runs fn on copy of data, which replaces data if fn succeeds
+func (r *MemoryRepository) InTx(ctx context.Context, fn func(tx Store) error) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	data := r.data.clone()
+	err := fn(&memoryStore{repo: r, data: data})
+	if err != nil {
+		return err
+	}
+	r.data = data
+	return nil
+}
+
+// Statement copies header and transactions of statement and calls begin and line without holding lock
+func (r *MemoryRepository) Statement(ctx context.Context, statement *types.Statement, begin func() error, line func(transaction *types.Transaction) error) error {
+	var transactions []*types.Transaction
+	err := r.read(func(data *memoryData) error {
+		err := data.statementHeader(statement)
+		if err != nil {
+			return err
+		}
+		transactions = data.findTransactions(func(t *types.Transaction) bool {
+			return t.AccID == statement.AccID && !t.Created.Before(statement.From) && t.Created.Before(statement.To)
+		}, 0)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	err = begin()
+	if err != nil {
+		return err
+	}
+	for _, transaction := range transactions {
+		err = line(transaction)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *MemoryRepository) read(fn func(data *memoryData) error) error {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return fn(r.data)
+}
+
+func (r *MemoryRepository) write(fn func(data *memoryData) error) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return fn(r.data)
+}
+
+// memoryStore works on data of transaction or, if data is nil, on data of repository under its lock
+type memoryStore struct {
+	repo *MemoryRepository
+	data *memoryData
+}
+
+func (s *memoryStore) read(fn func(data *memoryData) error) error {
+	if s.data != nil {
+		return fn(s.data)
+	}
+	return s.repo.read(fn)
+}
+
+func (s *memoryStore) write(fn func(data *memoryData) error) error {
+	if s.data != nil {
+		return fn(s.data)
+	}
+	return s.repo.write(fn)
+}
+
+func (d *memoryData) next(table string) int64 {
+	d.seq[table]++
+	return d.seq[table]
+}
+
+func (d *memoryData) clone() *memoryData {
+	c := &memoryData{
+		seq:             make(map[string]int64, len(d.seq)),
+		currencies:      make(map[string]types.Currency, len(d.currencies)),
+		accounts:        make(map[int64]types.Account, len(d.accounts)),
+		postings:        append([]types.Posting(nil), d.postings...),
+		transactions:    make(map[int64]types.Transaction, len(d.transactions)),
+		limits:          append([]types.LimitRule(nil), d.limits...),
+		fees:            append([]types.FeeRule(nil), d.fees...),
+		idempotencyKeys: make(map[idempotencyKey]idempotencyRecord, len(d.idempotencyKeys)),
+		holds:           make(map[int64]types.Hold, len(d.holds)),
+		identifications: make(map[int64]types.Identification, len(d.identifications)),
+		rates:           append([]types.ExchangeRate(nil), d.rates...),
+	}
+	for k, v := range d.seq {
+		c.seq[k] = v
+	}
+	for k, v := range d.currencies {
+		c.currencies[k] = v
+	}
+	for k, v := range d.accounts {
+		c.accounts[k] = v
+	}
+	for k, v := range d.transactions {
+		c.transactions[k] = v
+	}
+	for k, v := range d.idempotencyKeys {
+		c.idempotencyKeys[k] = v
+	}
+	for k, v := range d.holds {
+		c.holds[k] = v
+	}
+	for k, v := range d.identifications {
+		c.identifications[k] = v
+	}
+	return c
+}
+
+func (d *memoryData) account(match func(acc *types.Account) bool) (*types.Account, error) {
+	accounts := d.findAccounts(match)
+	if len(accounts) == 0 {
+		return nil, ErrNotFound
+	}
+	return accounts[0], nil
+}
+
+// findAccounts returns copies of matching accounts ordered by id
+func (d *memoryData) findAccounts(match func(acc *types.Account) bool) []*types.Account {
+	accounts := []*types.Account{}
+	for _, acc := range d.accounts {
+		acc := acc
+		if match(&acc) {
+			accounts = append(accounts, &acc)
+		}
+	}
+	sort.Slice(accounts, func(i, j int) bool { return accounts[i].ID < accounts[j].ID })
+	return accounts
+}
+
+// findTransactions returns copies of up to limit (all if zero) matching transactions ordered by created and id
+func (d *memoryData) findTransactions(match func(t *types.Transaction) bool, limit int) []*types.Transaction {
+	transactions := []*types.Transaction{}
+	for _, t := range d.transactions {
+		t := t
+		if match(&t) {
+			transactions = append(transactions, &t)
+		}
+	}
+	sort.Slice(transactions, func(i, j int) bool {
+		if !transactions[i].Created.Equal(transactions[j].Created) {
+			return transactions[i].Created.Before(transactions[j].Created)
+		}
+		return transactions[i].ID < transactions[j].ID
+	})
+	if limit > 0 && len(transactions) > limit {
+		transactions = transactions[:limit]
+	}
+	return transactions
+}
+
+func (d *memoryData) statementHeader(statement *types.Statement) error {
+	acc, ok := d.accounts[statement.AccID]
+	if !ok {
+		return ErrNotFound
+	}
+	statement.Currency = acc.Currency
+	statement.MinorUnits = d.currencies[acc.Currency].MinorUnits
+	statement.Opening = acc.Balance
+	for _, t := range d.transactions {
+		if t.AccID == acc.ID && !t.Created.Before(statement.From) {
+			statement.Opening -= t.Amount - t.Fee
+		}
+	}
+	return nil
+}
+
+// liveRate reports if rate is valid at now
+func liveRate(rate *types.ExchangeRate, now time.Time) bool {
+	return !rate.ValidFrom.After(now) && (rate.ValidTo == nil || rate.ValidTo.After(now))
+}
+
+// newerRate reports if rate a takes precedence over rate b of the same pair
+func newerRate(a *types.ExchangeRate, b *types.ExchangeRate) bool {
+	if !a.ValidFrom.Equal(b.ValidFrom) {
+		return a.ValidFrom.After(b.ValidFrom)
+	}
+	return a.ID > b.ID
+}
+
+func (s *memoryStore) Currencies(ctx context.Context) ([]*types.Currency, error) {
+	currencies := []*types.Currency{}
+	err := s.read(func(d *memoryData) error {
+		for _, currency := range d.currencies {
+			currency := currency
+			currencies = append(currencies, &currency)
+		}
+		return nil
+	})
+	sort.Slice(currencies, func(i, j int) bool { return currencies[i].Code < currencies[j].Code })
+	return currencies, err
+}
+
+func (s *memoryStore) Currency(ctx context.Context, code string) (*types.Currency, error) {
+	var currency types.Currency
+	err := s.read(func(d *memoryData) error {
+		var ok bool
+		currency, ok = d.currencies[code]
+		if !ok {
+			return ErrUnknownCurrency
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &currency, nil
+}
+
+// CreateAccount stores wallet of owner without phone, so phone is unique only among main accounts
+func (s *memoryStore) CreateAccount(ctx context.Context, acc *types.Account) error {
+	return s.write(func(d *memoryData) error {
+		if _, ok := d.currencies[acc.Currency]; !ok {
+			return ErrUnknownCurrency
+		}
+		owner := int64(0)
+		if acc.OwnerID != nil {
+			if _, ok := d.accounts[*acc.OwnerID]; !ok {
+				return ErrNotFound
+			}
+			owner = *acc.OwnerID
+		}
+		for _, other := range d.accounts {
+			if acc.OwnerID == nil && other.OwnerID == nil && other.Phone == acc.Phone {
+				return ErrExist
+			}
+			if owner != 0 && ownerOf(&other) == owner && other.Currency == acc.Currency {
+				return ErrExist
+			}
+		}
+
+		acc.ID = d.next("accounts")
+		acc.Balance = 0
+		acc.Active = true
+		acc.Created = time.Now()
+		if acc.OwnerID != nil {
+			acc.Phone = ""
+		}
+		d.accounts[acc.ID] = *acc
+		return nil
+	})
+}
+
+func (s *memoryStore) AccountByID(ctx context.Context, id int64) (*types.Account, error) {
+	var acc *types.Account
+	err := s.read(func(d *memoryData) error {
+		var err error
+		acc, err = d.account(func(a *types.Account) bool { return a.ID == id })
+		return err
+	})
+	return acc, err
+}
+
+func (s *memoryStore) AccountByPhone(ctx context.Context, phone string) (*types.Account, error) {
+	var acc *types.Account
+	err := s.read(func(d *memoryData) error {
+		var err error
+		acc, err = d.account(func(a *types.Account) bool { return a.OwnerID == nil && a.Phone == phone })
+		return err
+	})
+	return acc, err
+}
+
+func (s *memoryStore) WalletByPhone(ctx context.Context, phone string, currency string) (*types.Account, error) {
+	var acc *types.Account
+	err := s.read(func(d *memoryData) error {
+		owner, err := d.account(func(a *types.Account) bool { return a.OwnerID == nil && a.Phone == phone })
+		if err != nil {
+			return err
+		}
+		acc, err = d.account(func(a *types.Account) bool { return ownerOf(a) == owner.ID && a.Currency == currency })
+		return err
+	})
+	return acc, err
+}
+
+func (s *memoryStore) Wallets(ctx context.Context, ownerID int64) ([]*types.Account, error) {
+	var accounts []*types.Account
+	err := s.read(func(d *memoryData) error {
+		accounts = d.findAccounts(func(a *types.Account) bool { return ownerOf(a) == ownerID })
+		return nil
+	})
+	return accounts, err
+}
+
+func (s *memoryStore) LockAccount(ctx context.Context, id int64) (*types.Account, error) {
+	return s.AccountByID(ctx, id)
+}
+
+func (s *memoryStore) LockAccounts(ctx context.Context, ids ...int64) ([]*types.Account, error) {
+	var accounts []*types.Account
+	err := s.read(func(d *memoryData) error {
+		accounts = d.findAccounts(func(a *types.Account) bool {
+			for _, id := range ids {
+				if a.ID == id {
+					return true
+				}
+			}
+			return false
+		})
+		return nil
+	})
+	return accounts, err
+}
+
+func (s *memoryStore) SetTier(ctx context.Context, ownerID int64, tier string, identified bool) error {
+	return s.write(func(d *memoryData) error {
+		for id, acc := range d.accounts {
+			if ownerOf(&acc) == ownerID {
+				acc.Tier, acc.Identified = tier, identified
+				d.accounts[id] = acc
+			}
+		}
+		return nil
+	})
+}
+
+func (s *memoryStore) Post(ctx context.Context, kind string, postings []*types.Posting) (int64, error) {
+	var entryID int64
+	err := s.write(func(d *memoryData) error {
+		for _, p := range postings {
+			if p.AccID == nil {
+				continue
+			}
+			if _, ok := d.accounts[*p.AccID]; !ok {
+				return ErrNotFound
+			}
+		}
+
+		entryID = d.next("journal_entries")
+		for _, p := range postings {
+			p.EntryID = entryID
+			p.ID = d.next("postings")
+			d.postings = append(d.postings, *p)
+			if p.AccID != nil {
+				acc := d.accounts[*p.AccID]
+				acc.Balance += p.Amount
+				d.accounts[acc.ID] = acc
+			}
+		}
+		return nil
+	})
+	return entryID, err
+}
+
+func (s *memoryStore) LedgerViolation(ctx context.Context) (string, error) {
+	var violation string
+	err := s.read(func(d *memoryData) error {
+		entries := map[int64]map[string]int64{}
+		currencies := map[string]int64{}
+		balances := map[int64]int64{}
+		mismatch := ""
+		for _, p := range d.postings {
+			currencies[p.Currency] += p.Amount
+			if entries[p.EntryID] == nil {
+				entries[p.EntryID] = map[string]int64{}
+			}
+			entries[p.EntryID][p.Currency] += p.Amount
+			if p.AccID != nil {
+				balances[*p.AccID] += p.Amount
+				if mismatch == "" && d.accounts[*p.AccID].Currency != p.Currency {
+					mismatch = "posting currency differs from currency of account: " + strconv.FormatInt(*p.AccID, 10)
+				}
+			}
+		}
+		for currency, sum := range currencies {
+			if sum != 0 {
+				violation = "postings don't sum to zero in currency: " + currency
+				return nil
+			}
+		}
+		for id, sums := range entries {
+			for _, sum := range sums {
+				if sum != 0 {
+					violation = "unbalanced journal entry: " + strconv.FormatInt(id, 10)
+					return nil
+				}
+			}
+		}
+		for id, acc := range d.accounts {
+			if acc.Balance != balances[id] {
+				violation = "balance differs from postings of account: " + strconv.FormatInt(id, 10)
+				return nil
+			}
+		}
+		violation = mismatch
+		return nil
+	})
+	return violation, err
+}
+
+func (s *memoryStore) CreateTransaction(ctx context.Context, transaction *types.Transaction, entryID int64) error {
+	return s.write(func(d *memoryData) error {
+		if _, ok := d.accounts[transaction.AccID]; !ok {
+			return ErrNotFound
+		}
+		transaction.ID = d.next("transactions")
+		transaction.Created = time.Now()
+		d.transactions[transaction.ID] = *transaction
+		return nil
+	})
+}
+
+func (s *memoryStore) SetTransactionRef(ctx context.Context, id int64, refID int64) error {
+	return s.write(func(d *memoryData) error {
+		transaction, ok := d.transactions[id]
+		if !ok {
+			return ErrTransactionNotFound
+		}
+		transaction.RefID = &refID
+		d.transactions[id] = transaction
+		return nil
+	})
+}
+
+func (s *memoryStore) LockTransaction(ctx context.Context, id int64) (*types.Transaction, error) {
+	var transaction types.Transaction
+	err := s.read(func(d *memoryData) error {
+		var ok bool
+		transaction, ok = d.transactions[id]
+		if !ok {
+			return ErrTransactionNotFound
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &transaction, nil
+}
+
+func (s *memoryStore) ReversedAmount(ctx context.Context, id int64) (int64, error) {
+	var reversed int64
+	err := s.read(func(d *memoryData) error {
+		for _, t := range d.transactions {
+			if t.ReversalOf != nil && *t.ReversalOf == id {
+				reversed += t.Amount
+			}
+		}
+		return nil
+	})
+	return reversed, err
+}
+
+func (s *memoryStore) Transactions(ctx context.Context, accID int64, from time.Time, to time.Time) ([]*types.Transaction, error) {
+	var transactions []*types.Transaction
+	err := s.read(func(d *memoryData) error {
+		transactions = d.findTransactions(func(t *types.Transaction) bool {
+			return t.AccID == accID && !t.Created.Before(from) && t.Created.Before(to)
+		}, 0)
+		return nil
+	})
+	return transactions, err
+}
+
+// matchFilter returns condition of transactions of account matching filter like filterConditions
+func matchFilter(accID int64, filter *types.TransactionFilter) func(t *types.Transaction) bool {
+	return func(t *types.Transaction) bool {
+		switch {
+		case t.AccID != accID || t.Created.Before(filter.From) || !t.Created.Before(filter.To):
+			return false
+		case filter.Direction == DirectionCredit && t.Amount <= 0:
+			return false
+		case filter.Direction == DirectionDebit && t.Amount >= 0:
+			return false
+		case filter.MinAmount != nil && abs(t.Amount) < *filter.MinAmount:
+			return false
+		case filter.MaxAmount != nil && abs(t.Amount) > *filter.MaxAmount:
+			return false
+		}
+		return true
+	}
+}
+
+func (s *memoryStore) TransactionTotals(ctx context.Context, accID int64, filter *types.TransactionFilter) (int64, int64, error) {
+	var sum, count int64
+	err := s.read(func(d *memoryData) error {
+		match := matchFilter(accID, filter)
+		for _, t := range d.transactions {
+			if match(&t) {
+				sum += t.Amount
+				count++
+			}
+		}
+		return nil
+	})
+	return sum, count, err
+}
+
+func (s *memoryStore) FindTransactions(ctx context.Context, accID int64, filter *types.TransactionFilter, after *Cursor, limit int) ([]*types.Transaction, error) {
+	var transactions []*types.Transaction
+	err := s.read(func(d *memoryData) error {
+		match := matchFilter(accID, filter)
+		transactions = d.findTransactions(func(t *types.Transaction) bool {
+			if after != nil && (t.Created.Before(after.Created) || (t.Created.Equal(after.Created) && t.ID <= after.ID)) {
+				return false
+			}
+			return match(t)
+		}, limit)
+		return nil
+	})
+	return transactions, err
+}
+
+func (s *memoryStore) Turnover(ctx context.Context, accID int64, since time.Time, direction string) (int64, error) {
+	var turnover int64
+	err := s.read(func(d *memoryData) error {
+		for _, t := range d.transactions {
+			if t.AccID != accID || t.Created.Before(since) {
+				continue
+			}
+			if direction == DirectionAny || (direction == DirectionCredit && t.Amount > 0) || (direction == DirectionDebit && t.Amount < 0) {
+				turnover += abs(t.Amount)
+			}
+		}
+		return nil
+	})
+	return turnover, err
+}
+
+func (s *memoryStore) StatementHeader(ctx context.Context, statement *types.Statement) error {
+	return s.read(func(d *memoryData) error {
+		return d.statementHeader(statement)
+	})
+}
+
+func (s *memoryStore) LimitRules(ctx context.Context, tier string, currency string, direction string) ([]*types.LimitRule, error) {
+	rules := []*types.LimitRule{}
+	err := s.read(func(d *memoryData) error {
+		for _, rule := range d.limits {
+			rule := rule
+			if rule.Tier == tier && rule.Currency == currency && (rule.Direction == direction || rule.Direction == DirectionAny) {
+				rules = append(rules, &rule)
+			}
+		}
+		return nil
+	})
+	return rules, err
+}
+
+func (s *memoryStore) FeeRule(ctx context.Context, operation string, tier string, currency string, amount int64) (*types.FeeRule, error) {
+	var found *types.FeeRule
+	err := s.read(func(d *memoryData) error {
+		amount = abs(amount)
+		for _, rule := range d.fees {
+			rule := rule
+			if rule.Operation != operation || (rule.Tier != tier && rule.Tier != "") || rule.Currency != currency ||
+				rule.MinAmount > amount || (rule.MaxAmount != nil && *rule.MaxAmount <= amount) {
+				continue
+			}
+			if found == nil || (found.Tier == "" && rule.Tier != "") || (found.Tier == rule.Tier && rule.MinAmount > found.MinAmount) {
+				found = &rule
+			}
+		}
+		return nil
+	})
+	return found, err
+}
+
+func (s *memoryStore) CreateIdempotencyKey(ctx context.Context, accID int64, endpoint string, key string, requestHash string) (bool, error) {
+	created := false
+	err := s.write(func(d *memoryData) error {
+		k := idempotencyKey{accID: accID, endpoint: endpoint, key: key}
+		if _, ok := d.idempotencyKeys[k]; !ok {
+			d.idempotencyKeys[k] = idempotencyRecord{requestHash: requestHash}
+			created = true
+		}
+		return nil
+	})
+	return created, err
+}
+
+func (s *memoryStore) IdempotencyKey(ctx context.Context, accID int64, endpoint string, key string) (string, []byte, error) {
+	var record idempotencyRecord
+	err := s.read(func(d *memoryData) error {
+		var ok bool
+		record, ok = d.idempotencyKeys[idempotencyKey{accID: accID, endpoint: endpoint, key: key}]
+		if !ok {
+			return ErrNotFound
+		}
+		return nil
+	})
+	return record.requestHash, record.response, err
+}
+
+func (s *memoryStore) SetIdempotencyResponse(ctx context.Context, accID int64, endpoint string, key string, response []byte) error {
+	return s.write(func(d *memoryData) error {
+		k := idempotencyKey{accID: accID, endpoint: endpoint, key: key}
+		record := d.idempotencyKeys[k]
+		record.response = append([]byte(nil), response...)
+		d.idempotencyKeys[k] = record
+		return nil
+	})
+}
+
+func (s *memoryStore) CreateHold(ctx context.Context, hold *types.Hold, ttl time.Duration) error {
+	return s.write(func(d *memoryData) error {
+		if _, ok := d.accounts[hold.AccID]; !ok {
+			return ErrNotFound
+		}
+		hold.ID = d.next("holds")
+		hold.Status = HoldActive
+		hold.Created = time.Now()
+		hold.Expires = hold.Created.Add(ttl)
+		d.holds[hold.ID] = *hold
+		return nil
+	})
+}
+
+func (s *memoryStore) LockHold(ctx context.Context, partnerID string, id int64) (*types.Hold, bool, error) {
+	var hold types.Hold
+	err := s.read(func(d *memoryData) error {
+		var ok bool
+		hold, ok = d.holds[id]
+		if !ok || hold.PartnerID != partnerID {
+			return ErrHoldNotFound
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	return &hold, hold.Expires.After(time.Now()), nil
+}
+
+func (s *memoryStore) UpdateHold(ctx context.Context, id int64, status string, captured int64) error {
+	return s.write(func(d *memoryData) error {
+		hold, ok := d.holds[id]
+		if !ok {
+			return ErrHoldNotFound
+		}
+		hold.Status, hold.Captured = status, captured
+		d.holds[id] = hold
+		return nil
+	})
+}
+
+func (s *memoryStore) HeldAmount(ctx context.Context, accID int64, exceptHoldID *int64) (int64, error) {
+	var held int64
+	err := s.read(func(d *memoryData) error {
+		now := time.Now()
+		for _, hold := range d.holds {
+			if hold.AccID == accID && hold.Status == HoldActive && hold.Expires.After(now) && (exceptHoldID == nil || hold.ID != *exceptHoldID) {
+				held += hold.Amount
+			}
+		}
+		return nil
+	})
+	return held, err
+}
+
+func (s *memoryStore) ExpireHolds(ctx context.Context) (int64, error) {
+	var count int64
+	err := s.write(func(d *memoryData) error {
+		now := time.Now()
+		for id, hold := range d.holds {
+			if hold.Status == HoldActive && !hold.Expires.After(now) {
+				hold.Status = HoldExpired
+				d.holds[id] = hold
+				count++
+			}
+		}
+		return nil
+	})
+	return count, err
+}
+
+func (s *memoryStore) CreateIdentification(ctx context.Context, item *types.Identification) error {
+	return s.write(func(d *memoryData) error {
+		if _, ok := d.accounts[item.AccID]; !ok {
+			return ErrNotFound
+		}
+		item.ID = d.next("identifications")
+		item.Status = IdentificationPending
+		item.Created = time.Now()
+		item.Documents = append([]string{}, item.Documents...)
+		d.identifications[item.ID] = *item
+		return nil
+	})
+}
+
+func (s *memoryStore) HasPendingIdentification(ctx context.Context, accID int64) (bool, error) {
+	pending := false
+	err := s.read(func(d *memoryData) error {
+		for _, item := range d.identifications {
+			if item.AccID == accID && item.Status == IdentificationPending {
+				pending = true
+			}
+		}
+		return nil
+	})
+	return pending, err
+}
+
+func (s *memoryStore) LatestIdentification(ctx context.Context, accID int64) (*types.Identification, error) {
+	var latest *types.Identification
+	err := s.read(func(d *memoryData) error {
+		for _, item := range d.identifications {
+			item := item
+			if item.AccID == accID && (latest == nil || item.ID > latest.ID) {
+				latest = &item
+			}
+		}
+		return nil
+	})
+	return latest, err
+}
+
+func (s *memoryStore) LockIdentification(ctx context.Context, id int64) (*types.Identification, error) {
+	var item types.Identification
+	err := s.read(func(d *memoryData) error {
+		var ok bool
+		item, ok = d.identifications[id]
+		if !ok {
+			return ErrIdentificationNotFound
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+func (s *memoryStore) ReviewIdentification(ctx context.Context, id int64, status string, reason string, reviewer string) (*types.Identification, error) {
+	var item types.Identification
+	err := s.write(func(d *memoryData) error {
+		var ok bool
+		item, ok = d.identifications[id]
+		if !ok {
+			return ErrIdentificationNotFound
+		}
+		reviewed := time.Now()
+		item.Status, item.Reason, item.ReviewedBy, item.Reviewed = status, reason, reviewer, &reviewed
+		d.identifications[id] = item
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+func (s *memoryStore) CreateRate(ctx context.Context, rate *types.ExchangeRate) error {
+	return s.write(func(d *memoryData) error {
+		_, base := d.currencies[rate.Base]
+		_, quote := d.currencies[rate.Quote]
+		if !base || !quote {
+			return ErrUnknownCurrency
+		}
+		rate.ID = d.next("exchange_rates")
+		rate.Created = time.Now()
+		d.rates = append(d.rates, *rate)
+		return nil
+	})
+}
+
+func (s *memoryStore) HasRate(ctx context.Context, base string, quote string, validFrom time.Time) (bool, error) {
+	var exists bool
+	err := s.read(func(d *memoryData) error {
+		for _, rate := range d.rates {
+			if rate.Base == base && rate.Quote == quote && rate.ValidFrom.Equal(validFrom) {
+				exists = true
+			}
+		}
+		return nil
+	})
+	return exists, err
+}
+
+func (s *memoryStore) Rates(ctx context.Context) ([]*types.ExchangeRate, error) {
+	rates := []*types.ExchangeRate{}
+	err := s.read(func(d *memoryData) error {
+		now := time.Now()
+		newest := map[[2]string]*types.ExchangeRate{}
+		for _, rate := range d.rates {
+			rate := rate
+			pair := [2]string{rate.Base, rate.Quote}
+			if liveRate(&rate, now) && (newest[pair] == nil || newerRate(&rate, newest[pair])) {
+				newest[pair] = &rate
+			}
+		}
+		for _, rate := range newest {
+			rates = append(rates, rate)
+		}
+		return nil
+	})
+	sort.Slice(rates, func(i, j int) bool {
+		if rates[i].Base != rates[j].Base {
+			return rates[i].Base < rates[j].Base
+		}
+		return rates[i].Quote < rates[j].Quote
+	})
+	return rates, err
+}
+
+func (s *memoryStore) Rate(ctx context.Context, from string, to string) (*types.ExchangeRate, error) {
+	var found *types.ExchangeRate
+	err := s.read(func(d *memoryData) error {
+		now := time.Now()
+		for _, rate := range d.rates {
+			rate := rate
+			direct := rate.Base == from && rate.Quote == to
+			if !direct && !(rate.Base == to && rate.Quote == from) || !liveRate(&rate, now) {
+				continue
+			}
+			if found == nil || (direct && found.Base != from) || (direct == (found.Base == from) && newerRate(&rate, found)) {
+				found = &rate
+			}
+		}
+		if found == nil {
+			return ErrRateNotFound
+		}
+		return nil
+	})
+	return found, err
+}
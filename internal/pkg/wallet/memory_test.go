@@ -0,0 +1,134 @@
+package wallet
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/SYSTEMTerror/GoWallet/internal/pkg/types"
+)
+
+func TestMemoryRepository_CreateAccountUnique(t *testing.T) {
+	repo := NewMemoryRepository()
+	repo.AddCurrency(&types.Currency{Code: "USD", MinorUnits: 2})
+	repo.AddCurrency(&types.Currency{Code: "EUR", MinorUnits: 2})
+	ctx := context.Background()
+
+	owner := &types.Account{Currency: "USD", Phone: "992000000001", Tier: TierAnonymous}
+	if err := repo.CreateAccount(ctx, owner); err != nil {
+		t.Fatalf("CreateAccount error: %v", err)
+	}
+	if !owner.Active || owner.ID == 0 {
+		t.Errorf("CreateAccount = %+v, want active account with id", owner)
+	}
+
+	tests := []struct {
+		name string
+		acc  *types.Account
+		want error
+	}{
+		{"same phone", &types.Account{Currency: "EUR", Phone: owner.Phone}, ErrExist},
+		{"unknown currency", &types.Account{Currency: "XXX", Phone: "992000000002"}, ErrUnknownCurrency},
+		{"wallet in currency of main account", &types.Account{OwnerID: &owner.ID, Currency: "USD"}, ErrExist},
+		{"wallet in other currency", &types.Account{OwnerID: &owner.ID, Currency: "EUR"}, nil},
+		{"second wallet in currency", &types.Account{OwnerID: &owner.ID, Currency: "EUR"}, ErrExist},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := repo.CreateAccount(ctx, tt.acc)
+			if err != tt.want {
+				t.Errorf("CreateAccount error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	wallets, err := repo.Wallets(ctx, owner.ID)
+	if err != nil {
+		t.Fatalf("Wallets error: %v", err)
+	}
+	if len(wallets) != 2 || wallets[1].Phone != "" {
+		t.Errorf("Wallets = %+v, want main account and EUR wallet without phone", wallets)
+	}
+	wallet, err := repo.WalletByPhone(ctx, owner.Phone, "EUR")
+	if err != nil || wallet.ID != wallets[1].ID {
+		t.Errorf("WalletByPhone = %+v, %v, want EUR wallet", wallet, err)
+	}
+}
+
+func TestMemoryRepository_InTxRollback(t *testing.T) {
+	repo := NewMemoryRepository()
+	repo.AddCurrency(&types.Currency{Code: "USD", MinorUnits: 2})
+	ctx := context.Background()
+	acc := &types.Account{Currency: "USD", Phone: "992000000001"}
+	if err := repo.CreateAccount(ctx, acc); err != nil {
+		t.Fatalf("CreateAccount error: %v", err)
+	}
+
+	failed := errors.New("failed")
+	err := repo.InTx(ctx, func(tx Store) error {
+		_, err := tx.Post(ctx, EntryTopUp, []*types.Posting{
+			{AccID: &acc.ID, Currency: "USD", Amount: 100},
+			{System: SystemCashIn, Currency: "USD", Amount: -100},
+		})
+		if err != nil {
+			return err
+		}
+		return failed
+	})
+	if err != failed {
+		t.Fatalf("InTx error = %v, want %v", err, failed)
+	}
+
+	got, err := repo.AccountByID(ctx, acc.ID)
+	if err != nil {
+		t.Fatalf("AccountByID error: %v", err)
+	}
+	if got.Balance != 0 {
+		t.Errorf("balance after rollback = %d, want 0", got.Balance)
+	}
+	violation, err := repo.LedgerViolation(ctx)
+	if err != nil || violation != "" {
+		t.Errorf("LedgerViolation = %q, %v, want consistent ledger", violation, err)
+	}
+}
+
+func TestService_MemoryLimitsAndFees(t *testing.T) {
+	s, repo := newMemoryService(t)
+	repo.AddLimit(&types.LimitRule{Tier: TierAnonymous, Currency: "USD", Kind: LimitMaxOperation, Direction: DirectionAny, Amount: 500})
+	repo.AddFee(&types.FeeRule{Operation: EntryWithdrawal, Currency: "USD", Fixed: 10, Percent: "0"})
+	ctx := context.Background()
+
+	acc, _, err := s.Register(ctx, &types.RegInfo{Username: "memory", Phone: "992000000001", Password: "12345678", Currency: "USD"})
+	if err != nil {
+		t.Fatalf("Register error: %v", err)
+	}
+
+	_, code, err := s.Transaction(ctx, &types.Transaction{AccID: acc.ID, Amount: 1000})
+	if code != http.StatusBadRequest || !errors.Is(err, ErrOutOfLimit) {
+		t.Errorf("Transaction over limit = %d, %v, want 400 and ErrOutOfLimit", code, err)
+	}
+	_, _, err = s.Transaction(ctx, &types.Transaction{AccID: acc.ID, Amount: 500})
+	if err != nil {
+		t.Fatalf("Transaction top-up error: %v", err)
+	}
+	withdrawal, _, err := s.Transaction(ctx, &types.Transaction{AccID: acc.ID, Amount: -100})
+	if err != nil {
+		t.Fatalf("Transaction withdrawal error: %v", err)
+	}
+	if withdrawal.Fee != 10 {
+		t.Errorf("withdrawal fee = %d, want 10", withdrawal.Fee)
+	}
+
+	got, _, err := s.GetAccountByID(ctx, acc.ID)
+	if err != nil {
+		t.Fatalf("GetAccountByID error: %v", err)
+	}
+	if got.Balance != 390 {
+		t.Errorf("balance = %d, want 390", got.Balance)
+	}
+	_, err = s.CheckLedger(ctx)
+	if err != nil {
+		t.Errorf("CheckLedger error: %v", err)
+	}
+}
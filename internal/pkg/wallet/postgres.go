@@ -0,0 +1,626 @@
+package wallet
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/SYSTEMTerror/GoWallet/internal/pkg/types"
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// Postgres error codes of constraint violations
+const (
+	foreignKeyViolation = "23503"
+	uniqueViolation     = "23505"
+)
+
+// querier is common part of pgxpool.Pool and pgx.Tx
+type querier interface {
+	Exec(ctx context.Context, sql string, arguments ...interface{}) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row
+	SendBatch(ctx context.Context, b *pgx.Batch) pgx.BatchResults
+}
+
+// PostgresRepository stores wallets in Postgres database with schema of migrate package
+type PostgresRepository struct {
+	*postgresStore
+	pool *pgxpool.Pool
+}
+
+func NewPostgresRepository(pool *pgxpool.Pool) *PostgresRepository {
+	return &PostgresRepository{postgresStore: &postgresStore{db: pool}, pool: pool}
+}
+
+func (r *PostgresRepository) InTx(ctx context.Context, fn func(tx Store) error) error {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	err = fn(&postgresStore{db: tx})
+	if err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// Statement reads statement in one repeatable read transaction
+func (r *PostgresRepository) Statement(ctx context.Context, statement *types.Statement, begin func() error, line func(transaction *types.Transaction) error) error {
+	tx, err := r.pool.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.RepeatableRead, AccessMode: pgx.ReadOnly})
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	store := &postgresStore{db: tx}
+	err = store.StatementHeader(ctx, statement)
+	if err != nil {
+		return err
+	}
+
+	rows, err := tx.Query(ctx, `SELECT `+transactionColumns+` FROM transactions WHERE acc_id = $1 AND created >= $2::timestamptz AND created < $3::timestamptz ORDER BY created, id`, statement.AccID, statement.From, statement.To)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	err = begin()
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		transaction := &types.Transaction{}
+		err = scanTransaction(rows, transaction)
+		if err != nil {
+			return err
+		}
+		err = line(transaction)
+		if err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// postgresStore runs queries on pool or inside transaction
+type postgresStore struct {
+	db querier
+}
+
+// pgCode returns code of Postgres error
+func pgCode(err error) string {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return pgErr.Code
+	}
+	return ""
+}
+
+// accountColumns are columns of accounts table scanned by scanAccount
+const accountColumns = `id, owner_id, currency, balance, identified, tier, name, COALESCE(phone, ''), password, active, created`
+
+// scanAccount scans row of accountColumns to account
+func scanAccount(row pgx.Row, acc *types.Account) error {
+	return row.Scan(&acc.ID, &acc.OwnerID, &acc.Currency, &acc.Balance, &acc.Identified, &acc.Tier, &acc.Username, &acc.Phone, &acc.Password, &acc.Active, &acc.Created)
+}
+
+// transactionColumns are columns of transactions table scanned by scanTransaction
+const transactionColumns = `id, acc_id, currency, amount, ref_id, reversal_of, hold_id, COALESCE(rate, ''), fee, created`
+
+// scanTransaction scans row of transactionColumns to transaction
+func scanTransaction(row pgx.Row, transaction *types.Transaction) error {
+	return row.Scan(&transaction.ID, &transaction.AccID, &transaction.Currency, &transaction.Amount, &transaction.RefID, &transaction.ReversalOf, &transaction.HoldID, &transaction.Rate, &transaction.Fee, &transaction.Created)
+}
+
+// holdColumns are columns of holds table scanned by scanHold
+const holdColumns = `id, acc_id, partner_id, currency, amount, captured, status, expires, created`
+
+func scanHold(row pgx.Row, hold *types.Hold) error {
+	return row.Scan(&hold.ID, &hold.AccID, &hold.PartnerID, &hold.Currency, &hold.Amount, &hold.Captured, &hold.Status, &hold.Expires, &hold.Created)
+}
+
+// identificationColumns are columns of identifications table scanned by scanIdentification
+const identificationColumns = `id, acc_id, tier, status, full_name, birth_date, document_type, document_number, documents, reason, reviewed_by, created, reviewed`
+
+func scanIdentification(row pgx.Row, item *types.Identification) error {
+	return row.Scan(&item.ID, &item.AccID, &item.Tier, &item.Status, &item.FullName, &item.BirthDate, &item.DocumentType, &item.DocumentNumber, &item.Documents, &item.Reason, &item.ReviewedBy, &item.Created, &item.Reviewed)
+}
+
+// rateColumns are columns of exchange_rates table scanned by scanRate
+const rateColumns = `id, base, quote, rate::text, spread::text, valid_from, valid_to, created`
+
+// scanRate scans row of rateColumns to rate
+func scanRate(row pgx.Row, rate *types.ExchangeRate) error {
+	err := row.Scan(&rate.ID, &rate.Base, &rate.Quote, &rate.Rate, &rate.Spread, &rate.ValidFrom, &rate.ValidTo, &rate.Created)
+	if err != nil {
+		return err
+	}
+	rate.Rate, rate.Spread = trimDecimal(rate.Rate), trimDecimal(rate.Spread)
+	return nil
+}
+
+// feeColumns are columns of fees table scanned by FeeRule
+const feeColumns = `id, operation, COALESCE(tier, ''), currency, min_amount, max_amount, fixed, percent::text, min_fee, max_fee`
+
+func (s *postgresStore) Currencies(ctx context.Context) ([]*types.Currency, error) {
+	currencies := []*types.Currency{}
+	rows, err := s.db.Query(ctx, `SELECT code, name, minor_units FROM currencies ORDER BY code`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		currency := &types.Currency{}
+		err = rows.Scan(&currency.Code, &currency.Name, &currency.MinorUnits)
+		if err != nil {
+			return nil, err
+		}
+		currencies = append(currencies, currency)
+	}
+	return currencies, rows.Err()
+}
+
+func (s *postgresStore) Currency(ctx context.Context, code string) (*types.Currency, error) {
+	currency := &types.Currency{}
+	err := s.db.QueryRow(ctx, `SELECT code, name, minor_units FROM currencies WHERE code = $1`, code).Scan(&currency.Code, &currency.Name, &currency.MinorUnits)
+	if err == pgx.ErrNoRows {
+		return nil, ErrUnknownCurrency
+	}
+	if err != nil {
+		return nil, err
+	}
+	return currency, nil
+}
+
+// CreateAccount inserts wallet of owner without phone, so phone is unique only among main accounts
+func (s *postgresStore) CreateAccount(ctx context.Context, acc *types.Account) error {
+	var phone *string
+	if acc.OwnerID == nil {
+		phone = &acc.Phone
+	}
+	err := s.db.QueryRow(ctx, `
+		INSERT INTO accounts (owner_id, currency, identified, tier, name, phone, password)
+		VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id, balance, active, created
+	`, acc.OwnerID, acc.Currency, acc.Identified, acc.Tier, acc.Username, phone, acc.Password).Scan(&acc.ID, &acc.Balance, &acc.Active, &acc.Created)
+	switch pgCode(err) {
+	case foreignKeyViolation:
+		return ErrUnknownCurrency
+	case uniqueViolation:
+		return ErrExist
+	}
+	return err
+}
+
+func (s *postgresStore) account(ctx context.Context, query string, args ...interface{}) (*types.Account, error) {
+	acc := &types.Account{}
+	err := scanAccount(s.db.QueryRow(ctx, query, args...), acc)
+	if err == pgx.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return acc, nil
+}
+
+func (s *postgresStore) accounts(ctx context.Context, query string, args ...interface{}) ([]*types.Account, error) {
+	accounts := []*types.Account{}
+	rows, err := s.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		acc := &types.Account{}
+		err = scanAccount(rows, acc)
+		if err != nil {
+			return nil, err
+		}
+		accounts = append(accounts, acc)
+	}
+	return accounts, rows.Err()
+}
+
+func (s *postgresStore) AccountByID(ctx context.Context, id int64) (*types.Account, error) {
+	return s.account(ctx, `SELECT `+accountColumns+` FROM accounts WHERE id = $1`, id)
+}
+
+func (s *postgresStore) AccountByPhone(ctx context.Context, phone string) (*types.Account, error) {
+	return s.account(ctx, `SELECT `+accountColumns+` FROM accounts WHERE phone = $1`, phone)
+}
+
+func (s *postgresStore) WalletByPhone(ctx context.Context, phone string, currency string) (*types.Account, error) {
+	return s.account(ctx, `SELECT `+accountColumns+` FROM accounts WHERE currency = $2 AND COALESCE(owner_id, id) = (SELECT id FROM accounts WHERE phone = $1)`, phone, currency)
+}
+
+func (s *postgresStore) Wallets(ctx context.Context, ownerID int64) ([]*types.Account, error) {
+	return s.accounts(ctx, `SELECT `+accountColumns+` FROM accounts WHERE id = $1 OR owner_id = $1 ORDER BY id`, ownerID)
+}
+
+func (s *postgresStore) LockAccount(ctx context.Context, id int64) (*types.Account, error) {
+	return s.account(ctx, `SELECT `+accountColumns+` FROM accounts WHERE id = $1 FOR UPDATE`, id)
+}
+
+func (s *postgresStore) LockAccounts(ctx context.Context, ids ...int64) ([]*types.Account, error) {
+	return s.accounts(ctx, `SELECT `+accountColumns+` FROM accounts WHERE id = ANY($1) ORDER BY id FOR UPDATE`, ids)
+}
+
+func (s *postgresStore) SetTier(ctx context.Context, ownerID int64, tier string, identified bool) error {
+	_, err := s.db.Exec(ctx, `UPDATE accounts SET tier = $1, identified = $2 WHERE id = $3 OR owner_id = $3`, tier, identified, ownerID)
+	return err
+}
+
+func (s *postgresStore) Post(ctx context.Context, kind string, postings []*types.Posting) (int64, error) {
+	var entryID int64
+	err := s.db.QueryRow(ctx, `INSERT INTO journal_entries (kind) VALUES ($1) RETURNING id`, kind).Scan(&entryID)
+	if err != nil {
+		return 0, err
+	}
+
+	batch := &pgx.Batch{}
+	for _, p := range postings {
+		p.EntryID = entryID
+		if p.AccID != nil {
+			batch.Queue(`INSERT INTO postings (entry_id, acc_id, currency, amount) VALUES ($1, $2, $3, $4)`, entryID, p.AccID, p.Currency, p.Amount)
+			batch.Queue(`UPDATE accounts SET balance = balance + $1 WHERE id = $2`, p.Amount, p.AccID)
+		} else {
+			batch.Queue(`INSERT INTO postings (entry_id, system, currency, amount) VALUES ($1, $2, $3, $4)`, entryID, p.System, p.Currency, p.Amount)
+		}
+	}
+	err = s.db.SendBatch(ctx, batch).Close()
+	if err != nil {
+		return 0, err
+	}
+
+	return entryID, nil
+}
+
+func (s *postgresStore) LedgerViolation(ctx context.Context) (string, error) {
+	checks := []struct {
+		description string
+		query       string
+	}{
+		{"postings don't sum to zero in currency", `SELECT currency FROM postings GROUP BY currency HAVING SUM(amount) <> 0 LIMIT 1`},
+		{"unbalanced journal entry", `SELECT entry_id::text FROM postings GROUP BY entry_id, currency HAVING SUM(amount) <> 0 LIMIT 1`},
+		{"balance differs from postings of account", `SELECT a.id::text FROM accounts a LEFT JOIN (SELECT acc_id, SUM(amount) AS sum FROM postings WHERE acc_id IS NOT NULL GROUP BY acc_id) p ON p.acc_id = a.id WHERE a.balance <> COALESCE(p.sum, 0) LIMIT 1`},
+		{"posting currency differs from currency of account", `SELECT a.id::text FROM postings p JOIN accounts a ON a.id = p.acc_id WHERE p.currency <> a.currency LIMIT 1`},
+	}
+	for _, check := range checks {
+		var value string
+		err := s.db.QueryRow(ctx, check.query).Scan(&value)
+		if err == nil {
+			return check.description + ": " + value, nil
+		}
+		if err != pgx.ErrNoRows {
+			return "", err
+		}
+	}
+	return "", nil
+}
+
+func (s *postgresStore) CreateTransaction(ctx context.Context, transaction *types.Transaction, entryID int64) error {
+	var rate *string
+	if transaction.Rate != "" {
+		rate = &transaction.Rate
+	}
+	return s.db.QueryRow(ctx, `
+		INSERT INTO transactions (acc_id, currency, amount, ref_id, reversal_of, hold_id, rate, fee, entry_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING id, created
+	`, transaction.AccID, transaction.Currency, transaction.Amount, transaction.RefID, transaction.ReversalOf, transaction.HoldID, rate, transaction.Fee, entryID).Scan(&transaction.ID, &transaction.Created)
+}
+
+func (s *postgresStore) SetTransactionRef(ctx context.Context, id int64, refID int64) error {
+	_, err := s.db.Exec(ctx, `UPDATE transactions SET ref_id = $1 WHERE id = $2`, refID, id)
+	return err
+}
+
+func (s *postgresStore) LockTransaction(ctx context.Context, id int64) (*types.Transaction, error) {
+	transaction := &types.Transaction{}
+	err := scanTransaction(s.db.QueryRow(ctx, `SELECT `+transactionColumns+` FROM transactions WHERE id = $1 FOR UPDATE`, id), transaction)
+	if err == pgx.ErrNoRows {
+		return nil, ErrTransactionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return transaction, nil
+}
+
+func (s *postgresStore) ReversedAmount(ctx context.Context, id int64) (int64, error) {
+	var reversed int64
+	err := s.db.QueryRow(ctx, `SELECT COALESCE(SUM(amount), 0) FROM transactions WHERE reversal_of = $1`, id).Scan(&reversed)
+	return reversed, err
+}
+
+func (s *postgresStore) transactions(ctx context.Context, query string, args ...interface{}) ([]*types.Transaction, error) {
+	transactions := []*types.Transaction{}
+	rows, err := s.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		transaction := &types.Transaction{}
+		err = scanTransaction(rows, transaction)
+		if err != nil {
+			return nil, err
+		}
+		transactions = append(transactions, transaction)
+	}
+	return transactions, rows.Err()
+}
+
+func (s *postgresStore) Transactions(ctx context.Context, accID int64, from time.Time, to time.Time) ([]*types.Transaction, error) {
+	return s.transactions(ctx, `SELECT `+transactionColumns+` FROM transactions WHERE acc_id = $1 AND created >= $2::timestamptz AND created < $3::timestamptz ORDER BY created, id`, accID, from, to)
+}
+
+// filterConditions returns conditions of transactions of account matching filter and their arguments
+func filterConditions(accID int64, filter *types.TransactionFilter) ([]string, []interface{}) {
+	conditions := []string{"acc_id = $1", "created >= $2::timestamptz", "created < $3::timestamptz"}
+	args := []interface{}{accID, filter.From, filter.To}
+	switch filter.Direction {
+	case DirectionCredit:
+		conditions = append(conditions, "amount > 0")
+	case DirectionDebit:
+		conditions = append(conditions, "amount < 0")
+	}
+	if filter.MinAmount != nil {
+		args = append(args, *filter.MinAmount)
+		conditions = append(conditions, fmt.Sprintf("abs(amount) >= $%d", len(args)))
+	}
+	if filter.MaxAmount != nil {
+		args = append(args, *filter.MaxAmount)
+		conditions = append(conditions, fmt.Sprintf("abs(amount) <= $%d", len(args)))
+	}
+	return conditions, args
+}
+
+func (s *postgresStore) TransactionTotals(ctx context.Context, accID int64, filter *types.TransactionFilter) (int64, int64, error) {
+	conditions, args := filterConditions(accID, filter)
+	var sum, count int64
+	err := s.db.QueryRow(ctx, `SELECT COALESCE(SUM(amount), 0), COUNT(*) FROM transactions WHERE `+strings.Join(conditions, " AND "), args...).Scan(&sum, &count)
+	return sum, count, err
+}
+
+func (s *postgresStore) FindTransactions(ctx context.Context, accID int64, filter *types.TransactionFilter, after *Cursor, limit int) ([]*types.Transaction, error) {
+	conditions, args := filterConditions(accID, filter)
+	if after != nil {
+		args = append(args, after.Created, after.ID)
+		conditions = append(conditions, fmt.Sprintf("(created, id) > ($%d::timestamp, $%d)", len(args)-1, len(args)))
+	}
+	args = append(args, limit)
+	return s.transactions(ctx, `SELECT `+transactionColumns+` FROM transactions WHERE `+strings.Join(conditions, " AND ")+fmt.Sprintf(" ORDER BY created, id LIMIT $%d", len(args)), args...)
+}
+
+func (s *postgresStore) Turnover(ctx context.Context, accID int64, since time.Time, direction string) (int64, error) {
+	var turnover int64
+	err := s.db.QueryRow(ctx, `
+		SELECT COALESCE(SUM(abs(amount)), 0) FROM transactions
+		WHERE acc_id = $1 AND created >= $2::timestamptz
+		AND ($3 = 'any' OR ($3 = 'credit' AND amount > 0) OR ($3 = 'debit' AND amount < 0))
+	`, accID, since, direction).Scan(&turnover)
+	return turnover, err
+}
+
+// StatementHeader selects currency of account and its balance before start of period: current balance
+// minus balance effect of later transactions
+func (s *postgresStore) StatementHeader(ctx context.Context, statement *types.Statement) error {
+	err := s.db.QueryRow(ctx, `
+		SELECT a.currency, c.minor_units, a.balance - COALESCE((SELECT SUM(amount - fee) FROM transactions WHERE acc_id = a.id AND created >= $2::timestamptz), 0)
+		FROM accounts a JOIN currencies c ON c.code = a.currency WHERE a.id = $1
+	`, statement.AccID, statement.From).Scan(&statement.Currency, &statement.MinorUnits, &statement.Opening)
+	if err == pgx.ErrNoRows {
+		return ErrNotFound
+	}
+	return err
+}
+
+func (s *postgresStore) LimitRules(ctx context.Context, tier string, currency string, direction string) ([]*types.LimitRule, error) {
+	rules := []*types.LimitRule{}
+	rows, err := s.db.Query(ctx, `SELECT id, tier, currency, kind, direction, amount FROM limits WHERE tier = $1 AND currency = $2 AND active AND direction IN ($3, $4) ORDER BY id`, tier, currency, direction, DirectionAny)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		rule := &types.LimitRule{}
+		err = rows.Scan(&rule.ID, &rule.Tier, &rule.Currency, &rule.Kind, &rule.Direction, &rule.Amount)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, rows.Err()
+}
+
+func (s *postgresStore) FeeRule(ctx context.Context, operation string, tier string, currency string, amount int64) (*types.FeeRule, error) {
+	rule := &types.FeeRule{}
+	err := s.db.QueryRow(ctx, `
+		SELECT `+feeColumns+` FROM fees
+		WHERE active AND operation = $1 AND (tier = $2 OR tier IS NULL) AND currency = $3
+		AND min_amount <= $4 AND (max_amount IS NULL OR max_amount > $4)
+		ORDER BY tier IS NULL, min_amount DESC, id LIMIT 1
+	`, operation, tier, currency, abs(amount)).Scan(&rule.ID, &rule.Operation, &rule.Tier, &rule.Currency, &rule.MinAmount, &rule.MaxAmount, &rule.Fixed, &rule.Percent, &rule.MinFee, &rule.MaxFee)
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return rule, nil
+}
+
+func (s *postgresStore) CreateIdempotencyKey(ctx context.Context, accID int64, endpoint string, key string, requestHash string) (bool, error) {
+	tag, err := s.db.Exec(ctx, `INSERT INTO idempotency_keys (acc_id, endpoint, key, request_hash) VALUES ($1, $2, $3, $4) ON CONFLICT DO NOTHING`, accID, endpoint, key, requestHash)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+func (s *postgresStore) IdempotencyKey(ctx context.Context, accID int64, endpoint string, key string) (string, []byte, error) {
+	var requestHash string
+	var response []byte
+	err := s.db.QueryRow(ctx, `SELECT request_hash, response FROM idempotency_keys WHERE acc_id = $1 AND endpoint = $2 AND key = $3`, accID, endpoint, key).Scan(&requestHash, &response)
+	return requestHash, response, err
+}
+
+func (s *postgresStore) SetIdempotencyResponse(ctx context.Context, accID int64, endpoint string, key string, response []byte) error {
+	_, err := s.db.Exec(ctx, `UPDATE idempotency_keys SET response = $1 WHERE acc_id = $2 AND endpoint = $3 AND key = $4`, response, accID, endpoint, key)
+	return err
+}
+
+func (s *postgresStore) CreateHold(ctx context.Context, hold *types.Hold, ttl time.Duration) error {
+	return scanHold(s.db.QueryRow(ctx, `INSERT INTO holds (acc_id, partner_id, currency, amount, expires) VALUES ($1, $2, $3, $4, CURRENT_TIMESTAMP + $5::interval) RETURNING `+holdColumns, hold.AccID, hold.PartnerID, hold.Currency, hold.Amount, ttl), hold)
+}
+
+func (s *postgresStore) LockHold(ctx context.Context, partnerID string, id int64) (*types.Hold, bool, error) {
+	hold := &types.Hold{}
+	var live bool
+	err := s.db.QueryRow(ctx, `SELECT `+holdColumns+`, expires > CURRENT_TIMESTAMP FROM holds WHERE id = $1 AND partner_id = $2 FOR UPDATE`, id, partnerID).Scan(&hold.ID, &hold.AccID, &hold.PartnerID, &hold.Currency, &hold.Amount, &hold.Captured, &hold.Status, &hold.Expires, &hold.Created, &live)
+	if err == pgx.ErrNoRows {
+		return nil, false, ErrHoldNotFound
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return hold, live, nil
+}
+
+func (s *postgresStore) UpdateHold(ctx context.Context, id int64, status string, captured int64) error {
+	_, err := s.db.Exec(ctx, `UPDATE holds SET status = $1, captured = $2 WHERE id = $3`, status, captured, id)
+	return err
+}
+
+func (s *postgresStore) HeldAmount(ctx context.Context, accID int64, exceptHoldID *int64) (int64, error) {
+	var held int64
+	err := s.db.QueryRow(ctx, `SELECT COALESCE(SUM(amount), 0) FROM holds WHERE acc_id = $1 AND status = $2 AND expires > CURRENT_TIMESTAMP AND ($3::bigint IS NULL OR id <> $3)`, accID, HoldActive, exceptHoldID).Scan(&held)
+	return held, err
+}
+
+func (s *postgresStore) ExpireHolds(ctx context.Context) (int64, error) {
+	tag, err := s.db.Exec(ctx, `UPDATE holds SET status = $1 WHERE status = $2 AND expires <= CURRENT_TIMESTAMP`, HoldExpired, HoldActive)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+func (s *postgresStore) CreateIdentification(ctx context.Context, item *types.Identification) error {
+	return scanIdentification(s.db.QueryRow(ctx, `
+		INSERT INTO identifications (acc_id, tier, full_name, birth_date, document_type, document_number, documents)
+		VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING `+identificationColumns,
+		item.AccID, item.Tier, item.FullName, item.BirthDate, item.DocumentType, item.DocumentNumber, item.Documents), item)
+}
+
+func (s *postgresStore) HasPendingIdentification(ctx context.Context, accID int64) (bool, error) {
+	var pending bool
+	err := s.db.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM identifications WHERE acc_id = $1 AND status = $2)`, accID, IdentificationPending).Scan(&pending)
+	return pending, err
+}
+
+func (s *postgresStore) LatestIdentification(ctx context.Context, accID int64) (*types.Identification, error) {
+	item := &types.Identification{}
+	err := scanIdentification(s.db.QueryRow(ctx, `SELECT `+identificationColumns+` FROM identifications WHERE acc_id = $1 ORDER BY id DESC LIMIT 1`, accID), item)
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+func (s *postgresStore) LockIdentification(ctx context.Context, id int64) (*types.Identification, error) {
+	item := &types.Identification{}
+	err := scanIdentification(s.db.QueryRow(ctx, `SELECT `+identificationColumns+` FROM identifications WHERE id = $1 FOR UPDATE`, id), item)
+	if err == pgx.ErrNoRows {
+		return nil, ErrIdentificationNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+func (s *postgresStore) ReviewIdentification(ctx context.Context, id int64, status string, reason string, reviewer string) (*types.Identification, error) {
+	item := &types.Identification{}
+	err := scanIdentification(s.db.QueryRow(ctx, `
+		UPDATE identifications SET status = $2, reason = $3, reviewed_by = $4, reviewed = CURRENT_TIMESTAMP
+		WHERE id = $1 RETURNING `+identificationColumns, id, status, reason, reviewer), item)
+	if err == pgx.ErrNoRows {
+		return nil, ErrIdentificationNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+func (s *postgresStore) CreateRate(ctx context.Context, rate *types.ExchangeRate) error {
+	err := scanRate(s.db.QueryRow(ctx, `
+		INSERT INTO exchange_rates (base, quote, rate, spread, valid_from, valid_to)
+		VALUES ($1, $2, $3::numeric, $4::numeric, $5::timestamptz, $6::timestamptz) RETURNING `+rateColumns,
+		rate.Base, rate.Quote, rate.Rate, rate.Spread, rate.ValidFrom, rate.ValidTo), rate)
+	if pgCode(err) == foreignKeyViolation {
+		return ErrUnknownCurrency
+	}
+	return err
+}
+
+func (s *postgresStore) HasRate(ctx context.Context, base string, quote string, validFrom time.Time) (bool, error) {
+	var exists bool
+	err := s.db.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM exchange_rates WHERE base = $1 AND quote = $2 AND valid_from = $3)`, base, quote, validFrom).Scan(&exists)
+	return exists, err
+}
+
+func (s *postgresStore) Rates(ctx context.Context) ([]*types.ExchangeRate, error) {
+	rates := []*types.ExchangeRate{}
+	rows, err := s.db.Query(ctx, `
+		SELECT DISTINCT ON (base, quote) `+rateColumns+` FROM exchange_rates
+		WHERE valid_from <= CURRENT_TIMESTAMP AND (valid_to IS NULL OR valid_to > CURRENT_TIMESTAMP)
+		ORDER BY base, quote, valid_from DESC, id DESC
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		rate := &types.ExchangeRate{}
+		err = scanRate(rows, rate)
+		if err != nil {
+			return nil, err
+		}
+		rates = append(rates, rate)
+	}
+	return rates, rows.Err()
+}
+
+func (s *postgresStore) Rate(ctx context.Context, from string, to string) (*types.ExchangeRate, error) {
+	rate := &types.ExchangeRate{}
+	err := scanRate(s.db.QueryRow(ctx, `
+		SELECT `+rateColumns+` FROM exchange_rates
+		WHERE ((base = $1 AND quote = $2) OR (base = $2 AND quote = $1))
+		AND valid_from <= CURRENT_TIMESTAMP AND (valid_to IS NULL OR valid_to > CURRENT_TIMESTAMP)
+		ORDER BY base = $1 DESC, valid_from DESC, id DESC LIMIT 1
+	`, from, to), rate)
+	if err == pgx.ErrNoRows {
+		return nil, ErrRateNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return rate, nil
+}
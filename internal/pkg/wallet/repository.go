@@ -0,0 +1,112 @@
+package wallet
+
+import (
+	"context"
+	"time"
+
+	"github.com/SYSTEMTerror/GoWallet/internal/pkg/types"
+)
+
+// Cursor is position after last transaction of page in (created, id) order
+type Cursor struct {
+	Created time.Time `json:"created"`
+	ID      int64     `json:"id"`
+}
+
+// Store reads and changes data of wallets. Lock methods lock returned rows until the end of transaction of InTx,
+// outside of it every method is atomic on its own. Missing rows are reported with errors of Service
+// (ErrNotFound, ErrTransactionNotFound, ErrHoldNotFound, ErrIdentificationNotFound, ErrRateNotFound),
+// other errors are internal
+type Store interface {
+	// Currencies returns currencies ordered by code
+	Currencies(ctx context.Context) ([]*types.Currency, error)
+	// Currency returns currency with code or ErrUnknownCurrency
+	Currency(ctx context.Context, code string) (*types.Currency, error)
+
+	// CreateAccount inserts account and fills its ID, Balance, Active and Created. Phone of main account and
+	// currency of wallets of one owner are unique (ErrExist), currency must be known (ErrUnknownCurrency)
+	CreateAccount(ctx context.Context, acc *types.Account) error
+	AccountByID(ctx context.Context, id int64) (*types.Account, error)
+	AccountByPhone(ctx context.Context, phone string) (*types.Account, error)
+	// WalletByPhone returns wallet in currency of user with phone
+	WalletByPhone(ctx context.Context, phone string, currency string) (*types.Account, error)
+	// Wallets returns main account of owner and its wallets in other currencies ordered by id
+	Wallets(ctx context.Context, ownerID int64) ([]*types.Account, error)
+	LockAccount(ctx context.Context, id int64) (*types.Account, error)
+	// LockAccounts locks existing accounts of ids in id order, so concurrent transactions can't deadlock
+	LockAccounts(ctx context.Context, ids ...int64) ([]*types.Account, error)
+	// SetTier sets tier of main account of owner and of its wallets
+	SetTier(ctx context.Context, ownerID int64, tier string, identified bool) error
+
+	// Post records journal entry of kind with postings, applies wallet postings to balances and returns id of entry
+	Post(ctx context.Context, kind string, postings []*types.Posting) (int64, error)
+	// LedgerViolation describes the first found inconsistency of ledger, empty if ledger is consistent
+	LedgerViolation(ctx context.Context) (string, error)
+
+	// CreateTransaction inserts transaction of journal entry and fills its ID and Created
+	CreateTransaction(ctx context.Context, transaction *types.Transaction, entryID int64) error
+	SetTransactionRef(ctx context.Context, id int64, refID int64) error
+	LockTransaction(ctx context.Context, id int64) (*types.Transaction, error)
+	// ReversedAmount returns sum of reversals of transaction
+	ReversedAmount(ctx context.Context, id int64) (int64, error)
+	// Transactions returns transactions of account created in [from, to) ordered by created and id
+	Transactions(ctx context.Context, accID int64, from time.Time, to time.Time) ([]*types.Transaction, error)
+	// TransactionTotals returns sum and count of transactions of account matching filter, its cursor and limit are ignored
+	TransactionTotals(ctx context.Context, accID int64, filter *types.TransactionFilter) (int64, int64, error)
+	// FindTransactions returns up to limit transactions of account matching filter after cursor ordered by created and id
+	FindTransactions(ctx context.Context, accID int64, filter *types.TransactionFilter, after *Cursor, limit int) ([]*types.Transaction, error)
+	// Turnover returns sum of absolute amounts of transactions of account in direction created since
+	Turnover(ctx context.Context, accID int64, since time.Time, direction string) (int64, error)
+	// StatementHeader fills currency, minor units and opening balance of statement of account for period
+	StatementHeader(ctx context.Context, statement *types.Statement) error
+
+	// LimitRules returns active limit rules of tier and currency for direction or any direction ordered by id
+	LimitRules(ctx context.Context, tier string, currency string, direction string) ([]*types.LimitRule, error)
+	// FeeRule returns active fee rule of operation matching tier, currency and absolute amount, nil if operation is free.
+	// Rule of tier takes precedence over rule of all tiers, rule of higher amount band over lower one
+	FeeRule(ctx context.Context, operation string, tier string, currency string, amount int64) (*types.FeeRule, error)
+
+	// CreateIdempotencyKey stores key with hash of request, false if key already exists
+	CreateIdempotencyKey(ctx context.Context, accID int64, endpoint string, key string, requestHash string) (bool, error)
+	// IdempotencyKey returns hash of request and stored response of key
+	IdempotencyKey(ctx context.Context, accID int64, endpoint string, key string) (string, []byte, error)
+	SetIdempotencyResponse(ctx context.Context, accID int64, endpoint string, key string, response []byte) error
+
+	// CreateHold inserts active hold expiring after ttl and fills its ID, Status, Expires and Created
+	CreateHold(ctx context.Context, hold *types.Hold, ttl time.Duration) error
+	// LockHold locks hold of partner and reports if it is not expired yet
+	LockHold(ctx context.Context, partnerID string, id int64) (*types.Hold, bool, error)
+	UpdateHold(ctx context.Context, id int64, status string, captured int64) error
+	// HeldAmount returns sum of active not expired holds of account except given hold
+	HeldAmount(ctx context.Context, accID int64, exceptHoldID *int64) (int64, error)
+	// ExpireHolds marks active holds with passed expiration time as expired and returns their count
+	ExpireHolds(ctx context.Context) (int64, error)
+
+	// CreateIdentification inserts pending identification request and fills its ID, Status and Created
+	CreateIdentification(ctx context.Context, item *types.Identification) error
+	HasPendingIdentification(ctx context.Context, accID int64) (bool, error)
+	// LatestIdentification returns the latest identification request of account, nil if there is none
+	LatestIdentification(ctx context.Context, accID int64) (*types.Identification, error)
+	LockIdentification(ctx context.Context, id int64) (*types.Identification, error)
+	// ReviewIdentification sets status, reason and reviewer of identification request and returns it
+	ReviewIdentification(ctx context.Context, id int64, status string, reason string, reviewer string) (*types.Identification, error)
+
+	// CreateRate inserts exchange rate and fills its ID and Created, currencies must be known (ErrUnknownCurrency)
+	CreateRate(ctx context.Context, rate *types.ExchangeRate) error
+	// HasRate reports if rate of pair base/quote valid from validFrom is stored
+	HasRate(ctx context.Context, base string, quote string, validFrom time.Time) (bool, error)
+	// Rates returns exchange rates valid now, the newest one of every pair ordered by base and quote
+	Rates(ctx context.Context) ([]*types.ExchangeRate, error)
+	// Rate returns the newest rate valid now of pair from/to or, if there is none, of pair to/from
+	Rate(ctx context.Context, from string, to string) (*types.ExchangeRate, error)
+}
+
+// Repository is storage of Service
+type Repository interface {
+	Store
+	// InTx runs fn in transaction, which is committed if fn returns nil and rolled back otherwise
+	InTx(ctx context.Context, fn func(tx Store) error) error
+	// Statement fills header of statement like StatementHeader, calls begin and then line for every transaction
+	// of the period in order. Header and transactions are read from one consistent snapshot
+	Statement(ctx context.Context, statement *types.Statement, begin func() error, line func(transaction *types.Transaction) error) error
+}
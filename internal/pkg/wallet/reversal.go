@@ -0,0 +1,82 @@
+package wallet
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/SYSTEMTerror/GoWallet/internal/pkg/types"
+)
+
+var (
+	ErrTransactionNotFound = errors.New("transaction not found")
+	ErrNotReversible       = errors.New("transaction can't be reversed")
+	ErrAlreadyReversed     = errors.New("transaction already reversed")
+)
+
+// Reverse refunds amount of transaction by compensating transaction which references it.
+// Zero amount refunds the rest of transaction, refunds in sum can't exceed its amount.
+// Transfers and reversals themselves can't be reversed
+func (s *Service) Reverse(ctx context.Context, id int64, item *types.Reversal) (*types.Transaction, int, error) {
+	if item.Amount < 0 {
+		log.Println("Reverse amount error:", ErrInvalidAmount)
+		return nil, http.StatusBadRequest, ErrInvalidAmount
+	}
+
+	reversal := &types.Transaction{ReversalOf: &id}
+	statusCode, err := s.inTx(ctx, "Reverse", func(tx Store) (int, error) {
+		original, err := tx.LockTransaction(ctx, id)
+		if err == ErrTransactionNotFound {
+			log.Println("Reverse tx.LockTransaction not found:", err)
+			return http.StatusNotFound, ErrTransactionNotFound
+		}
+		if err != nil {
+			log.Println("Reverse tx.LockTransaction error:", err)
+			return http.StatusInternalServerError, ErrInternal
+		}
+		if original.RefID != nil || original.ReversalOf != nil || original.Amount == 0 {
+			log.Println("Reverse original transaction error:", ErrNotReversible)
+			return http.StatusBadRequest, ErrNotReversible
+		}
+
+		reversed, err := tx.ReversedAmount(ctx, id)
+		if err != nil {
+			log.Println("Reverse tx.ReversedAmount error:", err)
+			return http.StatusInternalServerError, ErrInternal
+		}
+
+		remaining := abs(original.Amount) - abs(reversed)
+		if remaining <= 0 {
+			log.Println("Reverse remaining amount error:", ErrAlreadyReversed)
+			return http.StatusConflict, ErrAlreadyReversed
+		}
+		amount := item.Amount
+		if amount == 0 {
+			amount = remaining
+		}
+		if amount > remaining {
+			log.Println("Reverse amount exceeds remaining:", ErrInvalidAmount)
+			return http.StatusBadRequest, ErrInvalidAmount
+		}
+
+		reversal.AccID = original.AccID
+		reversal.Currency = original.Currency
+		reversal.Amount = amount
+		if original.Amount > 0 {
+			reversal.Amount = -amount
+		}
+		return s.transaction(ctx, tx, reversal)
+	})
+	if err != nil {
+		return nil, statusCode, err
+	}
+	return reversal, statusCode, nil
+}
+
+func abs(v int64) int64 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
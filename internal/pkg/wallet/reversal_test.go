@@ -0,0 +1,119 @@
+package wallet
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/SYSTEMTerror/GoWallet/internal/pkg/types"
+)
+
+func TestReverse_Partial(t *testing.T) {
+	s, _ := newMemoryService(t)
+	acc := newMemoryAccount(t, s, "992000000001", 1000)
+	ctx := context.Background()
+
+	withdrawal, _, err := s.Transaction(ctx, &types.Transaction{AccID: acc.ID, Amount: -300})
+	if err != nil {
+		t.Fatalf("Transaction error: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		amount  int64
+		code    int
+		err     error
+		want    int64
+		balance int64
+	}{
+		{"partial refund", 100, http.StatusOK, nil, 100, 800},
+		{"over remaining", 201, http.StatusBadRequest, ErrInvalidAmount, 0, 800},
+		{"negative", -1, http.StatusBadRequest, ErrInvalidAmount, 0, 800},
+		{"rest of transaction", 0, http.StatusOK, nil, 200, 1000},
+		{"fully reversed", 0, http.StatusConflict, ErrAlreadyReversed, 0, 1000},
+		{"fully reversed with amount", 1, http.StatusConflict, ErrAlreadyReversed, 0, 1000},
+	}
+	for _, tt := range tests {
+		reversal, code, err := s.Reverse(ctx, withdrawal.ID, &types.Reversal{Amount: tt.amount})
+		if code != tt.code || err != tt.err {
+			t.Fatalf("%s: Reverse = %d, %v, want %d, %v", tt.name, code, err, tt.code, tt.err)
+		}
+		if err == nil && (reversal.Amount != tt.want || reversal.AccID != acc.ID || reversal.ReversalOf == nil || *reversal.ReversalOf != withdrawal.ID) {
+			t.Errorf("%s: reversal = %+v, want credit of %d referencing %d", tt.name, reversal, tt.want, withdrawal.ID)
+		}
+		if got := balanceOf(t, s, acc.ID); got != tt.balance {
+			t.Errorf("%s: balance = %d, want %d", tt.name, got, tt.balance)
+		}
+	}
+}
+
+func TestReverse_TopUp(t *testing.T) {
+	s, _ := newMemoryService(t)
+	acc := newMemoryAccount(t, s, "992000000001", 0)
+	ctx := context.Background()
+
+	topUp, _, err := s.Transaction(ctx, &types.Transaction{AccID: acc.ID, Amount: 500})
+	if err != nil {
+		t.Fatalf("Transaction error: %v", err)
+	}
+	_, _, err = s.Transaction(ctx, &types.Transaction{AccID: acc.ID, Amount: -400})
+	if err != nil {
+		t.Fatalf("Transaction error: %v", err)
+	}
+
+	// reversal of top-up debits account and can't overdraw it
+	_, code, err := s.Reverse(ctx, topUp.ID, &types.Reversal{})
+	if code != http.StatusBadRequest || err != ErrNotEnoughFunds {
+		t.Errorf("Reverse of spent top-up = %d, %v, want 400 and %v", code, err, ErrNotEnoughFunds)
+	}
+	reversal, _, err := s.Reverse(ctx, topUp.ID, &types.Reversal{Amount: 100})
+	if err != nil || reversal.Amount != -100 {
+		t.Fatalf("Reverse = %+v, %v, want debit of 100", reversal, err)
+	}
+	if got := balanceOf(t, s, acc.ID); got != 0 {
+		t.Errorf("balance = %d, want 0", got)
+	}
+}
+
+func TestReverse_NotReversible(t *testing.T) {
+	s, _ := newMemoryService(t)
+	sender := newMemoryAccount(t, s, "992000000001", 1000)
+	recipient := newMemoryAccount(t, s, "992000000002", 0)
+	ctx := context.Background()
+
+	transfer, _, err := s.Transfer(ctx, &types.Transfer{AccID: sender.ID, Phone: recipient.Phone, Amount: 100})
+	if err != nil {
+		t.Fatalf("Transfer error: %v", err)
+	}
+	withdrawal, _, err := s.Transaction(ctx, &types.Transaction{AccID: sender.ID, Amount: -100})
+	if err != nil {
+		t.Fatalf("Transaction error: %v", err)
+	}
+	reversal, _, err := s.Reverse(ctx, withdrawal.ID, &types.Reversal{Amount: 50})
+	if err != nil {
+		t.Fatalf("Reverse error: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		id   int64
+		code int
+		err  error
+	}{
+		{"debit of transfer", transfer.Debit.ID, http.StatusBadRequest, ErrNotReversible},
+		{"credit of transfer", transfer.Credit.ID, http.StatusBadRequest, ErrNotReversible},
+		{"reversal", reversal.ID, http.StatusBadRequest, ErrNotReversible},
+		{"unknown transaction", 1000, http.StatusNotFound, ErrTransactionNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, code, err := s.Reverse(ctx, tt.id, &types.Reversal{})
+			if result != nil || code != tt.code || err != tt.err {
+				t.Errorf("Reverse = %v, %d, %v, want %d, %v", result, code, err, tt.code, tt.err)
+			}
+		})
+	}
+	if got := balanceOf(t, s, sender.ID); got != 850 {
+		t.Errorf("sender balance = %d, want 850", got)
+	}
+}
@@ -5,10 +5,10 @@ import (
 	"errors"
 	"log"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/SYSTEMTerror/GoWallet/internal/pkg/types"
-	"github.com/jackc/pgx/v4"
-	"github.com/jackc/pgx/v4/pgxpool"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -19,35 +19,48 @@ var (
 	ErrOutOfLimit      = errors.New("out of limit")
 	ErrInternal        = errors.New("internal error")
 	ErrExpired         = errors.New("expired")
+	ErrInvalidAmount   = errors.New("invalid amount")
+	ErrSameAccount     = errors.New("sender and recipient are the same account")
+	ErrInvalidPeriod   = errors.New("invalid period")
 )
 
 type Service struct {
-	pool *pgxpool.Pool
+	repo     Repository
+	token    *TokenConfig
+	holds    *HoldConfig
+	location *time.Location
 }
 
-func NewService(pool *pgxpool.Pool) *Service {
-	return &Service{pool: pool}
+// NewService creates wallet service over repository, location is business timezone of statements
+func NewService(repo Repository, token *TokenConfig, holds *HoldConfig, location *time.Location) *Service {
+	return &Service{repo: repo, token: token, holds: holds, location: location}
 }
 
 // Exist checks if account with given phone exists. Returns false and nil or true and account
 func (s *Service) Exist(ctx context.Context, phone string) (bool, *types.Account, int,  error) {
-	acc := &types.Account{Phone: phone}
-	err := s.pool.QueryRow(ctx, `SELECT id, balance, identified, name,  password, active, created FROM accounts WHERE phone = $1`, acc.Phone).Scan(&acc.ID, &acc.Balance, &acc.Identified, &acc.Username, &acc.Password, &acc.Active, &acc.Created)
-	if err == pgx.ErrNoRows {
+	acc, err := s.repo.AccountByPhone(ctx, phone)
+	if err == ErrNotFound {
 		return false, nil, http.StatusOK, nil
 	}
 	if err != nil {
-		log.Println("Exist s.pool.QueryRow error:", err)
+		log.Println("Exist s.repo.AccountByPhone error:", err)
 		return false, nil, http.StatusInternalServerError, ErrInternal
 	}
 
 	return true, acc, http.StatusOK, nil
 }
 
+// Register creates main account of user with wallet in given currency, DefaultCurrency if it is empty
 func (s *Service) Register(ctx context.Context, item *types.RegInfo) (*types.Account, int, error) {
+	item.Currency = strings.ToUpper(item.Currency)
+	if item.Currency == "" {
+		item.Currency = DefaultCurrency
+	}
 	acc := &types.Account{
+		Currency:   item.Currency,
 		Balance:    0,
 		Identified: false,
+		Tier:       TierAnonymous,
 		Username:   item.Username,
 		Phone:      item.Phone,
 	}
@@ -68,97 +81,337 @@ func (s *Service) Register(ctx context.Context, item *types.RegInfo) (*types.Acc
 	}
 
 	item.Password = string(hash)
-	err = s.pool.QueryRow(ctx, `INSERT INTO accounts (name, phone, password) VALUES ($1, $2, $3) RETURNING id, active, created`, item.Username, item.Phone, item.Password).Scan(&acc.ID, &acc.Active, &acc.Created)
+	acc.Password = item.Password
+	err = s.repo.CreateAccount(ctx, acc)
+	if err == ErrUnknownCurrency {
+		log.Println("Register s.repo.CreateAccount unknown currency:", err)
+		return nil, http.StatusBadRequest, ErrUnknownCurrency
+	}
+	if err == ErrExist {
+		log.Println("Register s.repo.CreateAccount account already exist")
+		return nil, http.StatusConflict, ErrExist
+	}
 	if err != nil {
-		log.Println("Register s.pool.QueryRow error:", err)
+		log.Println("Register s.repo.CreateAccount error:", err)
 		return nil, http.StatusInternalServerError, ErrInternal
 	}
 
 	return acc, http.StatusOK, nil
 }
 
-// Transfer transfers money to/from account depending on sign of amount
+// Transaction transfers money to/from account depending on sign of amount.
+// Limit check, ledger insert and balance update run in one database transaction
+// with the account row locked, so concurrent requests can't overdraw the account
 func (s *Service) Transaction(ctx context.Context, item *types.Transaction) (*types.Transaction, int, error) {
-	var limit int64
-	acc, _, err := s.GetAccountByID(ctx, item.AccID)
+	item.RefID, item.ReversalOf, item.HoldID, item.Rate = nil, nil, nil, ""
+	statusCode, err := s.inTx(ctx, "Transaction", func(tx Store) (int, error) {
+		return s.transaction(ctx, tx, item)
+	})
+	if err != nil {
+		return nil, statusCode, err
+	}
+	return item, statusCode, nil
+}
+
+// transaction changes balance of account by item.Amount inside tx and fills item.ID, item.Fee and item.Created.
+// Empty item.Currency is filled with currency of account, other currency is rejected.
+// Fee of top-up or withdrawal is charged additionally, captures and reversals are free
+func (s *Service) transaction(ctx context.Context, tx Store, item *types.Transaction) (int, error) {
+	acc, err := tx.LockAccount(ctx, item.AccID)
+	if err == ErrNotFound {
+		log.Println("Transaction tx.LockAccount no rows:", err)
+		return http.StatusNotFound, ErrNotFound
+	}
+	if err != nil {
+		log.Println("Transaction tx.LockAccount error:", err)
+		return http.StatusInternalServerError, ErrInternal
+	}
+	balance, tier, currency := acc.Balance, acc.Tier, acc.Currency
+	if item.Currency == "" {
+		item.Currency = currency
+	}
+	if item.Currency != currency {
+		log.Println("Transaction currency error:", ErrCurrencyMismatch)
+		return http.StatusBadRequest, ErrCurrencyMismatch
+	}
+
+	item.Fee = 0
+	if item.ReversalOf == nil && item.HoldID == nil {
+		operation := EntryTopUp
+		if item.Amount < 0 {
+			operation = EntryWithdrawal
+		}
+		var statusCode int
+		item.Fee, statusCode, err = s.fee(ctx, tx, operation, tier, currency, item.Amount)
+		if err != nil {
+			return statusCode, err
+		}
+	}
+
+	held, err := tx.HeldAmount(ctx, item.AccID, item.HoldID)
+	if err != nil {
+		log.Println("Transaction tx.HeldAmount error:", err)
+		return http.StatusInternalServerError, ErrInternal
+	}
+
+	if balance-held+item.Amount-item.Fee < 0 {
+		log.Println("Transaction available funds error:", ErrNotEnoughFunds)
+		return http.StatusBadRequest, ErrNotEnoughFunds
+	}
+
+	statusCode, err := s.checkLimits(ctx, tx, item.AccID, tier, currency, balance, item.Amount)
+	if err != nil {
+		return statusCode, err
+	}
+
+	kind, postings := movementPostings(item.AccID, currency, item.Amount)
+	if item.ReversalOf != nil {
+		kind, postings = reversalPostings(item.AccID, currency, item.Amount)
+	}
+	postings = append(postings, feePostings(item.AccID, currency, item.Fee)...)
+	entryID, err := post(ctx, tx, kind, postings)
+	if err != nil {
+		log.Println("Transaction post error:", err)
+		return http.StatusInternalServerError, ErrInternal
+	}
+
+	err = tx.CreateTransaction(ctx, item, entryID)
 	if err != nil {
-		log.Println("Transaction s.GetAccountByID error:", err)
+		log.Println("Transaction tx.CreateTransaction error:", err)
+		return http.StatusInternalServerError, ErrInternal
+	}
+	return http.StatusOK, nil
+}
+
+// Transfer moves money from sender wallet to wallet of user with given phone in the same currency.
+// Both accounts are locked in id order, so opposite transfers can't deadlock
+func (s *Service) Transfer(ctx context.Context, item *types.Transfer) (*types.TransferResult, int, error) {
+	if item.Amount <= 0 {
+		log.Println("Transfer amount error:", ErrInvalidAmount)
+		return nil, http.StatusBadRequest, ErrInvalidAmount
+	}
+
+	var result *types.TransferResult
+	statusCode, err := s.inTx(ctx, "Transfer", func(tx Store) (int, error) {
+		var statusCode int
+		var err error
+		result, statusCode, err = s.transfer(ctx, tx, item)
+		return statusCode, err
+	})
+	if err != nil {
+		return nil, statusCode, err
+	}
+	return result, statusCode, nil
+}
+
+// transfer moves money between accounts inside tx. Empty item.Currency is filled with currency of sender wallet.
+// Fee of transfer is charged from sender in addition to amount
+func (s *Service) transfer(ctx context.Context, tx Store, item *types.Transfer) (*types.TransferResult, int, error) {
+	if item.Currency == "" {
+		acc, err := tx.AccountByID(ctx, item.AccID)
+		if err == ErrNotFound {
+			log.Println("Transfer tx.AccountByID no rows:", err)
+			return nil, http.StatusNotFound, ErrNotFound
+		}
+		if err != nil {
+			log.Println("Transfer tx.AccountByID error:", err)
+			return nil, http.StatusInternalServerError, ErrInternal
+		}
+		item.Currency = acc.Currency
+	}
+
+	// recipient wallet is looked up before locking, missing one is reported after the sender is locked
+	recipientID := int64(0)
+	target, err := tx.WalletByPhone(ctx, item.Phone, item.Currency)
+	if err != nil && err != ErrNotFound {
+		log.Println("Transfer tx.WalletByPhone error:", err)
 		return nil, http.StatusInternalServerError, ErrInternal
 	}
-	if !acc.Identified {
-		limit = 10_000_00 // Dirams
-	} else {
-		limit = 100_000_00 // Dirams
+	if err == nil {
+		recipientID = target.ID
 	}
 
-	if acc.Balance+item.Amount < 0 || acc.Balance+item.Amount>limit {
-		log.Println("Transaction s.GetAccountByID error:", ErrOutOfLimit)
-		return nil, http.StatusBadRequest, ErrOutOfLimit
+	accounts, err := tx.LockAccounts(ctx, item.AccID, recipientID)
+	if err != nil {
+		log.Println("Transfer tx.LockAccounts error:", err)
+		return nil, http.StatusInternalServerError, ErrInternal
+	}
+	var sender, recipient *types.Account
+	for _, acc := range accounts {
+		if acc.ID == item.AccID {
+			sender = acc
+		}
+		if acc.ID == recipientID {
+			recipient = acc
+		}
+	}
+	if sender == nil || recipient == nil {
+		log.Println("Transfer accounts lookup error:", ErrNotFound)
+		return nil, http.StatusNotFound, ErrNotFound
+	}
+	if sender.ID == recipient.ID {
+		log.Println("Transfer accounts lookup error:", ErrSameAccount)
+		return nil, http.StatusBadRequest, ErrSameAccount
+	}
+	if sender.Currency != item.Currency {
+		log.Println("Transfer currency error:", ErrCurrencyMismatch)
+		return nil, http.StatusBadRequest, ErrCurrencyMismatch
+	}
+
+	fee, statusCode, err := s.fee(ctx, tx, EntryTransfer, sender.Tier, sender.Currency, item.Amount)
+	if err != nil {
+		return nil, statusCode, err
 	}
 
-	err = s.pool.QueryRow(ctx, `INSERT INTO transactions (acc_id, amount) VALUES ($1, $2) RETURNING id, created`, item.AccID, item.Amount).Scan(&item.ID, &item.Created)
+	held, err := tx.HeldAmount(ctx, sender.ID, nil)
 	if err != nil {
-		log.Println("Transaction s.pool.QueryRow error:", err)
+		log.Println("Transfer tx.HeldAmount error:", err)
 		return nil, http.StatusInternalServerError, ErrInternal
 	}
 
-	_, err = s.pool.Exec(ctx, `UPDATE accounts SET balance = balance + $1 WHERE id = $2`, item.Amount, item.AccID)
+	if sender.Balance-held-item.Amount-fee < 0 {
+		log.Println("Transfer available funds error:", ErrNotEnoughFunds)
+		return nil, http.StatusBadRequest, ErrNotEnoughFunds
+	}
+
+	statusCode, err = s.checkLimits(ctx, tx, sender.ID, sender.Tier, sender.Currency, sender.Balance, -item.Amount)
+	if err != nil {
+		return nil, statusCode, err
+	}
+	statusCode, err = s.checkLimits(ctx, tx, recipient.ID, recipient.Tier, recipient.Currency, recipient.Balance, item.Amount)
 	if err != nil {
-		log.Println("Transaction s.pool.QueryRow error:", err)
+		return nil, statusCode, err
+	}
+
+	postings := []*types.Posting{
+		{AccID: &sender.ID, Currency: item.Currency, Amount: -item.Amount},
+		{AccID: &recipient.ID, Currency: item.Currency, Amount: item.Amount},
+	}
+	entryID, err := post(ctx, tx, EntryTransfer, append(postings, feePostings(sender.ID, item.Currency, fee)...))
+	if err != nil {
+		log.Println("Transfer post error:", err)
 		return nil, http.StatusInternalServerError, ErrInternal
 	}
-	return item, http.StatusOK, nil
+
+	debit := &types.Transaction{AccID: sender.ID, Currency: item.Currency, Amount: -item.Amount, Fee: fee}
+	err = tx.CreateTransaction(ctx, debit, entryID)
+	if err != nil {
+		log.Println("Transfer tx.CreateTransaction error:", err)
+		return nil, http.StatusInternalServerError, ErrInternal
+	}
+
+	credit := &types.Transaction{AccID: recipient.ID, Currency: item.Currency, Amount: item.Amount, RefID: &debit.ID}
+	err = tx.CreateTransaction(ctx, credit, entryID)
+	if err != nil {
+		log.Println("Transfer tx.CreateTransaction error:", err)
+		return nil, http.StatusInternalServerError, ErrInternal
+	}
+	debit.RefID = &credit.ID
+
+	err = tx.SetTransactionRef(ctx, debit.ID, credit.ID)
+	if err != nil {
+		log.Println("Transfer tx.SetTransactionRef error:", err)
+		return nil, http.StatusInternalServerError, ErrInternal
+	}
+	return &types.TransferResult{Debit: debit, Credit: credit}, http.StatusOK, nil
 }
 
-//GetTransactionsPerMonth returns transactions, sum of transactions and amount of transactions per month last month
+// inTx runs fn in transaction of repository and commits it if fn succeeded
+func (s *Service) inTx(ctx context.Context, name string, fn func(tx Store) (int, error)) (int, error) {
+	statusCode := http.StatusOK
+	var fnErr error
+	err := s.repo.InTx(ctx, func(tx Store) error {
+		statusCode, fnErr = fn(tx)
+		return fnErr
+	})
+	if fnErr != nil {
+		return statusCode, fnErr
+	}
+	if err != nil {
+		log.Println(name, "s.repo.InTx error:", err)
+		return http.StatusInternalServerError, ErrInternal
+	}
+	return statusCode, nil
+}
+
+//GetTransactionsPerMonth returns transactions, sum and count of transactions of current calendar month in business timezone
 func (s *Service) GetTransactionsPerMonth(ctx context.Context, accID int64) ([]*types.Transaction, int64, int64, int, error) {
-	transactions := []*types.Transaction{}
-	var sum int64
-	var count int64
+	from, to := s.CurrentMonth()
+	return s.GetTransactions(ctx, accID, from, to)
+}
 
-	rows, err := s.pool.Query(ctx, `SELECT id, acc_id, amount, created FROM transactions WHERE acc_id = $1 AND to_char(created, 'Mon') = to_char(current_date, 'Mon')`, accID)
+// GetTransactions returns transactions, sum and count of transactions of account created in [from, to)
+func (s *Service) GetTransactions(ctx context.Context, accID int64, from time.Time, to time.Time) ([]*types.Transaction, int64, int64, int, error) {
+	transactions, err := s.repo.Transactions(ctx, accID, from, to)
 	if err != nil {
-		log.Println("GetTransactionsPerMonth s.pool.Query error:", err)
+		log.Println("GetTransactions s.repo.Transactions error:", err)
 		return nil, 0, 0, http.StatusInternalServerError, ErrInternal
 	}
-	defer rows.Close()
 
-	for rows.Next() {
-		var transaction types.Transaction
-		err = rows.Scan(&transaction.ID, &transaction.AccID, &transaction.Amount, &transaction.Created)
-		if err != nil {
-			log.Println("GetTransactionsPerMonth rows.Scan error:", err)
-			return nil, 0, 0, http.StatusInternalServerError, ErrInternal
-		}
-		transactions = append(transactions, &transaction)
+	var sum int64
+	for _, transaction := range transactions {
 		sum += transaction.Amount
-		count++
 	}
+	count := int64(len(transactions))
 
 	return transactions, sum, count, http.StatusOK, nil
 }
 
-func (s *Service) GetAccountByID(ctx context.Context, id int64) (*types.Account, int, error) {
-	acc := &types.Account{}
-	err := s.pool.QueryRow(ctx, `SELECT id, balance, identified, name, phone, password, active, created FROM accounts WHERE id = $1`, id).Scan(&acc.ID, &acc.Balance, &acc.Identified, &acc.Username, &acc.Phone, &acc.Password, &acc.Active, &acc.Created)
-	if err == pgx.ErrNoRows {
-		log.Println("GetAccountByID s.pool.QueryRow no rows:", err)
-		return nil, http.StatusNotFound, ErrNotFound
+// periodStarts returns start of day and of month of now in business timezone
+func (s *Service) periodStarts(now time.Time) (time.Time, time.Time) {
+	now = now.In(s.location)
+	return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, s.location), time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, s.location)
+}
+
+// CurrentMonth returns [start, end) of current calendar month in business timezone
+func (s *Service) CurrentMonth() (time.Time, time.Time) {
+	return s.monthOf(time.Now())
+}
+
+// monthOf returns [start, end) of calendar month of t in business timezone
+func (s *Service) monthOf(t time.Time) (time.Time, time.Time) {
+	_, from := s.periodStarts(t)
+	return from, from.AddDate(0, 1, 0)
+}
+
+// ParsePeriod parses [from, to) bounds given as RFC 3339 time or as date (2006-01-02) in business timezone
+func (s *Service) ParsePeriod(from string, to string) (time.Time, time.Time, int, error) {
+	fromTime, err := s.parseTime(from)
+	if err != nil {
+		log.Println("ParsePeriod s.parseTime error:", err)
+		return time.Time{}, time.Time{}, http.StatusBadRequest, ErrInvalidPeriod
 	}
+	toTime, err := s.parseTime(to)
 	if err != nil {
-		log.Println("GetAccountByID s.pool.QueryRow error:", err)
-		return nil, http.StatusInternalServerError, ErrInternal
+		log.Println("ParsePeriod s.parseTime error:", err)
+		return time.Time{}, time.Time{}, http.StatusBadRequest, ErrInvalidPeriod
+	}
+	if !fromTime.Before(toTime) {
+		log.Println("ParsePeriod from is not before to:", ErrInvalidPeriod)
+		return time.Time{}, time.Time{}, http.StatusBadRequest, ErrInvalidPeriod
 	}
 
-	return acc, http.StatusOK, nil
+	return fromTime, toTime, http.StatusOK, nil
+}
+
+func (s *Service) parseTime(value string) (time.Time, error) {
+	t, err := time.Parse(time.RFC3339, value)
+	if err == nil {
+		return t, nil
+	}
+	return time.ParseInLocation("2006-01-02", value, s.location)
 }
 
-func (s *Service) Identify(ctx context.Context, id int64) (int, error) {
-	_, err := s.pool.Exec(ctx, `UPDATE accounts SET identified = true WHERE id = $1`, id)
+func (s *Service) GetAccountByID(ctx context.Context, id int64) (*types.Account, int, error) {
+	acc, err := s.repo.AccountByID(ctx, id)
+	if err == ErrNotFound {
+		log.Println("GetAccountByID s.repo.AccountByID no rows:", err)
+		return nil, http.StatusNotFound, ErrNotFound
+	}
 	if err != nil {
-		log.Println("Identify s.pool.Exec error:", err)
-		return http.StatusInternalServerError, ErrInternal
+		log.Println("GetAccountByID s.repo.AccountByID error:", err)
+		return nil, http.StatusInternalServerError, ErrInternal
 	}
 
-	return http.StatusOK, nil
+	return acc, http.StatusOK, nil
 }
@@ -0,0 +1,250 @@
+package wallet
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/SYSTEMTerror/GoWallet/internal/pkg/types"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// newTestService connects to database from GOWALLET_TEST_DSN with applied migrations ("go run . migrate up" in cmd)
+func newTestService(t *testing.T) *Service {
+	t.Helper()
+	dsn := os.Getenv("GOWALLET_TEST_DSN")
+	if dsn == "" {
+		t.Skip("GOWALLET_TEST_DSN is not set")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	pool, err := pgxpool.Connect(ctx, dsn)
+	if err != nil {
+		t.Fatalf("pgxpool.Connect error: %v", err)
+	}
+	t.Cleanup(pool.Close)
+
+	return NewService(NewPostgresRepository(pool), &TokenConfig{Secret: "test", TTL: time.Minute}, &HoldConfig{TTL: time.Hour, ExpiryInterval: time.Minute}, time.UTC)
+}
+
+// newMemoryService returns service on MemoryRepository with USD currency
+func newMemoryService(t *testing.T) (*Service, *MemoryRepository) {
+	t.Helper()
+	repo := NewMemoryRepository()
+	repo.AddCurrency(&types.Currency{Code: "USD", Name: "US Dollar", MinorUnits: 2})
+	return NewService(repo, &TokenConfig{Secret: "test", TTL: time.Minute}, &HoldConfig{TTL: time.Hour, ExpiryInterval: time.Minute}, time.UTC), repo
+}
+
+// newMemoryAccount registers account of phone in USD and tops it up by balance
+func newMemoryAccount(t *testing.T, s *Service, phone string, balance int64) *types.Account {
+	t.Helper()
+	ctx := context.Background()
+	acc, _, err := s.Register(ctx, &types.RegInfo{Username: phone, Phone: phone, Password: "12345678", Currency: "USD"})
+	if err != nil {
+		t.Fatalf("Register error: %v", err)
+	}
+	if balance != 0 {
+		_, _, err = s.Transaction(ctx, &types.Transaction{AccID: acc.ID, Amount: balance})
+		if err != nil {
+			t.Fatalf("Transaction top-up error: %v", err)
+		}
+	}
+	return acc
+}
+
+// balanceOf returns ledger balance of account
+func balanceOf(t *testing.T, s *Service, accID int64) int64 {
+	t.Helper()
+	acc, _, err := s.GetAccountByID(context.Background(), accID)
+	if err != nil {
+		t.Fatalf("GetAccountByID error: %v", err)
+	}
+	return acc.Balance
+}
+
+func TestTransaction_ConcurrentWithdrawalsNoOverdraft(t *testing.T) {
+	testConcurrentWithdrawals(t, newTestService(t))
+}
+
+func TestTransaction_ConcurrentWithdrawalsNoOverdraftMemory(t *testing.T) {
+	s, repo := newMemoryService(t)
+	// default currency with default withdrawal fee of migration
+	repo.AddCurrency(&types.Currency{Code: DefaultCurrency, Name: "Somoni", MinorUnits: 2})
+	maxFee := int64(5000)
+	repo.AddFee(&types.FeeRule{Operation: EntryWithdrawal, Currency: DefaultCurrency, Percent: "1", MinFee: 100, MaxFee: &maxFee})
+	testConcurrentWithdrawals(t, s)
+}
+
+func testConcurrentWithdrawals(t *testing.T, s *Service) {
+	ctx := context.Background()
+
+	acc, _, err := s.Register(ctx, &types.RegInfo{
+		Username: "concurrent",
+		Phone:    fmt.Sprintf("test-%d", time.Now().UnixNano()),
+		Password: "12345678",
+	})
+	if err != nil {
+		t.Fatalf("Register error: %v", err)
+	}
+
+	_, _, err = s.Transaction(ctx, &types.Transaction{AccID: acc.ID, Amount: 1000})
+	if err != nil {
+		t.Fatalf("Transaction top-up error: %v", err)
+	}
+
+	const workers = 50
+	var wg sync.WaitGroup
+	codes := make(chan int, workers)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, code, _ := s.Transaction(ctx, &types.Transaction{AccID: acc.ID, Amount: -100})
+			codes <- code
+		}()
+	}
+	wg.Wait()
+	close(codes)
+
+	succeeded := 0
+	for code := range codes {
+		switch code {
+		case http.StatusOK:
+			succeeded++
+		case http.StatusBadRequest:
+		default:
+			t.Errorf("unexpected status code %d", code)
+		}
+	}
+	// every withdrawal of 100 costs minimal fee of 100
+	if succeeded != 5 {
+		t.Errorf("succeeded withdrawals = %d, want 5", succeeded)
+	}
+
+	got, _, err := s.GetAccountByID(ctx, acc.ID)
+	if err != nil {
+		t.Fatalf("GetAccountByID error: %v", err)
+	}
+	if got.Balance != 0 {
+		t.Errorf("balance = %d, want 0", got.Balance)
+	}
+
+	transactions, _, _, _, err := s.GetTransactions(ctx, acc.ID, acc.Created.Add(-time.Hour), time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatalf("GetTransactions error: %v", err)
+	}
+	var ledgerSum, fees int64
+	for _, transaction := range transactions {
+		ledgerSum += transaction.Amount - transaction.Fee
+		fees += transaction.Fee
+	}
+	if ledgerSum != got.Balance {
+		t.Errorf("ledger sum = %d, balance = %d", ledgerSum, got.Balance)
+	}
+	if fees != 500 {
+		t.Errorf("charged fees = %d, want 500", fees)
+	}
+
+	_, err = s.CheckLedger(ctx)
+	if err != nil {
+		t.Errorf("CheckLedger error: %v", err)
+	}
+}
+
+func TestParsePeriod(t *testing.T) {
+	dushanbe, err := time.LoadLocation("Asia/Dushanbe")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewService(NewMemoryRepository(), &TokenConfig{Secret: "test", TTL: time.Minute}, &HoldConfig{TTL: time.Hour}, dushanbe)
+
+	tests := []struct {
+		name string
+		from string
+		to   string
+		want [2]time.Time
+		code int
+	}{
+		{"dates in business timezone", "2021-01-01", "2021-02-01",
+			[2]time.Time{time.Date(2020, 12, 31, 19, 0, 0, 0, time.UTC), time.Date(2021, 1, 31, 19, 0, 0, 0, time.UTC)}, http.StatusOK},
+		{"RFC 3339 keeps its offset", "2021-01-01T00:00:00Z", "2021-01-01T10:00:00+03:00",
+			[2]time.Time{time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2021, 1, 1, 7, 0, 0, 0, time.UTC)}, http.StatusOK},
+		{"date and RFC 3339", "2021-01-01", "2021-01-01T00:00:00Z",
+			[2]time.Time{time.Date(2020, 12, 31, 19, 0, 0, 0, time.UTC), time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)}, http.StatusOK},
+		{"from equals to", "2021-01-01", "2021-01-01", [2]time.Time{}, http.StatusBadRequest},
+		{"from after to", "2021-02-01", "2021-01-01", [2]time.Time{}, http.StatusBadRequest},
+		{"same instant in other offsets", "2021-01-01T05:00:00+05:00", "2021-01-01T00:00:00Z", [2]time.Time{}, http.StatusBadRequest},
+		{"bad from", "01.01.2021", "2021-02-01", [2]time.Time{}, http.StatusBadRequest},
+		{"bad to", "2021-01-01", "2021-02-30", [2]time.Time{}, http.StatusBadRequest},
+		{"empty", "", "", [2]time.Time{}, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			from, to, code, err := s.ParsePeriod(tt.from, tt.to)
+			if code != tt.code {
+				t.Fatalf("ParsePeriod = %d, %v, want %d", code, err, tt.code)
+			}
+			if code != http.StatusOK {
+				if err != ErrInvalidPeriod {
+					t.Errorf("ParsePeriod error = %v, want %v", err, ErrInvalidPeriod)
+				}
+				return
+			}
+			if !from.Equal(tt.want[0]) || !to.Equal(tt.want[1]) {
+				t.Errorf("ParsePeriod = [%v, %v), want [%v, %v)", from, to, tt.want[0], tt.want[1])
+			}
+		})
+	}
+}
+
+func TestMonthOf(t *testing.T) {
+	dushanbe, err := time.LoadLocation("Asia/Dushanbe")
+	if err != nil {
+		t.Fatal(err)
+	}
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		location *time.Location
+		t        time.Time
+		from     time.Time
+		to       time.Time
+	}{
+		{"middle of month", time.UTC, time.Date(2021, 3, 15, 12, 0, 0, 0, time.UTC),
+			time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC), time.Date(2021, 4, 1, 0, 0, 0, 0, time.UTC)},
+		{"first instant of month", time.UTC, time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC),
+			time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC), time.Date(2021, 4, 1, 0, 0, 0, 0, time.UTC)},
+		{"last instant of month", time.UTC, time.Date(2021, 2, 28, 23, 59, 59, 999999999, time.UTC),
+			time.Date(2021, 2, 1, 0, 0, 0, 0, time.UTC), time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC)},
+		{"next year in business timezone", dushanbe, time.Date(2021, 12, 31, 20, 0, 0, 0, time.UTC),
+			time.Date(2022, 1, 1, 0, 0, 0, 0, dushanbe), time.Date(2022, 2, 1, 0, 0, 0, 0, dushanbe)},
+		{"previous month in business timezone", newYork, time.Date(2021, 4, 1, 2, 0, 0, 0, time.UTC),
+			time.Date(2021, 3, 1, 0, 0, 0, 0, newYork), time.Date(2021, 4, 1, 0, 0, 0, 0, newYork)},
+		{"month with DST change", newYork, time.Date(2021, 3, 20, 12, 0, 0, 0, newYork),
+			time.Date(2021, 3, 1, 5, 0, 0, 0, time.UTC), time.Date(2021, 4, 1, 4, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewService(NewMemoryRepository(), &TokenConfig{Secret: "test", TTL: time.Minute}, &HoldConfig{TTL: time.Hour}, tt.location)
+			from, to := s.monthOf(tt.t)
+			if !from.Equal(tt.from) || !to.Equal(tt.to) {
+				t.Errorf("monthOf(%v) = [%v, %v), want [%v, %v)", tt.t, from, to, tt.from, tt.to)
+			}
+		})
+	}
+
+	s, _ := newMemoryService(t)
+	from, to := s.CurrentMonth()
+	if now := time.Now(); now.Before(from) || !now.Before(to) || from.Day() != 1 || to.Day() != 1 {
+		t.Errorf("CurrentMonth = [%v, %v), want calendar month containing now", from, to)
+	}
+}
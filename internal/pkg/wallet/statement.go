@@ -0,0 +1,104 @@
+package wallet
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/SYSTEMTerror/GoWallet/internal/pkg/types"
+)
+
+// StatementWriter receives statement while it is read from database: header with opening balance,
+// transactions in order with running balance and footer with totals and closing balance
+type StatementWriter interface {
+	Begin(statement *types.Statement) error
+	Line(line *types.StatementLine) error
+	End(statement *types.Statement) error
+}
+
+// Statement streams statement of account for [from, to) to w. Balances and transactions are read
+// in one repeatable read transaction, so closing balance is opening balance plus listed transactions.
+// Balance effect of transaction is its amount minus fee. Error after Begin means output is incomplete
+func (s *Service) Statement(ctx context.Context, accID int64, from time.Time, to time.Time, w StatementWriter) (int, error) {
+	statement := &types.Statement{AccID: accID, From: from, To: to}
+	var balance int64
+	err := s.repo.Statement(ctx, statement, func() error {
+		err := w.Begin(statement)
+		if err != nil {
+			log.Println("Statement w.Begin error:", err)
+			return err
+		}
+		balance = statement.Opening
+		return nil
+	}, func(transaction *types.Transaction) error {
+		line := &types.StatementLine{Transaction: *transaction}
+		balance += line.Amount - line.Fee
+		line.Balance = balance
+		if line.Amount > 0 {
+			statement.Credit += line.Amount
+		} else {
+			statement.Debit -= line.Amount
+		}
+		statement.Fees += line.Fee
+		statement.Count++
+
+		err := w.Line(line)
+		if err != nil {
+			log.Println("Statement w.Line error:", err)
+		}
+		return err
+	})
+	if err == ErrNotFound {
+		log.Println("Statement s.repo.Statement not found:", err)
+		return http.StatusNotFound, ErrNotFound
+	}
+	if err != nil {
+		log.Println("Statement s.repo.Statement error:", err)
+		return http.StatusInternalServerError, ErrInternal
+	}
+	statement.Closing = balance
+
+	err = w.End(statement)
+	if err != nil {
+		log.Println("Statement w.End error:", err)
+		return http.StatusInternalServerError, ErrInternal
+	}
+	return http.StatusOK, nil
+}
+
+// GetStatement returns statement of account for [from, to) with transactions of GetTransactions and balance after each of them.
+// Transactions committed between the two queries are either in both opening balance and lines or in neither of them
+func (s *Service) GetStatement(ctx context.Context, accID int64, from time.Time, to time.Time) (*types.Statement, []*types.StatementLine, int, error) {
+	transactions, _, count, statusCode, err := s.GetTransactions(ctx, accID, from, to)
+	if err != nil {
+		return nil, nil, statusCode, err
+	}
+
+	statement := &types.Statement{AccID: accID, From: from, To: to, Count: count}
+	err = s.repo.StatementHeader(ctx, statement)
+	if err == ErrNotFound {
+		log.Println("GetStatement s.repo.StatementHeader not found:", err)
+		return nil, nil, http.StatusNotFound, ErrNotFound
+	}
+	if err != nil {
+		log.Println("GetStatement s.repo.StatementHeader error:", err)
+		return nil, nil, http.StatusInternalServerError, ErrInternal
+	}
+
+	lines := make([]*types.StatementLine, 0, len(transactions))
+	balance := statement.Opening
+	for _, transaction := range transactions {
+		balance += transaction.Amount - transaction.Fee
+		if transaction.Amount > 0 {
+			statement.Credit += transaction.Amount
+		} else {
+			statement.Debit -= transaction.Amount
+		}
+		statement.Fees += transaction.Fee
+		lines = append(lines, &types.StatementLine{Transaction: *transaction, Balance: balance})
+	}
+	statement.Closing = balance
+
+	return statement, lines, http.StatusOK, nil
+}
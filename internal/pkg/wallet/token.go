@@ -0,0 +1,104 @@
+package wallet
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/SYSTEMTerror/GoWallet/internal/pkg/types"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var ErrInvalidToken = errors.New("invalid token")
+
+// TokenConfig is configuration of access tokens issued by Login
+type TokenConfig struct {
+	Secret string
+	TTL    time.Duration
+}
+
+// tokenPayload is signed part of access token
+type tokenPayload struct {
+	AccID   int64 `json:"acc_id"`
+	Expires int64 `json:"exp"`
+}
+
+// Login checks phone and password of active account and issues signed access token
+func (s *Service) Login(ctx context.Context, item *types.LoginInfo) (*types.Token, int, error) {
+	acc, err := s.repo.AccountByPhone(ctx, item.Phone)
+	if err == ErrNotFound || (err == nil && !acc.Active) {
+		log.Println("Login s.repo.AccountByPhone no active account:", item.Phone)
+		return nil, http.StatusUnauthorized, ErrInvalidPassword
+	}
+	if err != nil {
+		log.Println("Login s.repo.AccountByPhone error:", err)
+		return nil, http.StatusInternalServerError, ErrInternal
+	}
+
+	err = bcrypt.CompareHashAndPassword([]byte(acc.Password), []byte(item.Password))
+	if err != nil {
+		log.Println("Login bcrypt.CompareHashAndPassword error:", err)
+		return nil, http.StatusUnauthorized, ErrInvalidPassword
+	}
+
+	expires := time.Now().Add(s.token.TTL)
+	token, err := s.signToken(&tokenPayload{AccID: acc.ID, Expires: expires.Unix()})
+	if err != nil {
+		log.Println("Login s.signToken error:", err)
+		return nil, http.StatusInternalServerError, ErrInternal
+	}
+
+	return &types.Token{Token: token, Expires: expires}, http.StatusOK, nil
+}
+
+// IDByToken returns account id from valid access token, ErrInvalidToken or ErrExpired otherwise
+func (s *Service) IDByToken(ctx context.Context, token string) (int64, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 2 {
+		return 0, ErrInvalidToken
+	}
+	data, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return 0, ErrInvalidToken
+	}
+	sign, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return 0, ErrInvalidToken
+	}
+	if !hmac.Equal(sign, s.tokenSign(data)) {
+		return 0, ErrInvalidToken
+	}
+
+	var payload tokenPayload
+	err = json.Unmarshal(data, &payload)
+	if err != nil {
+		return 0, ErrInvalidToken
+	}
+	if time.Now().Unix() >= payload.Expires {
+		return 0, ErrExpired
+	}
+
+	return payload.AccID, nil
+}
+
+// signToken encodes payload and its hmac-sha256 signature as "payload.signature" in base64url
+func (s *Service) signToken(payload *tokenPayload) (string, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data) + "." + base64.RawURLEncoding.EncodeToString(s.tokenSign(data)), nil
+}
+
+func (s *Service) tokenSign(data []byte) []byte {
+	h := hmac.New(sha256.New, []byte(s.token.Secret))
+	h.Write(data)
+	return h.Sum(nil)
+}
@@ -0,0 +1,109 @@
+package wallet
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SYSTEMTerror/GoWallet/internal/pkg/types"
+)
+
+func TestLogin(t *testing.T) {
+	s, repo := newMemoryService(t)
+	acc := newMemoryAccount(t, s, "992000000001", 0)
+	inactive := newMemoryAccount(t, s, "992000000002", 0)
+	repo.write(func(d *memoryData) error {
+		a := d.accounts[inactive.ID]
+		a.Active = false
+		d.accounts[inactive.ID] = a
+		return nil
+	})
+	ctx := context.Background()
+
+	token, code, err := s.Login(ctx, &types.LoginInfo{Phone: acc.Phone, Password: "12345678"})
+	if err != nil || code != http.StatusOK {
+		t.Fatalf("Login = %d, %v, want 200", code, err)
+	}
+	if until := time.Until(token.Expires); until <= 0 || until > s.token.TTL {
+		t.Errorf("token expires in %v, want within TTL %v", until, s.token.TTL)
+	}
+	id, err := s.IDByToken(ctx, token.Token)
+	if err != nil || id != acc.ID {
+		t.Errorf("IDByToken = %d, %v, want %d", id, err, acc.ID)
+	}
+
+	tests := []struct {
+		name     string
+		phone    string
+		password string
+	}{
+		{"wrong password", acc.Phone, "87654321"},
+		{"unknown phone", "992000000009", "12345678"},
+		{"inactive account", inactive.Phone, "12345678"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, code, err := s.Login(ctx, &types.LoginInfo{Phone: tt.phone, Password: tt.password})
+			if code != http.StatusUnauthorized || !errors.Is(err, ErrInvalidPassword) || token != nil {
+				t.Errorf("Login = %v, %d, %v, want 401 and %v", token, code, err, ErrInvalidPassword)
+			}
+		})
+	}
+}
+
+func TestIDByToken(t *testing.T) {
+	s, _ := newMemoryService(t)
+	ctx := context.Background()
+
+	valid, err := s.signToken(&tokenPayload{AccID: 7, Expires: time.Now().Add(time.Minute).Unix()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expired, err := s.signToken(&tokenPayload{AccID: 7, Expires: time.Now().Add(-time.Second).Unix()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	other := &Service{token: &TokenConfig{Secret: "other", TTL: time.Minute}}
+	foreign, err := other.signToken(&tokenPayload{AccID: 7, Expires: time.Now().Add(time.Minute).Unix()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// payload of another account with signature of valid token
+	payload, _ := json.Marshal(&tokenPayload{AccID: 8, Expires: time.Now().Add(time.Minute).Unix()})
+	parts := strings.Split(valid, ".")
+	tamperedPayload := base64.RawURLEncoding.EncodeToString(payload) + "." + parts[1]
+	sign, _ := base64.RawURLEncoding.DecodeString(parts[1])
+	sign[0] ^= 1
+	tamperedSign := parts[0] + "." + base64.RawURLEncoding.EncodeToString(sign)
+
+	tests := []struct {
+		name  string
+		token string
+		id    int64
+		err   error
+	}{
+		{"valid", valid, 7, nil},
+		{"expired", expired, 0, ErrExpired},
+		{"tampered payload", tamperedPayload, 0, ErrInvalidToken},
+		{"tampered signature", tamperedSign, 0, ErrInvalidToken},
+		{"signed by other key", foreign, 0, ErrInvalidToken},
+		{"no signature", parts[0], 0, ErrInvalidToken},
+		{"extra part", valid + ".x", 0, ErrInvalidToken},
+		{"not base64", "!!!." + parts[1], 0, ErrInvalidToken},
+		{"empty", "", 0, ErrInvalidToken},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id, err := s.IDByToken(ctx, tt.token)
+			if id != tt.id || err != tt.err {
+				t.Errorf("IDByToken = %d, %v, want %d, %v", id, err, tt.id, tt.err)
+			}
+		})
+	}
+}
@@ -1,9 +1,34 @@
 -- password = 12345678
-INSERT INTO accounts (balance, identified, name, phone, password) VALUES 
-(0, FALSE, '1', '1', '$2a$10$W1uTjnpz.h/hbfWuRhO04ekfs6FffeMsIbtFpxLiFhE6eMgW7oMUi'),
-(1000000, FALSE, '2', '2', '$2a$10$W1uTjnpz.h/hbfWuRhO04ekfs6FffeMsIbtFpxLiFhE6eMgW7oMUi'),
-(10000000, TRUE, '3', '3', '$2a$10$W1uTjnpz.h/hbfWuRhO04ekfs6FffeMsIbtFpxLiFhE6eMgW7oMUi');
-
-INSERT INTO transactions (acc_id, amount) VALUES 
-(1, 1000000),
-(2, 10000000);
\ No newline at end of file
+INSERT INTO accounts (balance, identified, tier, name, phone, password) VALUES 
+(0, FALSE, 'anonymous', '1', '1', '$2a$10$W1uTjnpz.h/hbfWuRhO04ekfs6FffeMsIbtFpxLiFhE6eMgW7oMUi'),
+(1000000, FALSE, 'anonymous', '2', '2', '$2a$10$W1uTjnpz.h/hbfWuRhO04ekfs6FffeMsIbtFpxLiFhE6eMgW7oMUi'),
+(10000000, TRUE, 'full', '3', '3', '$2a$10$W1uTjnpz.h/hbfWuRhO04ekfs6FffeMsIbtFpxLiFhE6eMgW7oMUi');
+
+-- top-ups which make up balances above
+INSERT INTO journal_entries (kind) VALUES 
+('top_up'),
+('top_up');
+
+INSERT INTO postings (entry_id, acc_id, system, amount) VALUES 
+(1, 2, NULL, 1000000),
+(1, NULL, 'cash_in', -1000000),
+(2, 3, NULL, 10000000),
+(2, NULL, 'cash_in', -10000000);
+
+INSERT INTO transactions (acc_id, amount, entry_id) VALUES 
+(2, 1000000, 1),
+(3, 10000000, 2);
+
+
+-- exchange rates
+INSERT INTO exchange_rates (base, quote, rate, spread) VALUES 
+('USD', 'TJS', 10.95, 0.01),
+('EUR', 'TJS', 11.85, 0.01),
+('RUB', 'TJS', 0.1325, 0.015);
+
+-- partner with secret of former security.secret_key, it may call operator routes
+INSERT INTO partners (id, name, endpoints) VALUES 
+('test', 'Test partner', '{*,operator}');
+
+INSERT INTO partner_keys (partner_id, key_id, secret) VALUES 
+('test', '1', 'Secret');